@@ -0,0 +1,186 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// ErrBatcherClosed is returned when sending a command on a closed Batcher
+var ErrBatcherClosed = errors.New("batcher is closed")
+
+const (
+	// defaultBatchWindow is the default time commands are collected before they are sent
+	defaultBatchWindow = time.Millisecond
+
+	// defaultBatchMaxSize is the default number of commands that triggers an immediate send
+	defaultBatchMaxSize = 100
+)
+
+// BatcherOptions configures a Batcher
+type BatcherOptions struct {
+	Window  time.Duration // How long to collect commands before sending them (default: 1ms)
+	MaxSize int           // Send as soon as this many commands are queued (default: 100)
+}
+
+// Batcher coalesces commands from concurrent callers into pipelines
+//
+// Every command waits at most Window for others to join it, then the whole batch is sent
+// on one pooled connection in a single round trip (see Pipeline). This trades a little
+// latency for far fewer round trips when many goroutines issue small independent reads.
+//
+// Commands must be independent of each other: MULTI/WATCH, blocking commands and
+// pub/sub do not belong on a Batcher. Reads do not go through the local cache.
+type Batcher struct {
+	client  *Client
+	opts    BatcherOptions
+	mu      sync.Mutex
+	queue   []*batchedCommand
+	timer   *time.Timer
+	closed  bool
+	running sync.WaitGroup
+}
+
+// batchedCommand is a single caller's command waiting in a Batcher
+type batchedCommand struct {
+	command string
+	args    []interface{}
+	done    <-chan struct{} // The caller's ctx.Done(), the command is dropped if it closes while queued
+	reply   chan batchReply
+}
+
+// batchReply is the reply delivered back to the caller
+type batchReply struct {
+	value interface{}
+	err   error
+}
+
+// NewBatcher creates a Batcher sending on connections from the client's pool
+// Close() the Batcher when done, to send the remaining commands
+func NewBatcher(client *Client, opts BatcherOptions) *Batcher {
+	if opts.Window <= 0 {
+		opts.Window = defaultBatchWindow
+	}
+	if opts.MaxSize <= 0 {
+		opts.MaxSize = defaultBatchMaxSize
+	}
+	return &Batcher{client: client, opts: opts}
+}
+
+// Do queues the command and waits for its reply (or until ctx is done)
+// Commands whose ctx is done before their batch is sent are not sent at all
+func (b *Batcher) Do(ctx context.Context, command string, args ...interface{}) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	cmd := &batchedCommand{command: command, args: args, done: ctx.Done(), reply: make(chan batchReply, 1)}
+
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil, ErrBatcherClosed
+	}
+	b.queue = append(b.queue, cmd)
+	if len(b.queue) >= b.opts.MaxSize {
+		b.sendLocked()
+	} else if b.timer == nil {
+		b.timer = time.AfterFunc(b.opts.Window, b.send)
+	}
+	b.mu.Unlock()
+
+	select {
+	case reply := <-cmd.reply:
+		return reply.value, reply.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Get gets a key from redis in string format (batched)
+//
+// Spec: https://redis.io/commands/get
+func (b *Batcher) Get(ctx context.Context, key string) (string, error) {
+	return redis.String(b.Do(ctx, GetCommand, key))
+}
+
+// HashGet gets a field from a hash in string format (batched)
+//
+// Spec: https://redis.io/commands/hget
+func (b *Batcher) HashGet(ctx context.Context, hash, key string) (string, error) {
+	return redis.String(b.Do(ctx, HashGetCommand, hash, key))
+}
+
+// Close sends the queued commands and waits until every batch has been answered
+// Commands sent after Close() return ErrBatcherClosed
+func (b *Batcher) Close() {
+	b.mu.Lock()
+	b.closed = true
+	b.sendLocked()
+	b.mu.Unlock()
+	b.running.Wait()
+}
+
+// send sends the queued commands (called when the window expires)
+func (b *Batcher) send() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.sendLocked()
+}
+
+// sendLocked hands the queued commands to a new pipeline (b.mu must be held)
+func (b *Batcher) sendLocked() {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	if len(b.queue) == 0 {
+		return
+	}
+	batch := b.queue
+	b.queue = nil
+	b.running.Add(1)
+	go b.run(batch)
+}
+
+// run sends one batch in a pipeline and delivers the replies
+func (b *Batcher) run(batch []*batchedCommand) {
+	defer b.running.Done()
+
+	// Callers that gave up while queued are gone, do not send their commands
+	batch = slices.DeleteFunc(batch, func(cmd *batchedCommand) bool {
+		select {
+		case <-cmd.done:
+			return true
+		default:
+			return false
+		}
+	})
+	if len(batch) == 0 {
+		return
+	}
+
+	conn, err := b.client.GetConnectionWithContext(context.Background())
+	if err != nil {
+		for _, cmd := range batch {
+			cmd.reply <- batchReply{err: err}
+		}
+		return
+	}
+	defer b.client.CloseConnection(conn)
+
+	pipeline := NewPipeline(conn)
+	futures := make([]*Future[interface{}], len(batch))
+	for i, cmd := range batch {
+		futures[i] = pipeline.Do(cmd.command, cmd.args...)
+	}
+	_ = pipeline.Exec() // Errors are delivered through the futures
+
+	for i, cmd := range batch {
+		value, resultErr := futures[i].Result()
+		cmd.reply <- batchReply{value: value, err: resultErr}
+	}
+}
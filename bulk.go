@@ -0,0 +1,286 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// ErrInvalidBulkTTL is the error if the ttl given to SetManyExp is under a second (and not 0)
+var ErrInvalidBulkTTL = errors.New("ttl must be at least one second, or 0 for no expiration")
+
+// bulkChunkSize is the maximum number of keys sent in a single MGET, MSET or MULTI block
+const bulkChunkSize = 500
+
+// BulkItem is a single key/value pair written by SetMany() and SetManyExp()
+type BulkItem struct {
+	Key          string
+	Value        interface{} // string or []byte
+	Dependencies []string    // Dependency keys for this item (optional)
+}
+
+// GetMany gets many keys from redis in string format
+// Only keys that exist are in the returned map (check with: value, found := values[key])
+// Served from the local cache when enabled (see EnableLocalCache)
+// Large batches are split into chunks of 500 keys per MGET
+// Creates a new connection and closes connection at end of function call
+//
+// Custom connections use method: GetManyRaw()
+func GetMany(ctx context.Context, client *Client, keys ...string) (map[string]string, error) {
+	values := make(map[string]string, len(keys))
+	missing := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if value, ok := client.localGet(key); ok {
+			values[key] = value
+		} else {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) == 0 {
+		return values, nil
+	}
+
+	conn, err := client.GetConnectionWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer client.CloseConnection(conn)
+
+	var fetched map[string]string
+	if fetched, err = client.localLoadMany(conn, missing); err != nil {
+		return nil, err
+	}
+	for key, value := range fetched {
+		values[key] = value
+	}
+	return values, nil
+}
+
+// GetManyRaw gets many keys from redis in string format
+// Only keys that exist are in the returned map (check with: value, found := values[key])
+// Uses existing connection (does not close connection)
+//
+// Spec: https://redis.io/commands/mget
+func GetManyRaw(conn redis.Conn, keys ...string) (map[string]string, error) {
+	return getManyRaw(conn, keys, nil, redis.String)
+}
+
+// GetManyBytes gets many keys from redis formatted in bytes
+// Only keys that exist are in the returned map (check with: value, found := values[key])
+// Served from the local cache when enabled (see EnableLocalCache)
+// Creates a new connection and closes connection at end of function call
+//
+// Custom connections use method: GetManyBytesRaw()
+func GetManyBytes(ctx context.Context, client *Client, keys ...string) (map[string][]byte, error) {
+	if client.localCache() != nil {
+		values, err := GetMany(ctx, client, keys...)
+		if err != nil {
+			return nil, err
+		}
+		out := make(map[string][]byte, len(values))
+		for key, value := range values {
+			out[key] = []byte(value)
+		}
+		return out, nil
+	}
+	conn, err := client.GetConnectionWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer client.CloseConnection(conn)
+	return GetManyBytesRaw(conn, keys...)
+}
+
+// GetManyBytesRaw gets many keys from redis formatted in bytes
+// Only keys that exist are in the returned map (check with: value, found := values[key])
+// Uses existing connection (does not close connection)
+//
+// Spec: https://redis.io/commands/mget
+func GetManyBytesRaw(conn redis.Conn, keys ...string) (map[string][]byte, error) {
+	return getManyRaw(conn, keys, nil, redis.Bytes)
+}
+
+// SetMany will set many keys in redis and keep a reference to each item's dependencies
+// Large batches are split into chunks of 500 keys per MSET
+// Creates a new connection and closes connection at end of function call
+//
+// Custom connections use method: SetManyRaw()
+func SetMany(ctx context.Context, client *Client, items []BulkItem) error {
+	conn, err := client.GetConnectionWithContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.CloseConnection(conn)
+	if err = SetManyRaw(conn, items); err != nil {
+		return err
+	}
+	return client.invalidateLocal(ctx, bulkKeys(items)...)
+}
+
+// SetManyRaw will set many keys in redis and keep a reference to each item's dependencies
+// Uses existing connection (does not close connection)
+//
+// Spec: https://redis.io/commands/mset
+func SetManyRaw(conn redis.Conn, items []BulkItem) error {
+	for chunk := range slices.Chunk(items, bulkChunkSize) {
+		args := make([]interface{}, 0, len(chunk)*2)
+		for _, item := range chunk {
+			args = append(args, item.Key, item.Value)
+		}
+		if _, err := conn.Do(MultiSetCommand, args...); err != nil {
+			return err
+		}
+	}
+	return linkManyDependencies(conn, items, 0)
+}
+
+// SetManyExp will set many keys in redis with the same ttl and keep a reference to each item's dependencies
+// Each chunk of 500 keys is written in a single MULTI block
+// A ttl of 0 stores the keys without expiration (as SetMany), a ttl under a second returns
+// ErrInvalidBulkTTL before anything is written
+// Creates a new connection and closes connection at end of function call
+//
+// Custom connections use method: SetManyExpRaw()
+func SetManyExp(ctx context.Context, client *Client, items []BulkItem, ttl time.Duration) error {
+	conn, err := client.GetConnectionWithContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.CloseConnection(conn)
+	if err = SetManyExpRaw(conn, items, ttl); err != nil {
+		return err
+	}
+	return client.invalidateLocal(ctx, bulkKeys(items)...)
+}
+
+// SetManyExpRaw will set many keys in redis with the same ttl and keep a reference to each item's dependencies
+// A ttl of 0 stores the keys without expiration, a ttl under a second returns ErrInvalidBulkTTL
+// Uses existing connection (does not close connection)
+//
+// Commands used:
+// https://redis.io/commands/multi
+// https://redis.io/commands/setex
+// https://redis.io/commands/exec
+func SetManyExpRaw(conn redis.Conn, items []BulkItem, ttl time.Duration) error {
+	if ttl == 0 {
+		return SetManyRaw(conn, items)
+	} else if ttl < time.Second {
+		return ErrInvalidBulkTTL
+	}
+	for chunk := range slices.Chunk(items, bulkChunkSize) {
+		if err := conn.Send(MultiCommand); err != nil {
+			return err
+		}
+		for _, item := range chunk {
+			if err := conn.Send(SetExpirationCommand, item.Key, int64(ttl.Seconds()), item.Value); err != nil {
+				return err
+			}
+		}
+		if err := execRaw(conn); err != nil {
+			return err
+		}
+	}
+	return linkManyDependencies(conn, items, ttl)
+}
+
+// getManyRaw fetches the keys with chunked MGET commands and converts the found values
+// before (optional) runs ahead of every MGET
+func getManyRaw[T any](conn redis.Conn, keys []string, before func() error,
+	convert func(reply interface{}, err error) (T, error),
+) (map[string]T, error) {
+	values := make(map[string]T, len(keys))
+	for chunk := range slices.Chunk(keys, bulkChunkSize) {
+		if before != nil {
+			if err := before(); err != nil {
+				return nil, err
+			}
+		}
+		replies, err := redis.Values(conn.Do(MultiGetCommand, toInterfaces(chunk)...))
+		if err != nil {
+			return nil, err
+		}
+		for i, reply := range replies {
+			if reply == nil || i >= len(chunk) { // Missing key
+				continue
+			}
+			if values[chunk[i]], err = convert(reply, nil); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return values, nil
+}
+
+// linkManyDependencies links the dependencies of every item in a single MULTI block
+// Keys sharing a dependency are added with one SADD, plus one SADD per key for the reverse index
+// (which expires with the key when ttl > 0)
+//
+// Commands used:
+// https://redis.io/commands/multi
+// https://redis.io/commands/sadd
+// https://redis.io/commands/expire
+// https://redis.io/commands/exec
+func linkManyDependencies(conn redis.Conn, items []BulkItem, ttl time.Duration) error {
+	// Group the keys by dependency (in the order they were given)
+	var order []string
+	members := make(map[string][]string)
+	for _, item := range items {
+		for _, dependency := range item.Dependencies {
+			if _, ok := members[dependency]; !ok {
+				order = append(order, dependency)
+			}
+			members[dependency] = append(members[dependency], item.Key)
+		}
+	}
+	if len(order) == 0 {
+		return nil
+	}
+
+	if err := conn.Send(MultiCommand); err != nil {
+		return err
+	}
+	for _, dependency := range order {
+		for chunk := range slices.Chunk(members[dependency], bulkChunkSize) {
+			args := append([]interface{}{DependencyPrefix + dependency}, toInterfaces(chunk)...)
+			if err := conn.Send(AddToSetCommand, args...); err != nil {
+				return err
+			}
+		}
+	}
+	for _, item := range items {
+		if len(item.Dependencies) == 0 {
+			continue
+		}
+		args := append([]interface{}{ReverseDependencyPrefix + item.Key}, toInterfaces(item.Dependencies)...)
+		if err := conn.Send(AddToSetCommand, args...); err != nil {
+			return err
+		}
+		if ttl > 0 {
+			if err := conn.Send(ExpireCommand, args[0], int64(ttl.Seconds())); err != nil {
+				return err
+			}
+		}
+	}
+	return execRaw(conn)
+}
+
+// execRaw fires EXEC for the commands queued after MULTI
+// redigomock returns a nil reply for EXEC without a registered expectation (see linkDependencies)
+func execRaw(conn redis.Conn) (err error) {
+	if _, err = redis.Values(conn.Do(ExecuteCommand)); errors.Is(err, redis.ErrNil) {
+		err = nil
+	}
+	return err
+}
+
+// bulkKeys returns the keys of the items
+func bulkKeys(items []BulkItem) []string {
+	keys := make([]string, len(items))
+	for i, item := range items {
+		keys[i] = item.Key
+	}
+	return keys
+}
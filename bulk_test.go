@@ -0,0 +1,261 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestGetMany tests the methods GetMany() and GetManyRaw()
+func TestGetMany(t *testing.T) {
+	t.Run("found and missing keys", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.Command(MultiGetCommand, "a", "b", "c").Expect([]interface{}{[]byte("1"), nil, []byte("3")})
+
+		values, err := GetMany(context.Background(), client, "a", "b", "c")
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"a": "1", "c": "3"}, values)
+		_, found := values["b"]
+		assert.False(t, found)
+	})
+
+	t.Run("large batches are chunked", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		keys := make([]string, bulkChunkSize+1)
+		first := make([]interface{}, bulkChunkSize)
+		replies := make([]interface{}, bulkChunkSize)
+		for i := range keys {
+			keys[i] = fmt.Sprintf("key:%d", i)
+			if i < bulkChunkSize {
+				first[i] = keys[i]
+				replies[i] = []byte("v")
+			}
+		}
+		firstCmd := conn.Command(MultiGetCommand, first...).Expect(replies)
+		lastCmd := conn.Command(MultiGetCommand, keys[bulkChunkSize]).Expect([]interface{}{[]byte("last")})
+
+		values, err := GetManyRaw(conn, keys...)
+		require.NoError(t, err)
+		assert.Len(t, values, bulkChunkSize+1)
+		assert.Equal(t, "last", values[keys[bulkChunkSize]])
+		assert.Equal(t, 1, conn.Stats(firstCmd))
+		assert.Equal(t, 1, conn.Stats(lastCmd))
+	})
+
+	t.Run("no keys", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		cmd := conn.GenericCommand(MultiGetCommand).Expect([]interface{}{})
+		values, err := GetMany(context.Background(), client)
+		require.NoError(t, err)
+		assert.Empty(t, values)
+		assert.False(t, cmd.Called)
+	})
+
+	t.Run("served from the local cache", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+		lc := loadMockLocalCache(client, LocalCacheOptions{})
+		lc.store.set("a", "local", lc.store.currentEpoch())
+
+		conn.Command(MultiGetCommand, "b").Expect([]interface{}{[]byte("2")})
+		conn.Command(PTTLCommand, "b").Expect(ttlPersistent)
+
+		values, err := GetMany(context.Background(), client, "a", "b")
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"a": "local", "b": "2"}, values)
+
+		value, ok := lc.store.get("b")
+		assert.True(t, ok)
+		assert.Equal(t, "2", value)
+	})
+
+	t.Run("command error", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.Command(MultiGetCommand, "a").ExpectError(errTestLoader)
+
+		_, err := GetMany(context.Background(), client, "a")
+		require.ErrorIs(t, err, errTestLoader)
+	})
+}
+
+// TestGetManyBytes tests the methods GetManyBytes() and GetManyBytesRaw()
+func TestGetManyBytes(t *testing.T) {
+	client, conn := loadMockRedis(t)
+	defer client.CloseAll(conn)
+
+	conn.Command(MultiGetCommand, "a", "b").Expect([]interface{}{nil, []byte("2")})
+
+	values, err := GetManyBytes(context.Background(), client, "a", "b")
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"b": []byte("2")}, values)
+}
+
+// TestSetMany tests the methods SetMany() and SetManyRaw()
+func TestSetMany(t *testing.T) {
+	t.Run("sets values and links dependencies in one multi", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		msetCmd := conn.Command(MultiSetCommand, "a", "1", "b", "2").Expect("OK")
+		multiCmd := conn.Command(MultiCommand).Expect("OK")
+		userCmd := conn.Command(AddToSetCommand, DependencyPrefix+"user", "a", "b").Expect("QUEUED")
+		teamCmd := conn.Command(AddToSetCommand, DependencyPrefix+"team", "b").Expect("QUEUED")
+		aReverseCmd := mockReverseDependencies(conn, "a", "user").Expect("QUEUED")
+		bReverseCmd := mockReverseDependencies(conn, "b", "user", "team").Expect("QUEUED")
+		conn.Command(ExecuteCommand).Expect([]interface{}{int64(2), int64(1)})
+
+		err := SetMany(context.Background(), client, []BulkItem{
+			{Key: "a", Value: "1", Dependencies: []string{"user"}},
+			{Key: "b", Value: "2", Dependencies: []string{"user", "team"}},
+		})
+		require.NoError(t, err)
+		assert.True(t, msetCmd.Called)
+		assert.Equal(t, 1, conn.Stats(multiCmd))
+		assert.True(t, userCmd.Called)
+		assert.True(t, teamCmd.Called)
+		assert.True(t, aReverseCmd.Called)
+		assert.True(t, bReverseCmd.Called)
+	})
+
+	t.Run("without dependencies", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.Command(MultiSetCommand, "a", "1").Expect("OK")
+		multiCmd := conn.Command(MultiCommand).Expect("OK")
+
+		require.NoError(t, SetManyRaw(conn, []BulkItem{{Key: "a", Value: "1"}}))
+		assert.False(t, multiCmd.Called)
+	})
+
+	t.Run("invalidates the local cache", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+		lc := loadMockLocalCache(client, LocalCacheOptions{})
+		lc.store.set("a", "old", lc.store.currentEpoch())
+
+		conn.Command(MultiSetCommand, "a", "1").Expect("OK")
+		pubCmd := conn.Command(PublishCommand, LocalCacheChannel, []byte(`{"keys":["a"]}`)).Expect(int64(1))
+
+		require.NoError(t, SetMany(context.Background(), client, []BulkItem{{Key: "a", Value: "1"}}))
+		assert.True(t, pubCmd.Called)
+		assert.Equal(t, 0, lc.store.len())
+	})
+
+	t.Run("command error", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.Command(MultiSetCommand, "a", "1").ExpectError(errTestLoader)
+
+		err := SetMany(context.Background(), client, []BulkItem{{Key: "a", Value: "1"}})
+		require.ErrorIs(t, err, errTestLoader)
+	})
+
+	t.Run("set many using real redis", func(t *testing.T) {
+		if testing.Short() {
+			t.Skip("skipping live local redis tests")
+		}
+
+		client, conn, err := loadRealRedis(t)
+		require.NoError(t, err)
+		defer client.CloseAll(conn)
+		require.NoError(t, clearRealRedis(conn))
+
+		items := make([]BulkItem, bulkChunkSize*2+1)
+		for i := range items {
+			items[i] = BulkItem{Key: fmt.Sprintf("bulk:%d", i), Value: i, Dependencies: []string{testDependantKey}}
+		}
+		require.NoError(t, SetManyExp(context.Background(), client, items, time.Minute))
+
+		var values map[string]string
+		values, err = GetMany(context.Background(), client, "bulk:0", "bulk:1000", "missing")
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"bulk:0": "0", "bulk:1000": "1000"}, values)
+
+		var total int
+		total, err = KillByDependency(context.Background(), client, testDependantKey)
+		require.NoError(t, err)
+		assert.Equal(t, len(items), total)
+	})
+}
+
+// TestSetManyExp tests the methods SetManyExp() and SetManyExpRaw()
+func TestSetManyExp(t *testing.T) {
+	client, conn := loadMockRedis(t)
+	defer client.CloseAll(conn)
+
+	multiCmd := conn.Command(MultiCommand).Expect("OK")
+	aCmd := conn.Command(SetExpirationCommand, "a", int64(60), "1").Expect("QUEUED")
+	bCmd := conn.Command(SetExpirationCommand, "b", int64(60), "2").Expect("QUEUED")
+	depCmd := conn.Command(AddToSetCommand, DependencyPrefix+"user", "a").Expect("QUEUED")
+	mockReverseDependencies(conn, "a", "user").Expect("QUEUED")
+	reverseExpireCmd := mockReverseExpire(conn, "a", time.Minute).Expect("QUEUED")
+	conn.Command(ExecuteCommand).Expect([]interface{}{"OK", "OK"})
+
+	err := SetManyExp(context.Background(), client, []BulkItem{
+		{Key: "a", Value: "1", Dependencies: []string{"user"}},
+		{Key: "b", Value: "2"},
+	}, time.Minute)
+	require.NoError(t, err)
+	assert.True(t, aCmd.Called)
+	assert.True(t, bCmd.Called)
+	assert.True(t, depCmd.Called)
+	assert.True(t, reverseExpireCmd.Called, "the reverse index expires with the key")
+	assert.Equal(t, 2, conn.Stats(multiCmd))
+
+	t.Run("no ttl stores without expiration", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		msetCmd := conn.Command(MultiSetCommand, "a", "1").Expect("OK")
+		setexCmd := conn.GenericCommand(SetExpirationCommand).Expect("QUEUED")
+
+		require.NoError(t, SetManyExp(context.Background(), client, []BulkItem{{Key: "a", Value: "1"}}, 0))
+		assert.True(t, msetCmd.Called)
+		assert.False(t, setexCmd.Called)
+	})
+
+	t.Run("ttl under a second is rejected", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		multiCmd := conn.Command(MultiCommand).Expect("OK")
+
+		for _, ttl := range []time.Duration{500 * time.Millisecond, -time.Second} {
+			err := SetManyExp(context.Background(), client, []BulkItem{{Key: "a", Value: "1"}}, ttl)
+			require.ErrorIs(t, err, ErrInvalidBulkTTL)
+		}
+		assert.False(t, multiCmd.Called)
+	})
+}
+
+// ExampleGetMany is an example of the method GetMany()
+func ExampleGetMany() {
+	// Load a mocked redis for testing/examples
+	client, conn := loadMockRedis()
+
+	// Close connections at end of request
+	defer client.CloseAll(conn)
+
+	// Mock the MGET reply (the second key is missing)
+	conn.Command(MultiGetCommand, testKey, "missing").Expect([]interface{}{[]byte(testStringValue), nil})
+
+	// Get the keys
+	values, _ := GetMany(context.Background(), client, testKey, "missing")
+	_, found := values["missing"]
+	fmt.Printf("got value: %s, found missing: %t", values[testKey], found)
+	// Output:got value: test-string-value, found missing: false
+}
@@ -19,18 +19,28 @@ const (
 	AddToSetCommand          string = "SADD"
 	AllKeysCommand           string = "*"
 	AuthCommand              string = "AUTH"
+	ClientCommand            string = "CLIENT"
 	DeleteCommand            string = "DEL"
+	DiscardCommand           string = "DISCARD"
 	DependencyPrefix         string = "depend:"
 	EvalCommand              string = "EVALSHA"
 	ExecuteCommand           string = "EXEC"
 	ExistsCommand            string = "EXISTS"
 	ExpireCommand            string = "EXPIRE"
+	FencingTokenPrefix       string = "fencing:"
 	FlushAllCommand          string = "FLUSHALL"
+	FunctionCallCommand      string = "FCALL"
+	FunctionCallReadOnlyCmd  string = "FCALL_RO"
+	FunctionCommand          string = "FUNCTION"
+	GenerationPrefix         string = "generation:"
 	GetCommand               string = "GET"
 	HashGetCommand           string = "HGET"
+	HashScanCommand          string = "HSCAN"
 	HashKeySetCommand        string = "HSET"
 	HashMapGetCommand        string = "HMGET"
 	HashMapSetCommand        string = "HMSET"
+	IncrementCommand         string = "INCR"
+	InfoCommand              string = "INFO"
 	IsMemberCommand          string = "SISMEMBER"
 	KeysCommand              string = "KEYS"
 	ListPushCommand          string = "RPUSH"
@@ -38,18 +48,27 @@ const (
 	LoadCommand              string = "LOAD"
 	MembersCommand           string = "SMEMBERS"
 	MultiCommand             string = "MULTI"
+	MultiGetCommand          string = "MGET"
+	MultiSetCommand          string = "MSET"
+	PExpireCommand           string = "PEXPIRE"
+	PersistCommand           string = "PERSIST"
 	PingCommand              string = "PING"
+	PTTLCommand              string = "PTTL"
 	RemoveMemberCommand      string = "SREM"
+	ReverseDependencyPrefix  string = "rdepend:"
+	ScanCommand              string = "SCAN"
 	ScriptCommand            string = "SCRIPT"
 	SelectCommand            string = "SELECT"
 	SetCommand               string = "SET"
 	SetExpirationCommand     string = "SETEX"
+	SetScanCommand           string = "SSCAN"
 	SortedSetAddCommand      string = "ZADD"
 	SortedSetCardCommand     string = "ZCARD"
 	SortedSetPopMinCommand   string = "ZPOPMIN"
 	SortedSetRangeByScoreCmd string = "ZRANGEBYSCORE"
 	SortedSetRangeCommand    string = "ZRANGE"
 	SortedSetRemCommand      string = "ZREM"
+	SortedSetScanCommand     string = "ZSCAN"
 	SortedSetScoreCommand    string = "ZSCORE"
 	StreamAddCommand         string = "XADD"
 	StreamLenCommand         string = "XLEN"
@@ -59,19 +78,26 @@ const (
 	SubscribeCommand         string = "SUBSCRIBE"
 	PSubscribeCommand        string = "PSUBSCRIBE"
 	UnsubscribeCommand       string = "UNSUBSCRIBE"
+	UnlinkCommand            string = "UNLINK"
+	UnwatchCommand           string = "UNWATCH"
+	WatchCommand             string = "WATCH"
 )
 
 // Get gets a key from redis in string format
+// Served from the local cache when enabled (see EnableLocalCache)
 // Creates a new connection and closes connection at end of function call
 //
 // Custom connections use method: GetRaw()
 func Get(ctx context.Context, client *Client, key string) (string, error) {
+	if value, ok := client.localGet(key); ok {
+		return value, nil
+	}
 	conn, err := client.GetConnectionWithContext(ctx)
 	if err != nil {
 		return "", err
 	}
 	defer client.CloseConnection(conn)
-	return GetRaw(conn, key)
+	return client.localLoad(conn, key)
 }
 
 // GetRaw gets a key from redis in string format
@@ -83,10 +109,18 @@ func GetRaw(conn redis.Conn, key string) (string, error) {
 }
 
 // GetBytes gets a key from redis formatted in bytes
+// Served from the local cache when enabled (see EnableLocalCache)
 // Creates a new connection and closes connection at end of function call
 //
 // Custom connections use method: GetBytesRaw()
 func GetBytes(ctx context.Context, client *Client, key string) ([]byte, error) {
+	if client.localCache() != nil {
+		value, err := Get(ctx, client, key)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(value), nil
+	}
 	conn, err := client.GetConnectionWithContext(ctx)
 	if err != nil {
 		return nil, err
@@ -132,24 +166,26 @@ func GetListRaw(conn redis.Conn, key string) (list []string, err error) {
 	return list, err
 }
 
-// SetList saves a slice as a redis list (appends)
+// SetList saves a slice as a redis list (appends) and links a reference to each dependency
+// for the entire list
 // Creates a new connection and closes connection at end of function call
 //
 // Custom connections use method: SetListRaw()
-func SetList(ctx context.Context, client *Client, key string, slice []string) error {
+func SetList(ctx context.Context, client *Client, key string, slice []string, dependencies ...string) error {
 	conn, err := client.GetConnectionWithContext(ctx)
 	if err != nil {
 		return err
 	}
 	defer client.CloseConnection(conn)
-	return SetListRaw(conn, key, slice)
+	return SetListRaw(conn, key, slice, dependencies...)
 }
 
-// SetListRaw saves a slice as a redis list (appends)
+// SetListRaw saves a slice as a redis list (appends) and links a reference to each dependency
+// for the entire list
 // Uses existing connection (does not close connection)
 //
 // Spec: https://redis.io/commands/rpush
-func SetListRaw(conn redis.Conn, key string, slice []string) (err error) {
+func SetListRaw(conn redis.Conn, key string, slice []string, dependencies ...string) error {
 	// Create the arguments
 	args := make([]interface{}, len(slice)+1)
 	args[0] = key
@@ -160,13 +196,18 @@ func SetListRaw(conn redis.Conn, key string, slice []string) (err error) {
 	}
 
 	// Fire the set command
-	_, err = conn.Do(ListPushCommand, args...)
-	return err
+	if _, err := conn.Do(ListPushCommand, args...); err != nil {
+		return err
+	}
+
+	// Link and return the error
+	return linkDependencies(conn, key, dependencies...)
 }
 
 // GetAllKeys returns a []string of keys
 // Creates a new connection and closes connection at end of function call
 //
+// Deprecated: KEYS blocks redis while it walks the whole keyspace, use Scan()
 // Custom connections use method: GetAllKeysRaw()
 func GetAllKeys(ctx context.Context, client *Client) (keys []string, err error) {
 	var conn redis.Conn
@@ -181,6 +222,7 @@ func GetAllKeys(ctx context.Context, client *Client) (keys []string, err error)
 // GetAllKeysRaw returns a []string of keys
 // Uses existing connection (does not close connection)
 //
+// Deprecated: KEYS blocks redis while it walks the whole keyspace, use ScanRaw()
 // Spec: https://redis.io/commands/keys
 func GetAllKeysRaw(conn redis.Conn) (keys []string, err error) {
 	return redis.Strings(conn.Do(KeysCommand, AllKeysCommand))
@@ -199,7 +241,10 @@ func Set(ctx context.Context, client *Client, key string,
 		return err
 	}
 	defer client.CloseConnection(conn)
-	return SetRaw(conn, key, value, dependencies...)
+	if err = SetRaw(conn, key, value, dependencies...); err != nil {
+		return err
+	}
+	return client.invalidateLocal(ctx, key)
 }
 
 // SetRaw will set the key in redis and keep a reference to each dependency
@@ -228,7 +273,10 @@ func SetExp(ctx context.Context, client *Client, key string, value interface{},
 		return err
 	}
 	defer client.CloseConnection(conn)
-	return SetExpRaw(conn, key, value, ttl, dependencies...)
+	if err = SetExpRaw(conn, key, value, ttl, dependencies...); err != nil {
+		return err
+	}
+	return client.invalidateLocal(ctx, key)
 }
 
 // SetExpRaw will set the key in redis and keep a reference to each dependency
@@ -243,7 +291,7 @@ func SetExpRaw(conn redis.Conn, key string, value interface{},
 		return err
 	}
 
-	return linkDependencies(conn, key, dependencies...)
+	return linkDependenciesExp(conn, key, ttl, dependencies...)
 }
 
 // Exists checks if a key is present or not
@@ -268,6 +316,7 @@ func ExistsRaw(conn redis.Conn, key string) (bool, error) {
 }
 
 // Expire sets the expiration for a given key
+// The key is dropped from the local cache (if enabled), so it expires there too
 // Creates a new connection and closes connection at end of function call
 //
 // Custom connections use method: ExpireRaw()
@@ -277,7 +326,10 @@ func Expire(ctx context.Context, client *Client, key string, duration time.Durat
 		return err
 	}
 	defer client.CloseConnection(conn)
-	return ExpireRaw(conn, key, duration)
+	if err = ExpireRaw(conn, key, duration); err != nil {
+		return err
+	}
+	return client.invalidateLocal(ctx, key)
 }
 
 // ExpireRaw sets the expiration for a given key
@@ -299,16 +351,21 @@ func DeleteWithoutDependency(ctx context.Context, client *Client, keys ...string
 		return 0, err
 	}
 	defer client.CloseConnection(conn)
-	return DeleteWithoutDependencyRaw(conn, keys...)
+	total, err := DeleteWithoutDependencyRaw(conn, keys...)
+	if err != nil {
+		return total, err
+	}
+	return total, client.invalidateLocal(ctx, keys...)
 }
 
 // DeleteWithoutDependencyRaw will remove keys without using dependency script
+// The reverse dependency set of each key (see DependenciesOf) is removed with it
 // Uses existing connection (does not close connection)
 //
 // Spec: https://redis.io/commands/del
 func DeleteWithoutDependencyRaw(conn redis.Conn, keys ...string) (total int, err error) {
 	for _, key := range keys {
-		if _, err = conn.Do(DeleteCommand, key); err != nil {
+		if _, err = conn.Do(DeleteCommand, key, ReverseDependencyPrefix+key); err != nil {
 			return total, err
 		}
 		total++
@@ -319,20 +376,29 @@ func DeleteWithoutDependencyRaw(conn redis.Conn, keys ...string) (total int, err
 
 // DestroyCache will flush the entire redis server
 // It only removes keys, not scripts
+// On a namespaced client (WithNamespace) only the keys of the namespace are deleted
 // Creates a new connection and closes connection at end of function call
 //
 // Custom connections use method: DestroyCacheRaw()
 func DestroyCache(ctx context.Context, client *Client) error {
+	if len(client.Namespace()) > 0 {
+		_, err := DeleteByPattern(ctx, client, "*")
+		return err
+	}
 	conn, err := client.GetConnectionWithContext(ctx)
 	if err != nil {
 		return err
 	}
 	defer client.CloseConnection(conn)
-	return DestroyCacheRaw(conn)
+	if err = DestroyCacheRaw(conn); err != nil {
+		return err
+	}
+	return client.flushLocal(ctx)
 }
 
 // DestroyCacheRaw will flush the entire redis server
 // It only removes keys, not scripts
+// Returns ErrNamespaceFlush on a namespaced connection, use DeleteByPatternRaw(conn, "*") instead
 // Uses existing connection (does not close connection)
 //
 // Spec: https://redis.io/commands/flushall
@@ -353,7 +419,10 @@ func SetToJSON(ctx context.Context, client *Client, keyName string, modelData in
 		return err
 	}
 	defer client.CloseConnection(conn)
-	return SetToJSONRaw(conn, keyName, modelData, ttl, dependencies...)
+	if err = SetToJSONRaw(conn, keyName, modelData, ttl, dependencies...); err != nil {
+		return err
+	}
+	return client.invalidateLocal(ctx, keyName)
 }
 
 // SetToJSONRaw stores the struct data (Struct->JSON) into redis under a key
@@ -375,6 +444,32 @@ func SetToJSONRaw(conn redis.Conn, keyName string, modelData interface{},
 	return err
 }
 
+// GetFromJSON gets the key from redis and decodes the JSON into modelData (JSON->Struct)
+// modelData must be a pointer
+// Creates a new connection and closes connection at end of function call
+//
+// Custom connections use method: GetFromJSONRaw()
+func GetFromJSON(ctx context.Context, client *Client, keyName string, modelData interface{}) error {
+	data, err := GetBytes(ctx, client, keyName)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, modelData)
+}
+
+// GetFromJSONRaw gets the key from redis and decodes the JSON into modelData (JSON->Struct)
+// modelData must be a pointer
+// Uses existing connection (does not close connection)
+//
+// Uses methods: GetBytesRaw()
+func GetFromJSONRaw(conn redis.Conn, keyName string, modelData interface{}) error {
+	data, err := GetBytesRaw(conn, keyName)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, modelData)
+}
+
 // Ping is a basic Ping->Pong method to determine connection
 // Creates a new connection and closes connection at end of function call
 //
@@ -38,6 +38,7 @@ func FuzzSetAndGet(f *testing.F) {
 		if dep2 != "" {
 			dependencies = append(dependencies, dep2)
 		}
+		mockReverseDependencies(conn, key, dependencies...).Expect(int64(len(dependencies)))
 
 		assert.NotPanics(t, func() {
 			err := Set(ctx, client, key, value, dependencies...)
@@ -280,7 +281,7 @@ func FuzzDeleteWithoutDependency(f *testing.F) {
 		defer client.Close()
 
 		for _, key := range keys {
-			conn.Command(DeleteCommand, key).Expect(int64(1))
+			conn.Command(DeleteCommand, key, ReverseDependencyPrefix+key).Expect(int64(1))
 		}
 
 		ctx := context.Background()
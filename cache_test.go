@@ -21,7 +21,7 @@ const (
 	testHashName             = "test-hash-name"
 	testIdleTimeout          = 240 * time.Second
 	testKey                  = "test-key-name"
-	testKillDependencyHash   = "a648f768f57e73e2497ccaa113d5ad9e731c5cd8"
+	testKillDependencyHash   = "62ffb9bb8e146d0048fe2c00b624e0fee9cffdb7"
 	testLocalConnectionURL   = "redis://localhost:6379"
 	testMaxActiveConnections = 0
 	testMaxConnLifetime      = 60 * time.Second
@@ -151,6 +151,7 @@ func TestSet(t *testing.T) {
 					for _, dep := range test.dependencies {
 						commands = append(commands, conn.Command(AddToSetCommand, DependencyPrefix+dep, test.key))
 					}
+					commands = append(commands, mockReverseDependencies(conn, test.key, test.dependencies...))
 					commands = append(commands, conn.Command(ExecuteCommand))
 
 					err := Set(ctx, client, test.key, test.value, test.dependencies...)
@@ -316,6 +317,8 @@ func TestSetExp(t *testing.T) {
 					for _, dep := range test.dependencies {
 						commands = append(commands, conn.Command(AddToSetCommand, DependencyPrefix+dep, test.key))
 					}
+					commands = append(commands, mockReverseDependencies(conn, test.key, test.dependencies...))
+					commands = append(commands, mockReverseExpire(conn, test.key, test.expiration))
 					commands = append(commands, conn.Command(ExecuteCommand))
 
 					err := SetExp(context.Background(), client, test.key, test.value, test.expiration, test.dependencies...)
@@ -366,6 +369,12 @@ func TestSetExp(t *testing.T) {
 		require.Error(t, err)
 		assert.Empty(t, testVal)
 		assert.Equal(t, redis.ErrNil, err)
+
+		// The reverse dependency set expired with it
+		var found bool
+		found, err = ExistsRaw(conn, ReverseDependencyPrefix+testKey)
+		require.NoError(t, err)
+		assert.False(t, found)
 	})
 
 	t.Run("set exp cmd, trigger context err", func(t *testing.T) {
@@ -677,7 +686,7 @@ func TestGetAllKeys(t *testing.T) {
 		var keys []string
 		keys, err = GetAllKeys(context.Background(), client)
 		require.NoError(t, err)
-		assert.Len(t, keys, 2)
+		assert.Len(t, keys, 3) // key, dependency set and reverse index
 	})
 
 	t.Run("get all keys command using real redis (new relic)", func(t *testing.T) {
@@ -703,7 +712,7 @@ func TestGetAllKeys(t *testing.T) {
 		var keys []string
 		keys, err = GetAllKeys(context.Background(), client)
 		require.NoError(t, err)
-		assert.Len(t, keys, 2)
+		assert.Len(t, keys, 3) // key, dependency set and reverse index
 	})
 
 	t.Run("get all keys cmd, trigger context err", func(t *testing.T) {
@@ -1171,6 +1180,21 @@ func TestSetList(t *testing.T) {
 		}
 	})
 
+	t.Run("set list with dependencies using mocked redis", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		pushCmd := conn.Command(ListPushCommand, testKey, "a", "b")
+		commands := mockLinkDependencies(conn, testKey, testDependantKey)
+
+		err := SetList(context.Background(), client, testKey, []string{"a", "b"}, testDependantKey)
+		require.NoError(t, err)
+		assert.True(t, pushCmd.Called)
+		for _, c := range commands {
+			assert.True(t, c.Called)
+		}
+	})
+
 	t.Run("set list command using real redis", func(t *testing.T) {
 		if testing.Short() {
 			t.Skip("skipping live local redis tests")
@@ -1265,7 +1289,7 @@ func TestDeleteWithoutDependency(t *testing.T) {
 				// The main command to test
 				commands := make([]*redigomock.Cmd, 0, len(test.keys))
 				for _, key := range test.keys {
-					cmd := conn.Command(DeleteCommand, key)
+					cmd := conn.Command(DeleteCommand, key, ReverseDependencyPrefix+key)
 					commands = append(commands, cmd)
 				}
 
@@ -1310,6 +1334,12 @@ func TestDeleteWithoutDependency(t *testing.T) {
 		require.Error(t, err)
 		assert.Equal(t, redis.ErrNil, err)
 		assert.Empty(t, val)
+
+		// The reverse dependency set is gone with the key
+		var found bool
+		found, err = ExistsRaw(conn, ReverseDependencyPrefix+testKey)
+		require.NoError(t, err)
+		assert.False(t, found)
 	})
 
 	t.Run("expire cmd, trigger context err", func(t *testing.T) {
@@ -1409,6 +1439,7 @@ func TestSetToJSON(t *testing.T) {
 					for _, dep := range test.dependencies {
 						commands = append(commands, conn.Command(AddToSetCommand, DependencyPrefix+dep, test.key))
 					}
+					commands = append(commands, mockReverseDependencies(conn, test.key, test.dependencies...))
 					commands = append(commands, conn.Command(ExecuteCommand))
 
 					err = SetToJSONRaw(conn, test.key, test.modelData, 0, test.dependencies...)
@@ -1491,6 +1522,8 @@ func TestSetToJSON(t *testing.T) {
 					for _, dep := range test.dependencies {
 						commands = append(commands, conn.Command(AddToSetCommand, DependencyPrefix+dep, test.key))
 					}
+					commands = append(commands, mockReverseDependencies(conn, test.key, test.dependencies...))
+					commands = append(commands, mockReverseExpire(conn, test.key, test.expiration))
 					commands = append(commands, conn.Command(ExecuteCommand))
 
 					err = SetToJSONRaw(conn, test.key, test.modelData, test.expiration, test.dependencies...)
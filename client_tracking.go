@@ -0,0 +1,226 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// TrackingInvalidationChannel is the channel redis uses to deliver client tracking invalidations (RESP2)
+const TrackingInvalidationChannel = "__redis__:invalidate"
+
+// trackingResetTimeout bounds how long turning off tracking on the idle pooled connections may wait
+const trackingResetTimeout = time.Second
+
+var (
+	// ErrTrackingPrefixes is returned when key prefixes are given without broadcast mode
+	ErrTrackingPrefixes = errors.New("client tracking prefixes require broadcast mode")
+
+	// errUnexpectedTrackingReply is returned when the invalidation connection receives an unknown reply
+	errUnexpectedTrackingReply = errors.New("unexpected client tracking reply")
+)
+
+// TrackingMode selects which keys redis sends invalidations for
+type TrackingMode int
+
+// Client tracking modes
+const (
+	TrackingDefault   TrackingMode = iota // Invalidate keys after this client read them (tracked per read)
+	TrackingBroadcast                     // Invalidate every key matching the prefixes (BCAST)
+)
+
+// ClientTrackingOptions configures server-assisted client-side caching
+type ClientTrackingOptions struct {
+	Mode       TrackingMode     // Tracking mode (default: TrackingDefault)
+	Prefixes   []string         // Key prefixes to receive invalidations for (broadcast mode only, empty = all keys)
+	MaxEntries int              // Maximum number of entries held in memory (default: 10,000)
+	TTL        time.Duration    // Maximum age of a local entry (0 = until evicted or invalidated)
+	Policy     LocalCachePolicy // Eviction policy (default: LRU)
+}
+
+// EnableClientTracking puts a bounded in-memory (L1) cache in front of Get(), GetBytes() and
+// the methods built on them, using redis CLIENT TRACKING (redis 6+) for invalidation.
+// Unlike EnableLocalCache(), redis itself notifies us when a cached key changes, no matter
+// which client (or Raw method) modified it.
+//
+// Invalidations are received on a dedicated connection subscribed to __redis__:invalidate.
+// In TrackingDefault mode each local cache miss opts the read into tracking (pipelined with
+// the GET, no extra round trip). In TrackingBroadcast mode redis sends invalidations for
+// every key matching the prefixes, so nothing extra is sent on reads.
+//
+// While the invalidation connection is down reads go straight to redis. On reconnect
+// tracking is re-established and the whole local cache is flushed. Tracking is turned off
+// on the idle pooled connections on reconnect and by DisableClientTracking().
+//
+// Only one of EnableLocalCache() or EnableClientTracking() can be active per client.
+//
+// Spec: https://redis.io/docs/latest/develop/reference/client-side-caching/
+func (c *Client) EnableClientTracking(ctx context.Context, opts ClientTrackingOptions) error {
+	if len(opts.Prefixes) > 0 && opts.Mode != TrackingBroadcast {
+		return ErrTrackingPrefixes
+	}
+
+	lc := newLocalCache(LocalCacheOptions{
+		MaxEntries: opts.MaxEntries,
+		TTL:        opts.TTL,
+		Policy:     opts.Policy,
+	})
+	// Broadcast prefixes match the keys as stored in redis (with the namespace)
+	prefixes := c.physicalKeys(opts.Prefixes...)
+	if len(prefixes) == 0 && len(c.namespace) > 0 && opts.Mode == TrackingBroadcast {
+		prefixes = []string{c.namespace}
+	}
+	lc.tracker = &clientTracker{client: c.root(), mode: opts.Mode, prefixes: prefixes}
+
+	return c.root().enableLocal(ctx, lc, TrackingInvalidationChannel, subscriptionHooks{
+		connect: lc.tracker.connect,
+		receive: receiveInvalidation,
+	})
+}
+
+// DisableClientTracking stops client tracking and drops all local entries
+// Tracking is turned off on the idle pooled connections, connections in use at the time
+// keep it, but without CLIENT CACHING yes (OPTIN) their reads are not tracked
+func (c *Client) DisableClientTracking() {
+	c.DisableLocalCache()
+}
+
+// clientTracker keeps the CLIENT TRACKING state of the invalidation connection
+type clientTracker struct {
+	client     *Client // root client, owner of the pool
+	mode       TrackingMode
+	prefixes   []string
+	redirectID atomic.Int64 // CLIENT ID of the invalidation connection
+}
+
+// connect prepares a new invalidation connection before it subscribes
+// Broadcast tracking is registered on the invalidation connection itself (redirecting to
+// itself). On reconnect the pooled connections still redirect to the old connection, so
+// their tracking is turned off first.
+func (t *clientTracker) connect(conn redis.Conn) error {
+	if t.redirectID.Load() != 0 {
+		untrackIdleConnections(t.client)
+	}
+
+	id, err := redis.Int64(conn.Do(ClientCommand, "ID"))
+	if err != nil {
+		return err
+	}
+
+	if t.mode == TrackingBroadcast {
+		args := []interface{}{"TRACKING", "ON", "REDIRECT", id, "BCAST"}
+		for _, prefix := range t.prefixes {
+			args = append(args, "PREFIX", prefix)
+		}
+
+		// Reset any tracking left on the pooled connection (switching modes is an error)
+		if err = conn.Send(ClientCommand, "TRACKING", "OFF"); err != nil {
+			return err
+		}
+		if _, err = conn.Do(ClientCommand, args...); err != nil {
+			return err
+		}
+	}
+
+	t.redirectID.Store(id)
+	return nil
+}
+
+// untrackIdleConnections turns off client tracking on the idle pooled connections
+// Reads opt their connection into tracking, and a released invalidation connection keeps its
+// broadcast tracking. Every idle connection is borrowed before any is returned, so each one is
+// reset once (CLIENT TRACKING OFF is pipelined on all of them). Errors are ignored, a connection
+// that fails is dropped by the pool.
+//
+// Spec: https://redis.io/commands/client-tracking
+func untrackIdleConnections(client *Client) {
+	client.mu.RLock()
+	pool := client.Pool
+	client.mu.RUnlock()
+	if pool == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), trackingResetTimeout)
+	defer cancel()
+	conns := make([]redis.Conn, 0, pool.IdleCount())
+	for range cap(conns) {
+		conn, err := pool.GetContext(ctx)
+		if err != nil {
+			break
+		}
+		conns = append(conns, conn)
+		_ = conn.Send(ClientCommand, "TRACKING", "OFF")
+		_ = conn.Flush()
+	}
+	for _, conn := range conns {
+		_, _ = conn.Receive()
+		_ = conn.Close()
+	}
+}
+
+// track opts the next command on conn into tracking (default mode only)
+// The commands are only queued, so they go out in the same round trip as the read that follows.
+func (t *clientTracker) track(conn redis.Conn) error {
+	if t.mode != TrackingDefault {
+		return nil
+	}
+	if err := conn.Send(ClientCommand, "TRACKING", "ON", "REDIRECT", t.redirectID.Load(), "OPTIN"); err != nil {
+		return err
+	}
+	return conn.Send(ClientCommand, "CACHING", "YES")
+}
+
+// receiveInvalidation reads the next notification from the invalidation connection
+// Invalidation payloads are an array of keys (or nil when the database was flushed), which
+// PubSubConn.Receive() cannot scan. They are converted to the local cache broadcast payload,
+// so the same localCache.apply() handles both invalidation sources.
+func receiveInvalidation(conn redis.Conn) interface{} {
+	reply, err := redis.Values(conn.Receive())
+	if err != nil {
+		return err
+	}
+	if len(reply) != 3 {
+		return fmt.Errorf("%w: %d elements", errUnexpectedTrackingReply, len(reply))
+	}
+
+	var kind, channel string
+	if kind, err = redis.String(reply[0], nil); err != nil {
+		return err
+	}
+	if channel, err = redis.String(reply[1], nil); err != nil {
+		return err
+	}
+
+	switch kind {
+	case "message":
+		var inv localInvalidation
+		switch payload := reply[2].(type) {
+		case nil:
+			inv.All = true
+		case []byte:
+			inv.Keys = []string{string(payload)}
+		default:
+			if inv.Keys, err = redis.Strings(payload, nil); err != nil {
+				return err
+			}
+		}
+		var data []byte
+		if data, err = json.Marshal(inv); err != nil {
+			return err
+		}
+		return redis.Message{Channel: channel, Data: data}
+	case "subscribe", "unsubscribe":
+		var count int
+		if count, err = redis.Int(reply[2], nil); err != nil {
+			return err
+		}
+		return redis.Subscription{Kind: kind, Channel: channel, Count: count}
+	}
+	return fmt.Errorf("%w: %s", errUnexpectedTrackingReply, kind)
+}
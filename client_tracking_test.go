@@ -0,0 +1,307 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/rafaeljusto/redigomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestEnableClientTracking tests the method EnableClientTracking()
+func TestEnableClientTracking(t *testing.T) {
+	t.Run("prefixes require broadcast mode", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		err := client.EnableClientTracking(context.Background(), ClientTrackingOptions{Prefixes: []string{"user:"}})
+		require.ErrorIs(t, err, ErrTrackingPrefixes)
+		assert.Nil(t, client.localCache())
+	})
+
+	t.Run("client id error", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.Command(ClientCommand, "ID").ExpectError(errTestLoader)
+
+		err := client.EnableClientTracking(context.Background(), ClientTrackingOptions{})
+		require.ErrorIs(t, err, errTestLoader)
+		assert.Nil(t, client.localCache())
+	})
+
+	t.Run("default and broadcast mode using real redis", func(t *testing.T) {
+		if testing.Short() {
+			t.Skip("skipping live local redis tests")
+		}
+
+		for _, opts := range []ClientTrackingOptions{
+			{Mode: TrackingDefault},
+			{Mode: TrackingBroadcast, Prefixes: []string{testKey}},
+		} {
+			writer, conn, err := loadRealRedis(t)
+			require.NoError(t, err)
+			require.NoError(t, clearRealRedis(conn))
+
+			reader, readerConn, err := loadRealRedis(t)
+			require.NoError(t, err)
+
+			require.NoError(t, reader.EnableClientTracking(context.Background(), opts))
+
+			// Written with a Raw method, which the broadcast local cache would not see
+			require.NoError(t, SetRaw(conn, testKey, "first"))
+			val, err := Get(context.Background(), reader, testKey)
+			require.NoError(t, err)
+			assert.Equal(t, "first", val)
+
+			require.NoError(t, SetRaw(conn, testKey, "second"))
+			assert.Eventually(t, func() bool {
+				val, err = Get(context.Background(), reader, testKey)
+				return err == nil && val == "second"
+			}, 2*time.Second, 10*time.Millisecond)
+
+			reader.DisableClientTracking()
+			reader.CloseAll(readerConn)
+			writer.CloseAll(conn)
+		}
+	})
+}
+
+// TestClientTracker tests the CLIENT TRACKING commands sent by the tracker
+func TestClientTracker(t *testing.T) {
+	t.Run("default mode connect only reads the client id", func(t *testing.T) {
+		conn := redigomock.NewConn()
+		idCmd := conn.Command(ClientCommand, "ID").Expect(int64(42))
+		trackCmd := conn.GenericCommand(ClientCommand)
+
+		tracker := &clientTracker{mode: TrackingDefault}
+		require.NoError(t, tracker.connect(conn))
+		assert.True(t, idCmd.Called)
+		assert.False(t, trackCmd.Called)
+		assert.Equal(t, int64(42), tracker.redirectID.Load())
+	})
+
+	t.Run("broadcast mode connect registers prefixes", func(t *testing.T) {
+		conn := redigomock.NewConn()
+		conn.Command(ClientCommand, "ID").Expect(int64(7))
+		offCmd := conn.Command(ClientCommand, "TRACKING", "OFF").Expect("OK")
+		onCmd := conn.Command(ClientCommand, "TRACKING", "ON", "REDIRECT", int64(7), "BCAST",
+			"PREFIX", "user:", "PREFIX", "post:").Expect("OK")
+
+		tracker := &clientTracker{mode: TrackingBroadcast, prefixes: []string{"user:", "post:"}}
+		require.NoError(t, tracker.connect(conn))
+		assert.True(t, offCmd.Called)
+		assert.True(t, onCmd.Called)
+		assert.Equal(t, int64(7), tracker.redirectID.Load())
+	})
+
+	t.Run("reconnect turns off tracking on idle connections", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+		idle, err := client.GetConnectionWithContext(context.Background())
+		require.NoError(t, err)
+		client.CloseConnection(idle)
+
+		conn.Command(ClientCommand, "ID").Expect(int64(8))
+		offCmd := conn.Command(ClientCommand, "TRACKING", "OFF").Expect("OK")
+
+		tracker := &clientTracker{client: client, mode: TrackingDefault}
+		require.NoError(t, tracker.connect(conn))
+		assert.False(t, offCmd.Called, "nothing to reset on the first connect")
+
+		invalidation := redigomock.NewConn()
+		invalidation.Command(ClientCommand, "ID").Expect(int64(9))
+		require.NoError(t, tracker.connect(invalidation))
+		assert.Equal(t, 1, conn.Stats(offCmd))
+		assert.Equal(t, int64(9), tracker.redirectID.Load())
+	})
+
+	t.Run("disable turns off tracking on idle connections", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+		lc := loadMockLocalCache(client, LocalCacheOptions{})
+		lc.tracker = &clientTracker{client: client, mode: TrackingDefault}
+		idle, err := client.GetConnectionWithContext(context.Background())
+		require.NoError(t, err)
+		client.CloseConnection(idle)
+
+		offCmd := conn.Command(ClientCommand, "TRACKING", "OFF").Expect("OK")
+
+		client.DisableClientTracking()
+		assert.Nil(t, client.localCache())
+		assert.Equal(t, 1, conn.Stats(offCmd))
+	})
+
+	t.Run("closing the client does not reset idle connections", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		lc := loadMockLocalCache(client, LocalCacheOptions{})
+		lc.tracker = &clientTracker{client: client, mode: TrackingDefault}
+		idle, err := client.GetConnectionWithContext(context.Background())
+		require.NoError(t, err)
+		client.CloseConnection(idle)
+
+		offCmd := conn.Command(ClientCommand, "TRACKING", "OFF").Expect("OK")
+
+		client.CloseAll(conn)
+		assert.False(t, offCmd.Called)
+	})
+
+	t.Run("broadcast mode connect error", func(t *testing.T) {
+		conn := redigomock.NewConn()
+		conn.Command(ClientCommand, "ID").Expect(int64(7))
+		conn.Command(ClientCommand, "TRACKING", "OFF").Expect("OK")
+		conn.Command(ClientCommand, "TRACKING", "ON", "REDIRECT", int64(7), "BCAST").ExpectError(errTestLoader)
+
+		tracker := &clientTracker{mode: TrackingBroadcast}
+		require.ErrorIs(t, tracker.connect(conn), errTestLoader)
+		assert.Equal(t, int64(0), tracker.redirectID.Load())
+	})
+
+	t.Run("default mode get opts into tracking", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+		lc := loadMockLocalCache(client, LocalCacheOptions{})
+		lc.channel = ""
+		lc.tracker = &clientTracker{mode: TrackingDefault}
+		lc.tracker.redirectID.Store(42)
+
+		trackCmd := conn.Command(ClientCommand, "TRACKING", "ON", "REDIRECT", int64(42), "OPTIN").Expect("OK")
+		cachingCmd := conn.Command(ClientCommand, "CACHING", "YES").Expect("OK")
+		getCmd := conn.Command(GetCommand, testKey).Expect(testStringValue)
+		conn.Command(PTTLCommand, testKey).Expect(ttlPersistent)
+
+		for i := 0; i < 2; i++ {
+			val, err := Get(context.Background(), client, testKey)
+			require.NoError(t, err)
+			assert.Equal(t, testStringValue, val)
+		}
+		assert.Equal(t, 1, conn.Stats(trackCmd))
+		assert.Equal(t, 1, conn.Stats(cachingCmd))
+		assert.Equal(t, 1, conn.Stats(getCmd))
+	})
+
+	t.Run("writes do not broadcast", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+		lc := loadMockLocalCache(client, LocalCacheOptions{})
+		lc.channel = ""
+		lc.tracker = &clientTracker{mode: TrackingBroadcast}
+		lc.store.set(testKey, "old", lc.store.currentEpoch())
+
+		conn.Command(SetCommand, testKey, testStringValue)
+		pubCmd := conn.GenericCommand(PublishCommand).Expect(int64(1))
+
+		require.NoError(t, Set(context.Background(), client, testKey, testStringValue))
+		assert.False(t, pubCmd.Called)
+		_, ok := lc.store.get(testKey)
+		assert.False(t, ok)
+	})
+
+	t.Run("offline cache reads from redis", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+		lc := loadMockLocalCache(client, LocalCacheOptions{})
+		lc.store.set(testKey, "old", lc.store.currentEpoch())
+
+		getCmd := conn.Command(GetCommand, testKey).Expect(testStringValue)
+		conn.Command(PTTLCommand, testKey).Expect(ttlPersistent)
+
+		lc.offline()
+		for i := 0; i < 2; i++ {
+			val, err := Get(context.Background(), client, testKey)
+			require.NoError(t, err)
+			assert.Equal(t, testStringValue, val)
+		}
+		assert.Equal(t, 2, conn.Stats(getCmd))
+
+		lc.resync()
+		_, err := Get(context.Background(), client, testKey)
+		require.NoError(t, err)
+		_, err = Get(context.Background(), client, testKey)
+		require.NoError(t, err)
+		assert.Equal(t, 3, conn.Stats(getCmd))
+	})
+}
+
+// TestReceiveInvalidation tests decoding RESP2 client tracking replies
+func TestReceiveInvalidation(t *testing.T) {
+	tests := []struct {
+		name     string
+		reply    interface{}
+		expected interface{}
+	}{
+		{
+			"keys",
+			[]interface{}{[]byte("message"), []byte(TrackingInvalidationChannel), []interface{}{[]byte("a"), []byte("b")}},
+			redis.Message{Channel: TrackingInvalidationChannel, Data: []byte(`{"keys":["a","b"]}`)},
+		},
+		{
+			"single key",
+			[]interface{}{[]byte("message"), []byte(TrackingInvalidationChannel), []byte("a")},
+			redis.Message{Channel: TrackingInvalidationChannel, Data: []byte(`{"keys":["a"]}`)},
+		},
+		{
+			"flush",
+			[]interface{}{[]byte("message"), []byte(TrackingInvalidationChannel), nil},
+			redis.Message{Channel: TrackingInvalidationChannel, Data: []byte(`{"all":true}`)},
+		},
+		{
+			"subscribe",
+			[]interface{}{[]byte("subscribe"), []byte(TrackingInvalidationChannel), int64(1)},
+			redis.Subscription{Kind: "subscribe", Channel: TrackingInvalidationChannel, Count: 1},
+		},
+		{
+			"unsubscribe",
+			[]interface{}{[]byte("unsubscribe"), []byte(TrackingInvalidationChannel), int64(0)},
+			redis.Subscription{Kind: "unsubscribe", Channel: TrackingInvalidationChannel, Count: 0},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conn := redigomock.NewConn()
+			conn.AddSubscriptionMessage(test.reply)
+			assert.Equal(t, test.expected, receiveInvalidation(conn))
+		})
+	}
+
+	t.Run("unexpected replies are errors", func(t *testing.T) {
+		for _, reply := range []interface{}{
+			[]interface{}{[]byte("pong"), []byte("")},
+			[]interface{}{[]byte("pmessage"), []byte("p"), []byte("c")},
+			"not an array",
+		} {
+			conn := redigomock.NewConn()
+			conn.AddSubscriptionMessage(reply)
+			_, isErr := receiveInvalidation(conn).(error)
+			assert.True(t, isErr)
+		}
+	})
+
+	t.Run("connection error", func(t *testing.T) {
+		conn := redigomock.NewConn()
+		_, isErr := receiveInvalidation(conn).(error)
+		assert.True(t, isErr)
+	})
+
+	t.Run("payload applies to local cache", func(t *testing.T) {
+		conn := redigomock.NewConn()
+		conn.AddSubscriptionMessage([]interface{}{
+			[]byte("message"), []byte(TrackingInvalidationChannel), []interface{}{[]byte("a")},
+		})
+
+		lc := newLocalCache(LocalCacheOptions{})
+		lc.store.set("a", "1", lc.store.currentEpoch())
+		lc.store.set("b", "2", lc.store.currentEpoch())
+
+		msg, ok := receiveInvalidation(conn).(redis.Message)
+		require.True(t, ok)
+		lc.apply(msg.Data)
+		_, ok = lc.store.get("a")
+		assert.False(t, ok)
+		_, ok = lc.store.get("b")
+		assert.True(t, ok)
+	})
+}
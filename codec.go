@@ -0,0 +1,158 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// ErrCodecUnsupportedType is returned when a codec cannot handle the given value type
+var ErrCodecUnsupportedType = errors.New("codec does not support this type")
+
+// Codec converts values to and from the bytes stored in redis
+type Codec interface {
+	Marshal(value interface{}) ([]byte, error)
+	Unmarshal(data []byte, value interface{}) error
+}
+
+// JSONCodec encodes values as JSON (same format used by SetToJSON)
+type JSONCodec struct{}
+
+// Marshal encodes the value as JSON
+func (JSONCodec) Marshal(value interface{}) ([]byte, error) {
+	return json.Marshal(value)
+}
+
+// Unmarshal decodes JSON data into value (must be a pointer)
+func (JSONCodec) Unmarshal(data []byte, value interface{}) error {
+	return json.Unmarshal(data, value)
+}
+
+// GobCodec encodes values using encoding/gob
+// Interface values must be registered with gob.Register() before use
+type GobCodec struct{}
+
+// Marshal encodes the value using gob
+func (GobCodec) Marshal(value interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal decodes gob data into value (must be a pointer)
+func (GobCodec) Unmarshal(data []byte, value interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(value)
+}
+
+// RawCodec stores strings and byte slices as-is (no encoding)
+// Only string, []byte and pointers to them are supported
+type RawCodec struct{}
+
+// Marshal returns the raw bytes of a string or []byte value
+func (RawCodec) Marshal(value interface{}) ([]byte, error) {
+	switch v := value.(type) {
+	case []byte:
+		return v, nil
+	case string:
+		return []byte(v), nil
+	case *[]byte:
+		return *v, nil
+	case *string:
+		return []byte(*v), nil
+	}
+	return nil, fmt.Errorf("%w: %T", ErrCodecUnsupportedType, value)
+}
+
+// Unmarshal copies the raw bytes into a *string or *[]byte
+func (RawCodec) Unmarshal(data []byte, value interface{}) error {
+	switch v := value.(type) {
+	case *[]byte:
+		*v = append((*v)[:0], data...)
+		return nil
+	case *string:
+		*v = string(data)
+		return nil
+	}
+	return fmt.Errorf("%w: %T", ErrCodecUnsupportedType, value)
+}
+
+// codecOrDefault returns JSONCodec when no codec is given
+func codecOrDefault(codec Codec) Codec {
+	if codec == nil {
+		return JSONCodec{}
+	}
+	return codec
+}
+
+// GetAs gets a key from redis and decodes it into T using the codec
+// A nil codec defaults to JSONCodec
+// Creates a new connection and closes connection at end of function call
+//
+// Custom connections use method: GetAsRaw()
+func GetAs[T any](ctx context.Context, client *Client, codec Codec, key string) (value T, err error) {
+	var data []byte
+	if data, err = GetBytes(ctx, client, key); err != nil {
+		return value, err
+	}
+	err = codecOrDefault(codec).Unmarshal(data, &value)
+	return value, err
+}
+
+// GetAsRaw gets a key from redis and decodes it into T using the codec
+// A nil codec defaults to JSONCodec
+// Uses existing connection (does not close connection)
+//
+// Uses methods: GetBytesRaw()
+func GetAsRaw[T any](conn redis.Conn, codec Codec, key string) (value T, err error) {
+	var data []byte
+	if data, err = GetBytesRaw(conn, key); err != nil {
+		return value, err
+	}
+	err = codecOrDefault(codec).Unmarshal(data, &value)
+	return value, err
+}
+
+// SetAs encodes the value using the codec and stores it under the key, linking each dependency
+// A nil codec defaults to JSONCodec, a ttl of 0 stores the key without expiration
+// Creates a new connection and closes connection at end of function call
+//
+// Custom connections use method: SetAsRaw()
+func SetAs[T any](ctx context.Context, client *Client, codec Codec, key string, value T,
+	ttl time.Duration, dependencies ...string,
+) error {
+	conn, err := client.GetConnectionWithContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.CloseConnection(conn)
+	if err = SetAsRaw(conn, codec, key, value, ttl, dependencies...); err != nil {
+		return err
+	}
+	return client.invalidateLocal(ctx, key)
+}
+
+// SetAsRaw encodes the value using the codec and stores it under the key, linking each dependency
+// A nil codec defaults to JSONCodec, a ttl of 0 stores the key without expiration
+// Uses existing connection (does not close connection)
+//
+// Uses methods: SetExpRaw() or SetRaw()
+func SetAsRaw[T any](conn redis.Conn, codec Codec, key string, value T,
+	ttl time.Duration, dependencies ...string,
+) error {
+	data, err := codecOrDefault(codec).Marshal(value)
+	if err != nil {
+		return err
+	}
+	if ttl > 0 {
+		return SetExpRaw(conn, key, data, ttl, dependencies...)
+	}
+	return SetRaw(conn, key, data, dependencies...)
+}
@@ -0,0 +1,290 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testCodecModel is a simple model used for codec tests
+type testCodecModel struct {
+	Name  string  `json:"name"`
+	Count int     `json:"count"`
+	Score float64 `json:"score"`
+}
+
+// TestCodecs tests the in-box codecs
+func TestCodecs(t *testing.T) {
+	model := testCodecModel{Name: "test-name", Count: 3, Score: 1.5}
+
+	t.Run("json codec round trip", func(t *testing.T) {
+		data, err := JSONCodec{}.Marshal(model)
+		require.NoError(t, err)
+
+		var decoded testCodecModel
+		err = JSONCodec{}.Unmarshal(data, &decoded)
+		require.NoError(t, err)
+		assert.Equal(t, model, decoded)
+	})
+
+	t.Run("gob codec round trip", func(t *testing.T) {
+		data, err := GobCodec{}.Marshal(model)
+		require.NoError(t, err)
+
+		var decoded testCodecModel
+		err = GobCodec{}.Unmarshal(data, &decoded)
+		require.NoError(t, err)
+		assert.Equal(t, model, decoded)
+	})
+
+	t.Run("gob codec invalid data", func(t *testing.T) {
+		var decoded testCodecModel
+		err := GobCodec{}.Unmarshal([]byte("not-gob"), &decoded)
+		require.Error(t, err)
+	})
+
+	t.Run("raw codec supported types", func(t *testing.T) {
+		str := testStringValue
+		raw := []byte(testStringValue)
+
+		tests := []struct {
+			name  string
+			value interface{}
+		}{
+			{"string", testStringValue},
+			{"bytes", []byte(testStringValue)},
+			{"string pointer", &str},
+			{"bytes pointer", &raw},
+		}
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				data, err := RawCodec{}.Marshal(test.value)
+				require.NoError(t, err)
+				assert.Equal(t, []byte(testStringValue), data)
+			})
+		}
+
+		var decodedString string
+		require.NoError(t, RawCodec{}.Unmarshal([]byte(testStringValue), &decodedString))
+		assert.Equal(t, testStringValue, decodedString)
+
+		var decodedBytes []byte
+		require.NoError(t, RawCodec{}.Unmarshal([]byte(testStringValue), &decodedBytes))
+		assert.Equal(t, []byte(testStringValue), decodedBytes)
+	})
+
+	t.Run("raw codec unsupported types", func(t *testing.T) {
+		_, err := RawCodec{}.Marshal(model)
+		require.ErrorIs(t, err, ErrCodecUnsupportedType)
+
+		var decoded int
+		err = RawCodec{}.Unmarshal([]byte("1"), &decoded)
+		require.ErrorIs(t, err, ErrCodecUnsupportedType)
+	})
+}
+
+// TestGetAs tests the method GetAs()
+func TestGetAs(t *testing.T) {
+	t.Run("get as using mocked redis", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		model := testCodecModel{Name: "test-name", Count: 3, Score: 1.5}
+		data, err := json.Marshal(model)
+		require.NoError(t, err)
+
+		getCmd := conn.Command(GetCommand, testKey).Expect(data)
+
+		var decoded testCodecModel
+		decoded, err = GetAs[testCodecModel](context.Background(), client, nil, testKey)
+		require.NoError(t, err)
+		assert.True(t, getCmd.Called)
+		assert.Equal(t, model, decoded)
+	})
+
+	t.Run("get as with raw codec", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.Command(GetCommand, testKey).Expect(testStringValue)
+
+		val, err := GetAs[string](context.Background(), client, RawCodec{}, testKey)
+		require.NoError(t, err)
+		assert.Equal(t, testStringValue, val)
+	})
+
+	t.Run("missing key", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.Command(GetCommand, testKey).Expect(nil)
+
+		_, err := GetAs[testCodecModel](context.Background(), client, JSONCodec{}, testKey)
+		require.ErrorIs(t, err, redis.ErrNil)
+	})
+
+	t.Run("invalid data", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.Command(GetCommand, testKey).Expect("{invalid")
+
+		_, err := GetAs[testCodecModel](context.Background(), client, JSONCodec{}, testKey)
+		require.Error(t, err)
+	})
+
+	t.Run("closed client", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		client.CloseAll(conn)
+
+		_, err := GetAs[string](context.Background(), client, RawCodec{}, testKey)
+		require.ErrorIs(t, err, ErrRedisPoolNil)
+	})
+}
+
+// TestSetAs tests the method SetAs()
+func TestSetAs(t *testing.T) {
+	model := testCodecModel{Name: "test-name", Count: 3, Score: 1.5}
+	data, err := json.Marshal(model)
+	require.NoError(t, err)
+
+	t.Run("set as with dependencies using mocked redis", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		setCmd := conn.Command(SetCommand, testKey, data)
+		multiCmd := conn.Command(MultiCommand)
+		addCmd := conn.Command(AddToSetCommand, DependencyPrefix+testDependantKey, testKey)
+		mockReverseDependencies(conn, testKey, testDependantKey)
+		execCmd := conn.Command(ExecuteCommand)
+
+		err = SetAs(context.Background(), client, nil, testKey, model, 0, testDependantKey)
+		require.NoError(t, err)
+		assert.True(t, setCmd.Called)
+		assert.True(t, multiCmd.Called)
+		assert.True(t, addCmd.Called)
+		assert.True(t, execCmd.Called)
+	})
+
+	t.Run("set as with ttl using mocked redis", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		setCmd := conn.Command(SetExpirationCommand, testKey, int64(10), []byte(testStringValue))
+
+		err = SetAs(context.Background(), client, RawCodec{}, testKey, testStringValue, 10*time.Second)
+		require.NoError(t, err)
+		assert.True(t, setCmd.Called)
+	})
+
+	t.Run("unsupported value", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		err = SetAs(context.Background(), client, RawCodec{}, testKey, model, 0)
+		require.ErrorIs(t, err, ErrCodecUnsupportedType)
+	})
+
+	t.Run("closed client", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		client.CloseAll(conn)
+
+		err = SetAs(context.Background(), client, nil, testKey, model, 0)
+		require.ErrorIs(t, err, ErrRedisPoolNil)
+	})
+
+	t.Run("set and get as - real redis", func(t *testing.T) {
+		if testing.Short() {
+			t.Skip("skipping live local redis tests")
+		}
+
+		client, conn, err := loadRealRedis(t)
+		require.NoError(t, err)
+		defer client.CloseAll(conn)
+
+		err = clearRealRedis(conn, t)
+		require.NoError(t, err)
+
+		for _, codec := range []Codec{JSONCodec{}, GobCodec{}} {
+			err = SetAs(context.Background(), client, codec, testKey, model, 10*time.Second, testDependantKey)
+			require.NoError(t, err)
+
+			var decoded testCodecModel
+			decoded, err = GetAs[testCodecModel](context.Background(), client, codec, testKey)
+			require.NoError(t, err)
+			assert.Equal(t, model, decoded)
+		}
+	})
+}
+
+// TestGetFromJSON tests the method GetFromJSON()
+func TestGetFromJSON(t *testing.T) {
+	t.Run("get from json using mocked redis", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.Command(GetCommand, testKey).Expect(`{"name":"test-name","count":3,"score":1.5}`)
+
+		var decoded testCodecModel
+		err := GetFromJSON(context.Background(), client, testKey, &decoded)
+		require.NoError(t, err)
+		assert.Equal(t, testCodecModel{Name: "test-name", Count: 3, Score: 1.5}, decoded)
+	})
+
+	t.Run("missing key", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.Command(GetCommand, testKey).Expect(nil)
+
+		var decoded testCodecModel
+		err := GetFromJSON(context.Background(), client, testKey, &decoded)
+		require.ErrorIs(t, err, redis.ErrNil)
+	})
+
+	t.Run("closed client", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		client.CloseAll(conn)
+
+		var decoded testCodecModel
+		err := GetFromJSON(context.Background(), client, testKey, &decoded)
+		require.ErrorIs(t, err, ErrRedisPoolNil)
+	})
+}
+
+// ExampleGetAs is an example of the method GetAs()
+func ExampleGetAs() {
+	// Load a mocked redis for testing/examples
+	client, conn := loadMockRedis()
+
+	// Close connections at end of request
+	defer client.CloseAll(conn)
+
+	// Mock the stored value
+	conn.Command(GetCommand, testKey).Expect(`{"name":"test-name","count":3,"score":1.5}`)
+
+	// Get the typed value
+	model, _ := GetAs[testCodecModel](context.Background(), client, JSONCodec{}, testKey)
+	fmt.Printf("got model: %s", model.Name)
+	// Output:got model: test-name
+}
+
+// ExampleSetAs is an example of the method SetAs()
+func ExampleSetAs() {
+	// Load a mocked redis for testing/examples
+	client, _ := loadMockRedis()
+
+	// Close connections at end of request
+	defer client.Close()
+
+	// Set the typed value
+	_ = SetAs(context.Background(), client, JSONCodec{}, testKey, testCodecModel{Name: "test-name"}, 0, testDependantKey)
+	fmt.Printf("set: %s dep key: %s", testKey, testDependantKey)
+	// Output:set: test-key-name dep key: test-dependant-key-name
+}
@@ -0,0 +1,252 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// ErrEmptyPattern is returned when no pattern (or namespace prefix) is given to delete
+var ErrEmptyPattern = errors.New("pattern or namespace prefix is required")
+
+// defaultDeleteBatchSize is the default number of keys scanned and unlinked per batch
+const defaultDeleteBatchSize = 500
+
+// DeleteProgress reports how far a DeleteByPattern or FlushNamespace call has come
+type DeleteProgress struct {
+	Matched int // Keys matching the pattern so far
+	Deleted int // Keys deleted so far (always 0 on a dry run)
+	Cleaned int // Dependency set members removed so far (always 0 on a dry run)
+}
+
+// DeleteOption configures DeleteByPattern and FlushNamespace
+type DeleteOption func(*deleteOptions)
+
+type deleteOptions struct {
+	batchSize int
+	dryRun    bool
+	progress  func(DeleteProgress)
+}
+
+// WithDeleteBatchSize sets how many keys are scanned (COUNT hint) and unlinked per batch
+// Values less than 1 are ignored and the default (500) is used.
+func WithDeleteBatchSize(n int) DeleteOption {
+	return func(o *deleteOptions) {
+		if n >= 1 {
+			o.batchSize = n
+		}
+	}
+}
+
+// WithDeleteDryRun only counts the matching keys, nothing is deleted
+func WithDeleteDryRun() DeleteOption {
+	return func(o *deleteOptions) {
+		o.dryRun = true
+	}
+}
+
+// WithDeleteProgress calls fn after every batch and once when finished
+func WithDeleteProgress(fn func(DeleteProgress)) DeleteOption {
+	return func(o *deleteOptions) {
+		o.progress = fn
+	}
+}
+
+// DeleteByPattern deletes every key matching the glob-style pattern using SCAN and batched UNLINK
+// The deleted keys are removed from the dependency sets named by their reverse dependency sets.
+// Returns the number of keys deleted (or matched, on a dry run)
+//
+// Unlike DestroyCache() this does not block redis or touch keys outside the pattern. Keys
+// created while the scan is running may survive.
+// Creates a new connection and closes connection at end of function call
+//
+// Custom connections use method: DeleteByPatternRaw()
+func DeleteByPattern(ctx context.Context, client *Client, pattern string, opts ...DeleteOption) (int, error) {
+	conn, err := client.GetConnectionWithContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer client.CloseConnection(conn)
+	return deleteByPattern(ctx, conn, pattern, func(keys []string) error {
+		return client.invalidateLocal(ctx, keys...)
+	}, opts...)
+}
+
+// DeleteByPatternRaw deletes every key matching the glob-style pattern using SCAN and batched UNLINK
+// The deleted keys are removed from the dependency sets named by their reverse dependency sets.
+// Returns the number of keys deleted (or matched, on a dry run)
+// Uses existing connection (does not close connection)
+//
+// Spec: https://redis.io/commands/unlink
+func DeleteByPatternRaw(conn redis.Conn, pattern string, opts ...DeleteOption) (int, error) {
+	return deleteByPattern(context.Background(), conn, pattern, nil, opts...)
+}
+
+// FlushNamespace deletes every key starting with the prefix (e.g. "tenant-1:")
+// This is the per-tenant alternative to DestroyCache() on a shared redis
+// Creates a new connection and closes connection at end of function call
+//
+// Uses methods: DeleteByPattern()
+func FlushNamespace(ctx context.Context, client *Client, prefix string, opts ...DeleteOption) (int, error) {
+	if len(prefix) == 0 {
+		return 0, ErrEmptyPattern
+	}
+	return DeleteByPattern(ctx, client, escapePattern(prefix)+"*", opts...)
+}
+
+// FlushNamespaceRaw deletes every key starting with the prefix (e.g. "tenant-1:")
+// Uses existing connection (does not close connection)
+//
+// Uses methods: DeleteByPatternRaw()
+func FlushNamespaceRaw(conn redis.Conn, prefix string, opts ...DeleteOption) (int, error) {
+	if len(prefix) == 0 {
+		return 0, ErrEmptyPattern
+	}
+	return DeleteByPatternRaw(conn, escapePattern(prefix)+"*", opts...)
+}
+
+// deleteByPattern scans for the pattern and unlinks the matches in batches
+// onDelete (optional) is called with every batch of deleted keys
+func deleteByPattern(ctx context.Context, conn redis.Conn, pattern string,
+	onDelete func(keys []string) error, opts ...DeleteOption,
+) (int, error) {
+	if len(pattern) == 0 {
+		return 0, ErrEmptyPattern
+	}
+
+	d := &patternDelete{conn: conn, onDelete: onDelete, opts: deleteOptions{batchSize: defaultDeleteBatchSize}}
+	for _, opt := range opts {
+		opt(&d.opts)
+	}
+
+	keys := scanSeq(ctx, conn, ScanCommand, nil, ScanOptions{Match: pattern, Count: d.opts.batchSize}, redis.Strings)
+	for key, err := range keys {
+		if err != nil {
+			return d.progress.Deleted, err
+		}
+		if d.batch = append(d.batch, key); len(d.batch) >= d.opts.batchSize {
+			if err = d.flush(); err != nil {
+				return d.progress.Deleted, err
+			}
+		}
+	}
+	if err := d.flush(); err != nil {
+		return d.progress.Deleted, err
+	}
+	if d.opts.dryRun {
+		return d.progress.Matched, nil
+	}
+	d.report()
+	return d.progress.Deleted, nil
+}
+
+// patternDelete holds the state of a single deleteByPattern run
+type patternDelete struct {
+	conn     redis.Conn
+	opts     deleteOptions
+	onDelete func(keys []string) error
+	batch    []string
+	progress DeleteProgress
+}
+
+// flush unlinks the pending batch and its reverse dependency sets, and removes the keys
+// from their dependency sets (or only counts the batch on a dry run)
+func (d *patternDelete) flush() error {
+	if len(d.batch) == 0 {
+		return nil
+	}
+	d.progress.Matched += len(d.batch)
+	if !d.opts.dryRun {
+		// The reverse dependency sets name the dependency sets to clean, read them before they go
+		dependencies, err := prefixedSetMembers(d.conn, ReverseDependencyPrefix, d.batch)
+		if err != nil {
+			return err
+		}
+
+		var deleted int
+		if deleted, err = redis.Int(d.conn.Do(UnlinkCommand, toInterfaces(d.batch)...)); err != nil {
+			return err
+		}
+		d.progress.Deleted += deleted
+
+		// The reverse dependency sets of the keys go with them (not counted)
+		reverse := make([]interface{}, len(d.batch))
+		for i, key := range d.batch {
+			reverse[i] = ReverseDependencyPrefix + key
+		}
+		if _, err = d.conn.Do(UnlinkCommand, reverse...); err != nil {
+			return err
+		}
+
+		var cleaned int
+		cleaned, err = removeFromDependencySets(d.conn, d.batch, dependencies)
+		d.progress.Cleaned += cleaned
+		if err != nil {
+			return err
+		}
+		if d.onDelete != nil {
+			if err = d.onDelete(d.batch); err != nil {
+				return err
+			}
+		}
+	}
+	d.batch = d.batch[:0]
+	d.report()
+	return nil
+}
+
+// report sends the current progress to the progress callback (if any)
+func (d *patternDelete) report() {
+	if d.opts.progress != nil {
+		d.opts.progress(d.progress)
+	}
+}
+
+// removeFromDependencySets removes each key from the dependency sets listed in its
+// reverse dependency set (one SREM per set, pipelined) and returns the members removed
+//
+// Spec: https://redis.io/commands/srem
+func removeFromDependencySets(conn redis.Conn, keys []string, dependencies [][]string) (int, error) {
+	var sets []string
+	members := make(map[string][]interface{})
+	for i, key := range keys {
+		for _, dependency := range dependencies[i] {
+			if _, ok := members[dependency]; !ok {
+				sets = append(sets, dependency)
+			}
+			members[dependency] = append(members[dependency], key)
+		}
+	}
+
+	var cleaned int
+	for chunk := range slices.Chunk(sets, bulkChunkSize) {
+		p := NewPipeline(conn)
+		futures := make([]*Future[interface{}], len(chunk))
+		for i, dependency := range chunk {
+			futures[i] = p.Do(RemoveMemberCommand, append([]interface{}{DependencyPrefix + dependency},
+				members[dependency]...)...)
+		}
+		if err := p.Exec(); err != nil {
+			return cleaned, err
+		}
+		for _, future := range futures {
+			removed, err := redis.Int(future.Result())
+			if err != nil {
+				return cleaned, err
+			}
+			cleaned += removed
+		}
+	}
+	return cleaned, nil
+}
+
+// patternEscaper escapes the glob-style special characters used by SCAN MATCH
+var patternEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`)
+
+// escapePattern escapes a literal string for use in a SCAN MATCH pattern
+func escapePattern(s string) string {
+	return patternEscaper.Replace(s)
+}
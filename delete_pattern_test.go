@@ -0,0 +1,200 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestDeleteByPattern tests the method DeleteByPattern()
+func TestDeleteByPattern(t *testing.T) {
+	t.Run("deletes in batches and cleans dependency sets", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.Command(ScanCommand, "0", "MATCH", "user:*", "COUNT", 2).Expect(scanPage("0", "user:1", "user:2", "user:3"))
+		conn.Command(MembersCommand, ReverseDependencyPrefix+"user:1").Expect([]interface{}{[]byte(testDependantKey)})
+		conn.Command(MembersCommand, ReverseDependencyPrefix+"user:2").Expect([]interface{}{})
+		conn.Command(MembersCommand, ReverseDependencyPrefix+"user:3").
+			Expect([]interface{}{[]byte(testDependantKey), []byte("other")})
+		first := conn.Command(UnlinkCommand, "user:1", "user:2").Expect(int64(2))
+		second := conn.Command(UnlinkCommand, "user:3").Expect(int64(1))
+		firstReverse := conn.Command(UnlinkCommand, ReverseDependencyPrefix+"user:1", ReverseDependencyPrefix+"user:2").
+			Expect(int64(1))
+		secondReverse := conn.Command(UnlinkCommand, ReverseDependencyPrefix+"user:3").Expect(int64(0))
+
+		firstRem := conn.Command(RemoveMemberCommand, DependencyPrefix+testDependantKey, "user:1").Expect(int64(1))
+		secondRem := conn.Command(RemoveMemberCommand, DependencyPrefix+testDependantKey, "user:3").Expect(int64(1))
+		otherRem := conn.Command(RemoveMemberCommand, DependencyPrefix+"other", "user:3").Expect(int64(0))
+		scanSets := conn.GenericCommand(SetScanCommand).Expect(scanPage("0"))
+
+		var reports []DeleteProgress
+		total, err := DeleteByPattern(context.Background(), client, "user:*",
+			WithDeleteBatchSize(2), WithDeleteProgress(func(p DeleteProgress) {
+				reports = append(reports, p)
+			}))
+		require.NoError(t, err)
+		assert.Equal(t, 3, total)
+		assert.True(t, first.Called)
+		assert.True(t, second.Called)
+		assert.True(t, firstReverse.Called, "reverse dependency sets are unlinked with their keys")
+		assert.True(t, secondReverse.Called)
+		assert.True(t, firstRem.Called, "keys are removed from the sets named by their reverse index")
+		assert.True(t, secondRem.Called)
+		assert.True(t, otherRem.Called)
+		assert.False(t, scanSets.Called, "dependency sets are not scanned")
+		assert.Equal(t, []DeleteProgress{
+			{Matched: 2, Deleted: 2, Cleaned: 1},
+			{Matched: 3, Deleted: 3, Cleaned: 2},
+			{Matched: 3, Deleted: 3, Cleaned: 2},
+		}, reports)
+	})
+
+	t.Run("dry run only counts", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.Command(ScanCommand, "0", "MATCH", "user:*", "COUNT", defaultDeleteBatchSize).
+			Expect(scanPage("0", "user:1", "user:2"))
+		unlinkCmd := conn.GenericCommand(UnlinkCommand).Expect(int64(2))
+
+		total, err := DeleteByPattern(context.Background(), client, "user:*", WithDeleteDryRun())
+		require.NoError(t, err)
+		assert.Equal(t, 2, total)
+		assert.False(t, unlinkCmd.Called)
+	})
+
+	t.Run("invalidates the local cache", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+		lc := loadMockLocalCache(client, LocalCacheOptions{})
+		lc.store.set("user:1", testStringValue, lc.store.currentEpoch())
+
+		conn.Command(ScanCommand, "0", "MATCH", "user:*", "COUNT", defaultDeleteBatchSize).Expect(scanPage("0", "user:1"))
+		conn.Command(MembersCommand, ReverseDependencyPrefix+"user:1").Expect([]interface{}{})
+		conn.Command(UnlinkCommand, "user:1").Expect(int64(1))
+		conn.Command(UnlinkCommand, ReverseDependencyPrefix+"user:1").Expect(int64(0))
+		pubCmd := conn.Command(PublishCommand, LocalCacheChannel, []byte(`{"keys":["user:1"]}`)).Expect(int64(1))
+
+		total, err := DeleteByPattern(context.Background(), client, "user:*")
+		require.NoError(t, err)
+		assert.Equal(t, 1, total)
+		assert.True(t, pubCmd.Called)
+		assert.Equal(t, 0, lc.store.len())
+	})
+
+	t.Run("empty pattern", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		_, err := DeleteByPattern(context.Background(), client, "")
+		require.ErrorIs(t, err, ErrEmptyPattern)
+	})
+
+	t.Run("unlink error", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.Command(ScanCommand, "0", "MATCH", "user:*", "COUNT", defaultDeleteBatchSize).Expect(scanPage("0", "user:1"))
+		conn.Command(MembersCommand, ReverseDependencyPrefix+"user:1").Expect([]interface{}{})
+		conn.Command(UnlinkCommand, "user:1").ExpectError(errTestLoader)
+
+		_, err := DeleteByPatternRaw(conn, "user:*")
+		require.ErrorIs(t, err, errTestLoader)
+	})
+
+	t.Run("scan error", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.Command(ScanCommand, "0", "MATCH", "user:*", "COUNT", defaultDeleteBatchSize).ExpectError(errTestLoader)
+
+		_, err := DeleteByPatternRaw(conn, "user:*")
+		require.ErrorIs(t, err, errTestLoader)
+	})
+
+	t.Run("delete by pattern using real redis", func(t *testing.T) {
+		if testing.Short() {
+			t.Skip("skipping live local redis tests")
+		}
+
+		client, conn, err := loadRealRedis(t)
+		require.NoError(t, err)
+		defer client.CloseAll(conn)
+		require.NoError(t, clearRealRedis(conn))
+
+		for i := 0; i < 25; i++ {
+			require.NoError(t, SetRaw(conn, fmt.Sprintf("tenant-1:%d", i), i, testDependantKey))
+		}
+		require.NoError(t, SetRaw(conn, "tenant-2:0", 0, testDependantKey))
+
+		var total int
+		total, err = FlushNamespaceRaw(conn, "tenant-1:", WithDeleteDryRun())
+		require.NoError(t, err)
+		assert.Equal(t, 25, total)
+
+		total, err = FlushNamespace(context.Background(), client, "tenant-1:", WithDeleteBatchSize(10))
+		require.NoError(t, err)
+		assert.Equal(t, 25, total)
+
+		var members []string
+		members, err = SetMembersRaw(conn, DependencyPrefix+testDependantKey)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"tenant-2:0"}, members)
+
+		var found bool
+		found, err = ExistsRaw(conn, ReverseDependencyPrefix+"tenant-1:0")
+		require.NoError(t, err)
+		assert.False(t, found, "reverse dependency sets are deleted with their keys")
+		found, err = ExistsRaw(conn, ReverseDependencyPrefix+"tenant-2:0")
+		require.NoError(t, err)
+		assert.True(t, found)
+	})
+}
+
+// TestFlushNamespace tests the method FlushNamespace()
+func TestFlushNamespace(t *testing.T) {
+	t.Run("escapes the prefix", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		scanCmd := conn.Command(ScanCommand, "0", "MATCH", `tenant\[1\]:*`, "COUNT", defaultDeleteBatchSize).
+			Expect(scanPage("0"))
+
+		total, err := FlushNamespace(context.Background(), client, "tenant[1]:")
+		require.NoError(t, err)
+		assert.Equal(t, 0, total)
+		assert.True(t, scanCmd.Called)
+	})
+
+	t.Run("empty prefix", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		_, err := FlushNamespace(context.Background(), client, "")
+		require.ErrorIs(t, err, ErrEmptyPattern)
+		_, err = FlushNamespaceRaw(conn, "")
+		require.ErrorIs(t, err, ErrEmptyPattern)
+	})
+}
+
+// TestEscapePattern tests escaping glob-style special characters
+func TestEscapePattern(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"tenant:", "tenant:"},
+		{"a*b", `a\*b`},
+		{"a?b", `a\?b`},
+		{"[a]", `\[a\]`},
+		{`a\b`, `a\\b`},
+		{"", ""},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.expected, escapePattern(test.input))
+	}
+}
@@ -3,6 +3,7 @@ package cache
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/gomodule/redigo/redis"
 )
@@ -12,13 +13,7 @@ import (
 //
 // Custom connections use method: DeleteRaw()
 func Delete(ctx context.Context, client *Client, keys ...string) (total int, err error) {
-	var conn redis.Conn
-	conn, err = client.GetConnectionWithContext(ctx)
-	if err != nil {
-		return total, err
-	}
-	defer client.CloseConnection(conn)
-	return DeleteRaw(conn, keys...)
+	return KillByDependency(ctx, client, keys...)
 }
 
 // DeleteRaw is an alias for KillByDependency()
@@ -28,12 +23,15 @@ func DeleteRaw(conn redis.Conn, keys ...string) (total int, err error) {
 }
 
 // KillByDependency removes all keys which are listed as depending on the key(s)
+// Uses the shipped function library when loaded (see LoadFunctions), and sends an
+// invalidation event when enabled (see EnableInvalidationEvents)
 // Alias: Delete()
 // Creates a new connection and closes connection at end of function call
 //
 // Custom connections use method: KillByDependencyRaw()
 //
 // Commands used:
+// https://redis.io/commands/fcall
 // https://redis.io/commands/eval
 // https://redis.io/commands/del
 func KillByDependency(ctx context.Context, client *Client, keys ...string) (int, error) {
@@ -42,36 +40,61 @@ func KillByDependency(ctx context.Context, client *Client, keys ...string) (int,
 		return 0, err
 	}
 	defer client.CloseConnection(conn)
-	return KillByDependencyRaw(conn, keys...)
+
+	// Collect the dependent keys before they are gone so local caches and event
+	// subscribers can drop them too
+	var dependents []string
+	if client.localCache() != nil || client.invalidationEvents() != nil {
+		if dependents, err = dependentKeysRaw(conn, keys...); err != nil {
+			return 0, err
+		}
+	}
+
+	var total int
+	if total, err = killByDependencyRaw(conn, client.functionsLoaded(), keys...); err != nil {
+		return total, err
+	}
+	invalidated := append(dependents, keys...)
+	err = client.sendInvalidation(conn, keys, invalidated)
+	if localErr := client.invalidateLocal(ctx, invalidated...); err == nil {
+		err = localErr
+	}
+	return total, err
 }
 
 // KillByDependencyRaw removes all keys which are listed as depending on the key(s)
+// The script is run by SHA and sent in full when redis does not have it (NOSCRIPT), so this
+// works without RegisterScripts() and after a redis restart or SCRIPT FLUSH
 // Alias: Delete()
 //
 // Commands used:
+// https://redis.io/commands/evalsha
 // https://redis.io/commands/eval
 // https://redis.io/commands/del
 func KillByDependencyRaw(conn redis.Conn, keys ...string) (total int, err error) {
+	return killByDependencyRaw(conn, false, keys...)
+}
+
+// killByDependencyRaw runs the dependency kill function (when functions are loaded) or script
+func killByDependencyRaw(conn redis.Conn, functions bool, keys ...string) (total int, err error) {
 	// Do we have keys to kill?
 	if len(keys) == 0 {
 		return 0, nil
 	}
 
-	// Create the arguments
-	args := make([]interface{}, len(keys)+2)
+	// Create the arguments: the dependency sets (passed as KEYS so namespaced connections
+	// can prefix them), and the keys themselves so the script can find their reverse sets
+	sets := make([]string, len(keys))
 	deleteArgs := make([]interface{}, len(keys))
 
-	args[0] = killByDependencySha
-	args[1] = 0
-
 	// Loop keys
 	for i, key := range keys {
-		args[i+2] = DependencyPrefix + key
+		sets[i] = DependencyPrefix + key
 		deleteArgs[i] = key
 	}
 
 	// Run the script
-	if total, err = redis.Int(conn.Do(EvalCommand, args...)); err != nil {
+	if total, err = redis.Int(killByDependencyScript.do(conn, functions, sets, deleteArgs...)); err != nil {
 		return 0, err
 	}
 
@@ -85,13 +108,161 @@ func KillByDependencyRaw(conn redis.Conn, keys ...string) (total int, err error)
 	return total, nil
 }
 
+// UnlinkDependencies removes the links between the key and the dependencies, without deleting anything
+// Creates a new connection and closes connection at end of function call
+//
+// Custom connections use method: UnlinkDependenciesRaw()
+func UnlinkDependencies(ctx context.Context, client *Client, key string, dependencies ...string) error {
+	conn, err := client.GetConnectionWithContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.CloseConnection(conn)
+	return UnlinkDependenciesRaw(conn, key, dependencies...)
+}
+
+// UnlinkDependenciesRaw removes the links between the key and the dependencies
+// Uses existing connection (does not close connection)
+//
+// Commands used:
+// https://redis.io/commands/multi
+// https://redis.io/commands/srem
+// https://redis.io/commands/exec
+func UnlinkDependenciesRaw(conn redis.Conn, key string, dependencies ...string) error {
+	if len(dependencies) == 0 {
+		return nil
+	}
+	if err := conn.Send(MultiCommand); err != nil {
+		return err
+	}
+	for _, dependency := range dependencies {
+		if err := conn.Send(RemoveMemberCommand, DependencyPrefix+dependency, key); err != nil {
+			return err
+		}
+	}
+	reverse := append([]interface{}{ReverseDependencyPrefix + key}, toInterfaces(dependencies)...)
+	if err := conn.Send(RemoveMemberCommand, reverse...); err != nil {
+		return err
+	}
+	return execRaw(conn)
+}
+
+// ReplaceDependencies atomically swaps the dependencies of the key (e.g. when an entity is
+// re-parented): links to dependencies not listed are removed, the listed ones are linked.
+// No dependencies removes every link. The current links are read from the reverse index, so
+// links made before it was introduced are not removed (use UnlinkDependencies).
+//
+// The swap runs as an optimistic transaction on the reverse index, retried on conflict.
+// Creates a new connection and closes connection at end of function call
+//
+// Custom connections use method: ReplaceDependenciesRaw()
+func ReplaceDependencies(ctx context.Context, client *Client, key string, dependencies ...string) error {
+	_, err := Transaction(ctx, client, []string{ReverseDependencyPrefix + key},
+		replaceDependenciesTx(key, dependencies))
+	return err
+}
+
+// ReplaceDependenciesRaw atomically swaps the dependencies of the key
+// Runs once: returns ErrTxConflict if the key's links changed during the swap (nothing is changed)
+// Uses existing connection (does not close connection)
+//
+// Commands used:
+// https://redis.io/commands/watch
+// https://redis.io/commands/smembers
+// https://redis.io/commands/pttl
+// https://redis.io/commands/srem
+// https://redis.io/commands/sadd
+// https://redis.io/commands/pexpire
+// https://redis.io/commands/exec
+func ReplaceDependenciesRaw(conn redis.Conn, key string, dependencies ...string) error {
+	_, err := TransactionRaw(conn, []string{ReverseDependencyPrefix + key}, replaceDependenciesTx(key, dependencies))
+	return err
+}
+
+// replaceDependenciesTx reads the key's current dependencies and ttl, and queues the swap
+// The reverse index is updated in place and given the key's ttl, so it still expires with the key
+func replaceDependenciesTx(key string, dependencies []string) func(tx *Tx) error {
+	return func(tx *Tx) error {
+		current, err := redis.Strings(tx.Do(MembersCommand, ReverseDependencyPrefix+key))
+		if err != nil {
+			return err
+		}
+		var ttl int64
+		if ttl, err = redis.Int64(tx.Do(PTTLCommand, key)); err != nil {
+			return err
+		}
+
+		keep := make(map[string]bool, len(dependencies))
+		for _, dependency := range dependencies {
+			keep[dependency] = true
+		}
+		var removed []interface{}
+		for _, dependency := range current {
+			if !keep[dependency] {
+				if err = tx.Queue(RemoveMemberCommand, DependencyPrefix+dependency, key); err != nil {
+					return err
+				}
+				removed = append(removed, dependency)
+			}
+		}
+		for _, dependency := range dependencies {
+			if err = tx.Queue(AddToSetCommand, DependencyPrefix+dependency, key); err != nil {
+				return err
+			}
+		}
+
+		if len(removed) > 0 {
+			removed = append([]interface{}{ReverseDependencyPrefix + key}, removed...)
+			if err = tx.Queue(RemoveMemberCommand, removed...); err != nil {
+				return err
+			}
+		}
+		if len(dependencies) == 0 {
+			return nil
+		}
+		reverse := append([]interface{}{ReverseDependencyPrefix + key}, toInterfaces(dependencies)...)
+		if err = tx.Queue(AddToSetCommand, reverse...); err != nil || ttl <= 0 {
+			return err
+		}
+		return tx.Queue(PExpireCommand, ReverseDependencyPrefix+key, ttl)
+	}
+}
+
+// dependentKeysRaw returns the keys linked to the given dependency keys
+//
+// Spec: https://redis.io/commands/smembers
+func dependentKeysRaw(conn redis.Conn, keys ...string) (dependents []string, err error) {
+	for _, key := range keys {
+		var members []string
+		if members, err = SetMembersRaw(conn, DependencyPrefix+key); err != nil {
+			return nil, err
+		}
+		dependents = append(dependents, members...)
+	}
+	return dependents, nil
+}
+
 // linkDependencies links any dependencies
+// The reverse index (key -> its dependencies) is kept for DependenciesOf()
+//
+// Commands used:
+// https://redis.io/commands/multi
+// https://redis.io/commands/sadd
+// https://redis.io/commands/exec
+func linkDependencies(conn redis.Conn, key interface{}, dependencies ...string) error {
+	return linkDependenciesExp(conn, key, 0, dependencies...)
+}
+
+// linkDependenciesExp links any dependencies, the reverse index expires with the key (ttl > 0)
 //
 // Commands used:
 // https://redis.io/commands/multi
 // https://redis.io/commands/sadd
+// https://redis.io/commands/expire
 // https://redis.io/commands/exec
-func linkDependencies(conn redis.Conn, key interface{}, dependencies ...string) (err error) {
+func linkDependenciesExp(conn redis.Conn, key interface{}, ttl time.Duration,
+	dependencies ...string,
+) (err error) {
 	// No dependencies given
 	if len(dependencies) == 0 {
 		return err
@@ -109,6 +280,17 @@ func linkDependencies(conn redis.Conn, key interface{}, dependencies ...string)
 		}
 	}
 
+	// Record the dependencies of the key
+	reverse := append([]interface{}{ReverseDependencyPrefix + argString(key)}, toInterfaces(dependencies)...)
+	if err = conn.Send(AddToSetCommand, reverse...); err != nil {
+		return err
+	}
+	if ttl > 0 {
+		if err = conn.Send(ExpireCommand, reverse[0], int64(ttl.Seconds())); err != nil {
+			return err
+		}
+	}
+
 	// Fire the exec command.
 	// EXEC returns an array of SADD results on real Redis. redigomock returns a nil reply
 	// for commands without a registered expectation, which redigo surfaces as redis.ErrNil;
@@ -0,0 +1,198 @@
+package cache
+
+import (
+	"context"
+	"slices"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// defaultCascadeDepth is the default number of dependency levels followed by KillByDependencyCascade()
+const defaultCascadeDepth = 10
+
+// cascadeDeleteLua deletes the KEYS one by one and returns the (1-based) indexes of the ones that existed
+// Only the first ARGV[1] keys are reported, the ones after them (reverse dependency sets) are just deleted.
+// Indexes are returned rather than names so namespaced connections need no translation
+const cascadeDeleteLua = `
+local reported = tonumber(ARGV[1]) or #KEYS
+local deleted = {}
+for i, key in ipairs(KEYS) do
+	if redis.call("` + DeleteCommand + `", key) == 1 and i <= reported then
+		table.insert(deleted, i)
+	end
+end
+return deleted
+`
+
+// cascadeDeleteScript runs cascadeDeleteLua (EVALSHA, falling back to EVAL on NOSCRIPT)
+var cascadeDeleteScript = redis.NewScript(-1, cascadeDeleteLua)
+
+// CascadeOption configures KillByDependencyCascade()
+type CascadeOption func(*cascadeOptions)
+
+type cascadeOptions struct {
+	maxDepth  int
+	batchSize int
+}
+
+// WithCascadeDepth sets how many levels of dependents are followed (default: 10)
+// A depth of 1 deletes the same keys as KillByDependency()
+func WithCascadeDepth(depth int) CascadeOption {
+	return func(o *cascadeOptions) {
+		if depth > 0 {
+			o.maxDepth = depth
+		}
+	}
+}
+
+// WithCascadeBatchSize sets how many keys are deleted per script call (default: 500)
+// Everything is deleted atomically when it fits in a single batch
+func WithCascadeBatchSize(size int) CascadeOption {
+	return func(o *cascadeOptions) {
+		if size > 0 {
+			o.batchSize = size
+		}
+	}
+}
+
+// CascadeResult is the outcome of KillByDependencyCascade()
+type CascadeResult struct {
+	Deleted []string // every key that existed and was deleted, including dependency sets (depend:...)
+	Depth   int      // deepest level of dependents reached (0: only the given keys)
+}
+
+// Count returns the exact number of deleted keys
+func (r *CascadeResult) Count() int {
+	return len(r.Deleted)
+}
+
+// KillByDependencyCascade removes the key(s) and everything depending on them, following
+// dependents of dependents (e.g. user -> user's orders -> order summaries) up to the depth
+// set by WithCascadeDepth(). Keys seen before are skipped, so cycles are safe.
+//
+// The deleted keys are dropped from the local cache and sent as an invalidation event (if enabled).
+// Creates a new connection and closes connection at end of function call
+//
+// Custom connections use method: KillByDependencyCascadeRaw()
+func KillByDependencyCascade(ctx context.Context, client *Client, keys []string,
+	opts ...CascadeOption,
+) (*CascadeResult, error) {
+	conn, err := client.GetConnectionWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer client.CloseConnection(conn)
+
+	result, err := KillByDependencyCascadeRaw(conn, keys, opts...)
+	if result != nil && len(result.Deleted) > 0 {
+		if eventErr := client.sendInvalidation(conn, keys, result.Deleted); err == nil {
+			err = eventErr
+		}
+		if localErr := client.invalidateLocal(ctx, result.Deleted...); err == nil {
+			err = localErr
+		}
+	}
+	return result, err
+}
+
+// KillByDependencyCascadeRaw removes the key(s) and everything depending on them, following
+// dependents of dependents up to the depth set by WithCascadeDepth()
+//
+// The graph is walked first (one pipeline per level), then the keys are deleted by a script
+// in batches (WithCascadeBatchSize). On error the result holds what was deleted so far.
+// Uses existing connection (does not close connection)
+//
+// Commands used:
+// https://redis.io/commands/smembers
+// https://redis.io/commands/evalsha
+// https://redis.io/commands/del
+func KillByDependencyCascadeRaw(conn redis.Conn, keys []string, opts ...CascadeOption) (*CascadeResult, error) {
+	o := cascadeOptions{maxDepth: defaultCascadeDepth, batchSize: bulkChunkSize}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	targets, visited, depth, err := walkDependencies(conn, keys, o.maxDepth)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &CascadeResult{Depth: depth}
+	for batch := range slices.Chunk(targets, o.batchSize) {
+		// The reverse dependency sets of the deleted keys go with them
+		var reverse []interface{}
+		for _, target := range batch {
+			if visited[target] {
+				reverse = append(reverse, ReverseDependencyPrefix+target)
+			}
+		}
+		args := make([]interface{}, 0, len(batch)+len(reverse)+2)
+		args = append(args, len(batch)+len(reverse))
+		args = append(args, toInterfaces(batch)...)
+		args = append(args, reverse...)
+		args = append(args, len(batch))
+
+		var deleted []int
+		if deleted, err = redis.Ints(cascadeDeleteScript.Do(conn, args...)); err != nil {
+			return result, err
+		}
+		for _, index := range deleted {
+			if index > 0 && index <= len(batch) {
+				result.Deleted = append(result.Deleted, batch[index-1])
+			}
+		}
+	}
+	return result, nil
+}
+
+// walkDependencies returns every key reachable from the keys within maxDepth levels, with the
+// dependency sets of the keys that were expanded, the keys reached (visited) and the deepest level reached
+func walkDependencies(conn redis.Conn, keys []string, maxDepth int,
+) (targets []string, visited map[string]bool, depth int, err error) {
+	visited = make(map[string]bool, len(keys))
+	frontier := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if !visited[key] {
+			visited[key] = true
+			frontier = append(frontier, key)
+		}
+	}
+
+	for len(frontier) > 0 {
+		targets = append(targets, frontier...)
+		if depth == maxDepth {
+			break
+		}
+
+		var members []string
+		if members, err = dependencyMembers(conn, frontier); err != nil {
+			return nil, nil, 0, err
+		}
+		for _, key := range frontier {
+			targets = append(targets, DependencyPrefix+key)
+		}
+
+		var next []string
+		for _, member := range members {
+			if !visited[member] {
+				visited[member] = true
+				next = append(next, member)
+			}
+		}
+		if len(next) == 0 {
+			break
+		}
+		frontier = next
+		depth++
+	}
+	return targets, visited, depth, nil
+}
+
+// dependencyMembers returns the members of the dependency sets of the keys (pipelined)
+func dependencyMembers(conn redis.Conn, keys []string) ([]string, error) {
+	sets, err := dependencySets(conn, keys)
+	if err != nil {
+		return nil, err
+	}
+	return slices.Concat(sets...), nil
+}
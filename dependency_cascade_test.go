@@ -0,0 +1,164 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testCascadeSha is the SHA of the cascade delete script
+var testCascadeSha = redis.NewScript(-1, cascadeDeleteLua).Hash()
+
+// TestKillByDependencyCascade tests the method KillByDependencyCascade()
+func TestKillByDependencyCascade(t *testing.T) {
+	t.Run("follows every level and stops at cycles", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.Command(MembersCommand, DependencyPrefix+"user").Expect([]interface{}{[]byte("orders")})
+		conn.Command(MembersCommand, DependencyPrefix+"orders").Expect([]interface{}{[]byte("summary")})
+		conn.Command(MembersCommand, DependencyPrefix+"summary").Expect([]interface{}{[]byte("user")})
+		deleteCmd := conn.Command(EvalCommand, testCascadeSha, 9,
+			"user", DependencyPrefix+"user", "orders", DependencyPrefix+"orders",
+			"summary", DependencyPrefix+"summary",
+			ReverseDependencyPrefix+"user", ReverseDependencyPrefix+"orders", ReverseDependencyPrefix+"summary", 6).
+			Expect([]interface{}{int64(2), int64(3), int64(4), int64(5)})
+
+		result, err := KillByDependencyCascade(context.Background(), client, []string{"user"})
+		require.NoError(t, err)
+		assert.True(t, deleteCmd.Called)
+		assert.Equal(t, 2, result.Depth)
+		assert.Equal(t, 4, result.Count())
+		assert.Equal(t, []string{DependencyPrefix + "user", "orders", DependencyPrefix + "orders", "summary"},
+			result.Deleted)
+	})
+
+	t.Run("depth limit", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.Command(MembersCommand, DependencyPrefix+"user").Expect([]interface{}{[]byte("orders")})
+		deleteCmd := conn.Command(EvalCommand, testCascadeSha, 5, "user", DependencyPrefix+"user", "orders",
+			ReverseDependencyPrefix+"user", ReverseDependencyPrefix+"orders", 3).
+			Expect([]interface{}{int64(3)})
+
+		result, err := KillByDependencyCascadeRaw(conn, []string{"user", "user"}, WithCascadeDepth(1))
+		require.NoError(t, err)
+		assert.True(t, deleteCmd.Called)
+		assert.Equal(t, 1, result.Depth)
+		assert.Equal(t, []string{"orders"}, result.Deleted)
+	})
+
+	t.Run("deletes in batches", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.Command(MembersCommand, DependencyPrefix+"user").Expect([]interface{}{[]byte("orders")})
+		conn.Command(MembersCommand, DependencyPrefix+"orders").Expect([]interface{}{})
+		firstCmd := conn.Command(EvalCommand, testCascadeSha, 3, "user", DependencyPrefix+"user",
+			ReverseDependencyPrefix+"user", 2).
+			Expect([]interface{}{int64(2)})
+		secondCmd := conn.Command(EvalCommand, testCascadeSha, 3, "orders", DependencyPrefix+"orders",
+			ReverseDependencyPrefix+"orders", 2).
+			Expect([]interface{}{int64(1), int64(2)})
+
+		result, err := KillByDependencyCascadeRaw(conn, []string{"user"}, WithCascadeBatchSize(2))
+		require.NoError(t, err)
+		assert.True(t, firstCmd.Called)
+		assert.True(t, secondCmd.Called)
+		assert.Equal(t, []string{DependencyPrefix + "user", "orders", DependencyPrefix + "orders"}, result.Deleted)
+	})
+
+	t.Run("walk error", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.Command(MembersCommand, DependencyPrefix+"user").ExpectError(errTestLoader)
+
+		_, err := KillByDependencyCascade(context.Background(), client, []string{"user"})
+		require.ErrorIs(t, err, errTestLoader)
+	})
+
+	t.Run("delete error keeps the earlier batches", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.Command(MembersCommand, DependencyPrefix+"user").Expect([]interface{}{[]byte("orders")})
+		conn.Command(MembersCommand, DependencyPrefix+"orders").Expect([]interface{}{})
+		conn.Command(EvalCommand, testCascadeSha, 3, "user", DependencyPrefix+"user",
+			ReverseDependencyPrefix+"user", 2).
+			Expect([]interface{}{int64(1)})
+		conn.Command(EvalCommand, testCascadeSha, 3, "orders", DependencyPrefix+"orders",
+			ReverseDependencyPrefix+"orders", 2).
+			ExpectError(errTestLoader)
+
+		result, err := KillByDependencyCascadeRaw(conn, []string{"user"}, WithCascadeBatchSize(2))
+		require.ErrorIs(t, err, errTestLoader)
+		assert.Equal(t, []string{"user"}, result.Deleted)
+	})
+
+	t.Run("cascade using real redis", func(t *testing.T) {
+		if testing.Short() {
+			t.Skip("skipping live local redis tests")
+		}
+
+		client, conn, err := loadRealRedis(t)
+		require.NoError(t, err)
+		defer client.CloseAll(conn)
+		require.NoError(t, clearRealRedis(conn))
+
+		require.NoError(t, SetRaw(conn, "orders", testStringValue, "user"))
+		require.NoError(t, SetRaw(conn, "summary", testStringValue, "orders"))
+		require.NoError(t, SetRaw(conn, "unrelated", testStringValue))
+
+		var result *CascadeResult
+		result, err = KillByDependencyCascade(context.Background(), client, []string{"user"})
+		require.NoError(t, err)
+		assert.Equal(t, 2, result.Depth)
+		assert.ElementsMatch(t, []string{
+			DependencyPrefix + "user", "orders", DependencyPrefix + "orders", "summary",
+		}, result.Deleted)
+
+		var found bool
+		found, err = ExistsRaw(conn, "summary")
+		require.NoError(t, err)
+		assert.False(t, found)
+		found, err = ExistsRaw(conn, "unrelated")
+		require.NoError(t, err)
+		assert.True(t, found)
+
+		// The reverse dependency sets went with their keys
+		found, err = ExistsRaw(conn, ReverseDependencyPrefix+"summary")
+		require.NoError(t, err)
+		assert.False(t, found)
+		found, err = ExistsRaw(conn, ReverseDependencyPrefix+"orders")
+		require.NoError(t, err)
+		assert.False(t, found)
+	})
+}
+
+// ExampleKillByDependencyCascade is an example of the method KillByDependencyCascade()
+func ExampleKillByDependencyCascade() {
+	// Load a mocked redis for testing/examples
+	client, conn := loadMockRedis()
+
+	// Close connections at end of request
+	defer client.CloseAll(conn)
+
+	// Mock the graph (user -> orders) and the delete
+	conn.Command(MembersCommand, DependencyPrefix+"user").Expect([]interface{}{[]byte("orders")})
+	conn.Command(MembersCommand, DependencyPrefix+"orders").Expect([]interface{}{})
+	conn.Command(EvalCommand, testCascadeSha, 6,
+		"user", DependencyPrefix+"user", "orders", DependencyPrefix+"orders",
+		ReverseDependencyPrefix+"user", ReverseDependencyPrefix+"orders", 4).
+		Expect([]interface{}{int64(2), int64(3)})
+
+	// Delete the user's dependents, and their dependents
+	result, _ := KillByDependencyCascade(context.Background(), client, []string{"user"})
+	fmt.Printf("deleted %d keys: %v", result.Count(), result.Deleted)
+	// Output:deleted 2 keys: [depend:user orders]
+}
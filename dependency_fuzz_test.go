@@ -30,13 +30,14 @@ func FuzzKillByDependency(f *testing.F) {
 		args := make([]interface{}, len(keys)+2)
 		deleteArgs := make([]interface{}, len(keys))
 		args[0] = killByDependencySha
-		args[1] = 0
+		args[1] = len(keys)
 
 		for i, key := range keys {
 			args[i+2] = DependencyPrefix + key
 			deleteArgs[i] = key
 		}
 
+		args = append(args, deleteArgs...) // the keys follow their dependency sets
 		conn.Command(EvalCommand, args...).Expect(int64(len(keys)))
 		conn.Command(DeleteCommand, deleteArgs...).Expect(int64(len(keys)))
 
@@ -73,13 +74,14 @@ func FuzzDelete(f *testing.F) {
 		args := make([]interface{}, len(keys)+2)
 		deleteArgs := make([]interface{}, len(keys))
 		args[0] = killByDependencySha
-		args[1] = 0
+		args[1] = len(keys)
 
 		for i, key := range keys {
 			args[i+2] = DependencyPrefix + key
 			deleteArgs[i] = key
 		}
 
+		args = append(args, deleteArgs...) // the keys follow their dependency sets
 		conn.Command(EvalCommand, args...).Expect(int64(len(keys)))
 		conn.Command(DeleteCommand, deleteArgs...).Expect(int64(len(keys)))
 
@@ -116,6 +118,7 @@ func FuzzLinkDependencies(f *testing.F) {
 		for _, dependency := range dependencies {
 			conn.Command(AddToSetCommand, DependencyPrefix+dependency, key).Expect("QUEUED")
 		}
+		mockReverseDependencies(conn, key, dependencies...).Expect("QUEUED")
 		conn.Command(ExecuteCommand).Expect(make([]interface{}, len(dependencies)))
 
 		assert.NotPanics(t, func() {
@@ -144,7 +147,7 @@ func FuzzKillByDependencyWithVariousInputs(f *testing.F) {
 		client, conn := loadMockRedis(t)
 		defer client.Close()
 
-		args := []interface{}{killByDependencySha, 0, DependencyPrefix + key}
+		args := []interface{}{killByDependencySha, 1, DependencyPrefix + key, key}
 		deleteArgs := []interface{}{key}
 
 		conn.Command(EvalCommand, args...).Expect(int64(1))
@@ -180,13 +183,14 @@ func FuzzKillByDependencyRaw(f *testing.F) {
 		args := make([]interface{}, len(keys)+2)
 		deleteArgs := make([]interface{}, len(keys))
 		args[0] = killByDependencySha
-		args[1] = 0
+		args[1] = len(keys)
 
 		for i, key := range keys {
 			args[i+2] = DependencyPrefix + key
 			deleteArgs[i] = key
 		}
 
+		args = append(args, deleteArgs...) // the keys follow their dependency sets
 		conn.Command(EvalCommand, args...).Expect(int64(len(keys)))
 		conn.Command(DeleteCommand, deleteArgs...).Expect(int64(len(keys)))
 
@@ -220,9 +224,10 @@ func FuzzDependencyOperationsWithEdgeCases(f *testing.F) {
 
 		conn.Command(MultiCommand).Expect("QUEUED")
 		conn.Command(AddToSetCommand, DependencyPrefix+dependency, key).Expect("QUEUED")
+		mockReverseDependencies(conn, key, dependency).Expect("QUEUED")
 		conn.Command(ExecuteCommand).Expect([]interface{}{int64(1)})
 
-		args := []interface{}{killByDependencySha, 0, DependencyPrefix + dependency}
+		args := []interface{}{killByDependencySha, 1, DependencyPrefix + dependency, dependency}
 		deleteArgs := []interface{}{dependency}
 
 		conn.Command(EvalCommand, args...).Expect(int64(1))
@@ -0,0 +1,232 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// DependencyGraph maps each dependency to the keys depending on it (see ExportDependencyGraph)
+type DependencyGraph map[string][]string
+
+// dotEscaper escapes a string for a quoted DOT identifier
+var dotEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// DOT renders the graph in Graphviz DOT format, with edges from each dependency to its dependents
+func (g DependencyGraph) DOT() string {
+	var b strings.Builder
+	b.WriteString("digraph dependencies {\n")
+	for _, dependency := range slices.Sorted(maps.Keys(g)) {
+		if len(g[dependency]) == 0 {
+			_, _ = fmt.Fprintf(&b, "\t\"%s\";\n", dotEscaper.Replace(dependency))
+		}
+		for _, key := range g[dependency] {
+			_, _ = fmt.Fprintf(&b, "\t\"%s\" -> \"%s\";\n", dotEscaper.Replace(dependency), dotEscaper.Replace(key))
+		}
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// JSON renders the graph as a JSON object of dependency -> dependent keys
+func (g DependencyGraph) JSON() ([]byte, error) {
+	return json.Marshal(map[string][]string(g))
+}
+
+// DependentsOf returns the keys depending on the dependency (what KillByDependency deletes)
+// Creates a new connection and closes connection at end of function call
+//
+// Custom connections use method: DependentsOfRaw()
+func DependentsOf(ctx context.Context, client *Client, dependency string) ([]string, error) {
+	conn, err := client.GetConnectionWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer client.CloseConnection(conn)
+	return DependentsOfRaw(conn, dependency)
+}
+
+// DependentsOfRaw returns the keys depending on the dependency
+// Uses existing connection (does not close connection)
+//
+// Spec: https://redis.io/commands/smembers
+func DependentsOfRaw(conn redis.Conn, dependency string) ([]string, error) {
+	return SetMembersRaw(conn, DependencyPrefix+dependency)
+}
+
+// DependenciesOf returns the dependencies the key was linked to
+// Only links made since the reverse index was introduced are known
+// Creates a new connection and closes connection at end of function call
+//
+// Custom connections use method: DependenciesOfRaw()
+func DependenciesOf(ctx context.Context, client *Client, key string) ([]string, error) {
+	conn, err := client.GetConnectionWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer client.CloseConnection(conn)
+	return DependenciesOfRaw(conn, key)
+}
+
+// DependenciesOfRaw returns the dependencies the key was linked to
+// Uses existing connection (does not close connection)
+//
+// Spec: https://redis.io/commands/smembers
+func DependenciesOfRaw(conn redis.Conn, key string) ([]string, error) {
+	return SetMembersRaw(conn, ReverseDependencyPrefix+key)
+}
+
+// KillByDependencyDryRun returns the keys KillByDependency() would delete, without deleting anything
+// Only keys that exist are returned, so the length matches the total KillByDependency() would report
+// Creates a new connection and closes connection at end of function call
+//
+// Custom connections use method: KillByDependencyDryRunRaw()
+func KillByDependencyDryRun(ctx context.Context, client *Client, keys ...string) ([]string, error) {
+	conn, err := client.GetConnectionWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer client.CloseConnection(conn)
+	return KillByDependencyDryRunRaw(conn, keys...)
+}
+
+// KillByDependencyDryRunRaw returns the keys KillByDependencyRaw() would delete, without deleting anything
+// Uses existing connection (does not close connection)
+//
+// Commands used:
+// https://redis.io/commands/smembers
+// https://redis.io/commands/exists
+func KillByDependencyDryRunRaw(conn redis.Conn, keys ...string) ([]string, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	sets, err := dependencySets(conn, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	// Same keys, in the same order, as the dependency script and the DEL that follows it
+	seen := make(map[string]bool)
+	var candidates []string
+	add := func(key string) {
+		if !seen[key] {
+			seen[key] = true
+			candidates = append(candidates, key)
+		}
+	}
+	for i, key := range keys {
+		add(DependencyPrefix + key)
+		for _, member := range sets[i] {
+			add(member)
+		}
+	}
+	for _, key := range keys {
+		add(key)
+	}
+
+	var existing []string
+	for chunk := range slices.Chunk(candidates, bulkChunkSize) {
+		p := NewPipeline(conn)
+		futures := make([]*Future[bool], len(chunk))
+		for i, key := range chunk {
+			futures[i] = p.Exists(key)
+		}
+		if err = p.Exec(); err != nil {
+			return nil, err
+		}
+		for i, future := range futures {
+			var found bool
+			if found, err = future.Result(); err != nil {
+				return nil, err
+			}
+			if found {
+				existing = append(existing, chunk[i])
+			}
+		}
+	}
+	return existing, nil
+}
+
+// ExportDependencyGraph returns the dependents of the given dependencies, or of every
+// dependency in the database when none are given (found with SCAN, for debugging)
+// Creates a new connection and closes connection at end of function call
+//
+// Custom connections use method: ExportDependencyGraphRaw()
+func ExportDependencyGraph(ctx context.Context, client *Client, dependencies ...string) (DependencyGraph, error) {
+	conn, err := client.GetConnectionWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer client.CloseConnection(conn)
+	return ExportDependencyGraphRaw(conn, dependencies...)
+}
+
+// ExportDependencyGraphRaw returns the dependents of the given dependencies, or of every
+// dependency in the database when none are given
+// Uses existing connection (does not close connection)
+//
+// Commands used:
+// https://redis.io/commands/scan
+// https://redis.io/commands/smembers
+func ExportDependencyGraphRaw(conn redis.Conn, dependencies ...string) (DependencyGraph, error) {
+	if len(dependencies) == 0 {
+		seen := make(map[string]bool)
+		for key, err := range ScanRaw(conn, ScanOptions{Match: DependencyPrefix + AllKeysCommand}) {
+			if err != nil {
+				return nil, err
+			}
+			if dependency := strings.TrimPrefix(key, DependencyPrefix); !seen[dependency] {
+				seen[dependency] = true
+				dependencies = append(dependencies, dependency)
+			}
+		}
+	}
+
+	sets, err := dependencySets(conn, dependencies)
+	if err != nil {
+		return nil, err
+	}
+	graph := make(DependencyGraph, len(dependencies))
+	for i, dependency := range dependencies {
+		slices.Sort(sets[i])
+		graph[dependency] = append([]string{}, sets[i]...)
+	}
+	return graph, nil
+}
+
+// dependencySets returns the members of the dependency set of each dependency (pipelined)
+//
+// Spec: https://redis.io/commands/smembers
+func dependencySets(conn redis.Conn, dependencies []string) ([][]string, error) {
+	return prefixedSetMembers(conn, DependencyPrefix, dependencies)
+}
+
+// prefixedSetMembers returns the members of the set (prefix + name) of each name (pipelined)
+//
+// Spec: https://redis.io/commands/smembers
+func prefixedSetMembers(conn redis.Conn, prefix string, names []string) ([][]string, error) {
+	sets := make([][]string, 0, len(names))
+	for chunk := range slices.Chunk(names, bulkChunkSize) {
+		p := NewPipeline(conn)
+		futures := make([]*Future[interface{}], len(chunk))
+		for i, name := range chunk {
+			futures[i] = p.Do(MembersCommand, prefix+name)
+		}
+		if err := p.Exec(); err != nil {
+			return nil, err
+		}
+		for _, future := range futures {
+			members, err := redis.Strings(future.Result())
+			if err != nil {
+				return nil, err
+			}
+			sets = append(sets, members)
+		}
+	}
+	return sets, nil
+}
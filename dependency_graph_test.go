@@ -0,0 +1,201 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestDependentsOf tests the methods DependentsOf() and DependenciesOf()
+func TestDependentsOf(t *testing.T) {
+	t.Run("reads both directions", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.Command(MembersCommand, DependencyPrefix+"user").Expect([]interface{}{[]byte("orders")})
+		conn.Command(MembersCommand, ReverseDependencyPrefix+"orders").Expect([]interface{}{[]byte("user")})
+
+		dependents, err := DependentsOf(context.Background(), client, "user")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"orders"}, dependents)
+
+		var dependencies []string
+		dependencies, err = DependenciesOf(context.Background(), client, "orders")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"user"}, dependencies)
+	})
+
+	t.Run("both directions using real redis", func(t *testing.T) {
+		if testing.Short() {
+			t.Skip("skipping live local redis tests")
+		}
+
+		client, conn, err := loadRealRedis(t)
+		require.NoError(t, err)
+		defer client.CloseAll(conn)
+		require.NoError(t, clearRealRedis(conn))
+
+		require.NoError(t, SetRaw(conn, "orders", testStringValue, "user", "team"))
+		require.NoError(t, SetManyRaw(conn, []BulkItem{{Key: "summary", Value: "1", Dependencies: []string{"user"}}}))
+
+		var dependents []string
+		dependents, err = DependentsOfRaw(conn, "user")
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"orders", "summary"}, dependents)
+
+		var dependencies []string
+		dependencies, err = DependenciesOfRaw(conn, "orders")
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"user", "team"}, dependencies)
+		dependencies, err = DependenciesOfRaw(conn, "summary")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"user"}, dependencies)
+	})
+}
+
+// TestKillByDependencyDryRun tests the method KillByDependencyDryRun()
+func TestKillByDependencyDryRun(t *testing.T) {
+	t.Run("lists the existing keys without deleting", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.Command(MembersCommand, DependencyPrefix+"user").Expect([]interface{}{[]byte("orders"), []byte("gone")})
+		conn.Command(MembersCommand, DependencyPrefix+"team").Expect([]interface{}{[]byte("orders")})
+		conn.Command(ExistsCommand, DependencyPrefix+"user").Expect(int64(1))
+		conn.Command(ExistsCommand, "orders").Expect(int64(1))
+		conn.Command(ExistsCommand, "gone").Expect(int64(0))
+		conn.Command(ExistsCommand, DependencyPrefix+"team").Expect(int64(1))
+		conn.Command(ExistsCommand, "user").Expect(int64(0))
+		conn.Command(ExistsCommand, "team").Expect(int64(1))
+		deleteCmd := conn.GenericCommand(DeleteCommand)
+		evalCmd := conn.GenericCommand(EvalCommand)
+
+		keys, err := KillByDependencyDryRun(context.Background(), client, "user", "team")
+		require.NoError(t, err)
+		assert.Equal(t, []string{DependencyPrefix + "user", "orders", DependencyPrefix + "team", "team"}, keys)
+		assert.False(t, deleteCmd.Called)
+		assert.False(t, evalCmd.Called)
+	})
+
+	t.Run("no keys", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		keys, err := KillByDependencyDryRunRaw(conn)
+		require.NoError(t, err)
+		assert.Empty(t, keys)
+	})
+
+	t.Run("read error", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.Command(MembersCommand, DependencyPrefix+"user").ExpectError(errTestLoader)
+
+		_, err := KillByDependencyDryRunRaw(conn, "user")
+		require.ErrorIs(t, err, errTestLoader)
+	})
+
+	t.Run("matches kill by dependency using real redis", func(t *testing.T) {
+		if testing.Short() {
+			t.Skip("skipping live local redis tests")
+		}
+
+		client, conn, err := loadRealRedis(t)
+		require.NoError(t, err)
+		defer client.CloseAll(conn)
+		require.NoError(t, clearRealRedis(conn))
+
+		require.NoError(t, SetRaw(conn, "orders", testStringValue, "user"))
+		require.NoError(t, SetRaw(conn, "user", testStringValue))
+
+		var keys []string
+		keys, err = KillByDependencyDryRunRaw(conn, "user")
+		require.NoError(t, err)
+		assert.Equal(t, []string{DependencyPrefix + "user", "orders", "user"}, keys)
+
+		var total int
+		total, err = KillByDependencyRaw(conn, "user")
+		require.NoError(t, err)
+		assert.Len(t, keys, total)
+	})
+}
+
+// TestExportDependencyGraph tests the method ExportDependencyGraph()
+func TestExportDependencyGraph(t *testing.T) {
+	t.Run("every dependency", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.Command(ScanCommand, "0", "MATCH", DependencyPrefix+AllKeysCommand).
+			Expect(scanPage("0", DependencyPrefix+"user", DependencyPrefix+"team"))
+		conn.Command(MembersCommand, DependencyPrefix+"user").Expect([]interface{}{[]byte("summary"), []byte("orders")})
+		conn.Command(MembersCommand, DependencyPrefix+"team").Expect([]interface{}{})
+
+		graph, err := ExportDependencyGraph(context.Background(), client)
+		require.NoError(t, err)
+		assert.Equal(t, DependencyGraph{"user": {"orders", "summary"}, "team": {}}, graph)
+
+		assert.Equal(t, "digraph dependencies {\n"+
+			"\t\"team\";\n"+
+			"\t\"user\" -> \"orders\";\n"+
+			"\t\"user\" -> \"summary\";\n"+
+			"}\n", graph.DOT())
+
+		var data []byte
+		data, err = graph.JSON()
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"team":[],"user":["orders","summary"]}`, string(data))
+	})
+
+	t.Run("given dependencies", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		scanCmd := conn.GenericCommand(ScanCommand)
+		conn.Command(MembersCommand, DependencyPrefix+"user").Expect([]interface{}{[]byte("orders")})
+
+		graph, err := ExportDependencyGraphRaw(conn, "user")
+		require.NoError(t, err)
+		assert.Equal(t, DependencyGraph{"user": {"orders"}}, graph)
+		assert.False(t, scanCmd.Called)
+	})
+
+	t.Run("scan error", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.Command(ScanCommand, "0", "MATCH", DependencyPrefix+AllKeysCommand).ExpectError(errTestLoader)
+
+		_, err := ExportDependencyGraphRaw(conn)
+		require.ErrorIs(t, err, errTestLoader)
+	})
+
+	t.Run("escapes DOT identifiers", func(t *testing.T) {
+		graph := DependencyGraph{`a"b`: {"c\\d"}}
+		assert.Equal(t, "digraph dependencies {\n\t\"a\\\"b\" -> \"c\\\\d\";\n}\n", graph.DOT())
+	})
+}
+
+// ExampleKillByDependencyDryRun is an example of the method KillByDependencyDryRun()
+func ExampleKillByDependencyDryRun() {
+	// Load a mocked redis for testing/examples
+	client, conn := loadMockRedis()
+
+	// Close connections at end of request
+	defer client.CloseAll(conn)
+
+	// Mock the dependency set and which keys exist
+	conn.Command(MembersCommand, DependencyPrefix+"user").Expect([]interface{}{[]byte("orders")})
+	conn.Command(ExistsCommand, DependencyPrefix+"user").Expect(int64(1))
+	conn.Command(ExistsCommand, "orders").Expect(int64(1))
+	conn.Command(ExistsCommand, "user").Expect(int64(0))
+
+	// See what would be deleted
+	keys, _ := KillByDependencyDryRun(context.Background(), client, "user")
+	fmt.Printf("would delete: %v", keys)
+	// Output:would delete: [depend:user orders]
+}
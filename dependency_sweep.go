@@ -0,0 +1,317 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// Define static errors to avoid dynamic error creation
+var (
+	ErrInvalidSweepInterval = errors.New("sweep interval must be positive")
+	errUnexpectedSweepReply = errors.New("unexpected sweep reply")
+)
+
+// ttlPersistent is the TTL reply for keys without an expiry
+const ttlPersistent int64 = -1
+
+// SweepStats reports what a dependency sweep did
+type SweepStats struct {
+	Sets    int // Dependency sets scanned
+	Checked int // Set members checked
+	Removed int // Members removed because their keys no longer exist
+	Expired int // Sets given a TTL (WithSweepExpireSets)
+}
+
+// SweepOption configures SweepDependencies and StartDependencySweeper
+type SweepOption func(*sweepOptions)
+
+type sweepOptions struct {
+	batchSize  int
+	rate       int
+	expireSets bool
+	report     func(SweepStats, error)
+}
+
+// WithSweepBatchSize sets how many members are checked per round trip (default: 500)
+// Values less than 1 are ignored.
+func WithSweepBatchSize(n int) SweepOption {
+	return func(o *sweepOptions) {
+		if n >= 1 {
+			o.batchSize = n
+		}
+	}
+}
+
+// WithSweepRateLimit caps how many members are checked per second (default: no limit)
+func WithSweepRateLimit(perSecond int) SweepOption {
+	return func(o *sweepOptions) {
+		if perSecond >= 0 {
+			o.rate = perSecond
+		}
+	}
+}
+
+// WithSweepExpireSets gives each dependency set a TTL matching its longest-lived member
+// Sets with a member that never expires are made persistent. A key linked after the sweep
+// can outlive the set until the next sweep extends it, so sweep more often than the
+// shortest TTL in use.
+func WithSweepExpireSets() SweepOption {
+	return func(o *sweepOptions) {
+		o.expireSets = true
+	}
+}
+
+// WithSweepReport calls fn after every sweep of StartDependencySweeper
+func WithSweepReport(fn func(SweepStats, error)) SweepOption {
+	return func(o *sweepOptions) {
+		o.report = fn
+	}
+}
+
+// SweepDependencies removes members whose keys no longer exist (e.g. expired after SetExp)
+// from every dependency set, with their reverse index. Sets are found with SCAN and read
+// with SSCAN, so redis is not blocked; empty sets are removed by redis.
+// Creates a new connection and closes connection at end of function call
+//
+// Custom connections use method: SweepDependenciesRaw()
+func SweepDependencies(ctx context.Context, client *Client, opts ...SweepOption) (SweepStats, error) {
+	conn, err := client.GetConnectionWithContext(ctx)
+	if err != nil {
+		return SweepStats{}, err
+	}
+	defer client.CloseConnection(conn)
+	return sweepDependencies(ctx, conn, opts...)
+}
+
+// SweepDependenciesRaw removes members whose keys no longer exist from every dependency set
+// Uses existing connection (does not close connection)
+//
+// Commands used:
+// https://redis.io/commands/scan
+// https://redis.io/commands/sscan
+// https://redis.io/commands/evalsha
+// https://redis.io/commands/pttl
+// https://redis.io/commands/srem
+// https://redis.io/commands/pexpire
+func SweepDependenciesRaw(conn redis.Conn, opts ...SweepOption) (SweepStats, error) {
+	return sweepDependencies(context.Background(), conn, opts...)
+}
+
+// dependencySweep holds the state of a single sweep
+type dependencySweep struct {
+	conn  redis.Conn
+	opts  sweepOptions
+	stats SweepStats
+	next  time.Time // earliest time the next batch may be checked (rate limit)
+}
+
+// sweepDependencies sweeps every dependency set
+func sweepDependencies(ctx context.Context, conn redis.Conn, opts ...SweepOption) (SweepStats, error) {
+	s := &dependencySweep{conn: conn, opts: sweepOptions{batchSize: defaultDeleteBatchSize}}
+	for _, opt := range opts {
+		opt(&s.opts)
+	}
+
+	sets := scanSeq(ctx, conn, ScanCommand, nil,
+		ScanOptions{Match: escapePattern(DependencyPrefix) + "*", Count: s.opts.batchSize}, redis.Strings)
+	for set, err := range sets {
+		if err != nil {
+			return s.stats, err
+		}
+		if err = s.sweepSet(ctx, set); err != nil {
+			return s.stats, err
+		}
+	}
+	return s.stats, nil
+}
+
+// sweepSet removes the missing members of one set (and sets its TTL if enabled)
+func (s *dependencySweep) sweepSet(ctx context.Context, set string) error {
+	s.stats.Sets++
+
+	var batch []string
+	var longest int64 // longest member TTL (ms), ttlPersistent if a member never expires
+	flush := func() error {
+		ttl, err := s.checkBatch(ctx, set, batch)
+		longest = longerTTL(longest, ttl)
+		batch = batch[:0]
+		return err
+	}
+
+	members := scanSeq(ctx, s.conn, SetScanCommand, set, ScanOptions{Count: s.opts.batchSize}, redis.Strings)
+	for member, err := range members {
+		if err != nil {
+			return err
+		}
+		if batch = append(batch, member); len(batch) >= s.opts.batchSize {
+			if err = flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+
+	if s.opts.expireSets {
+		return s.expireSet(set, longest)
+	}
+	return nil
+}
+
+// sweepBatchLua removes the members of the set KEYS[1] whose keys are gone, with their reverse sets
+// KEYS[2..n+1] are the members (n is ARGV[1]), followed by their reverse sets. Checking and removing
+// in one script means a member re-linked in the meantime is never removed.
+// Returns the number of members removed and the longest PTTL of the others (-1 if one never expires)
+const sweepBatchLua = `
+local count = tonumber(ARGV[1])
+local removed, longest = 0, 0
+for i = 2, count + 1 do
+	local ttl = redis.call("` + PTTLCommand + `", KEYS[i])
+	if ttl == -2 then
+		removed = removed + redis.call("` + RemoveMemberCommand + `", KEYS[1], KEYS[i])
+		redis.call("` + UnlinkCommand + `", KEYS[i + count])
+	elseif ttl == -1 or longest == -1 then
+		longest = -1
+	elseif ttl > longest then
+		longest = ttl
+	end
+end
+return {removed, longest}
+`
+
+// sweepBatchScript runs sweepBatchLua (EVALSHA, falling back to EVAL on NOSCRIPT)
+var sweepBatchScript = redis.NewScript(-1, sweepBatchLua)
+
+// checkBatch atomically removes the members of the batch whose keys are gone
+// Returns the longest TTL (ms) of the remaining members, ttlPersistent if one never expires
+func (s *dependencySweep) checkBatch(ctx context.Context, set string, batch []string) (int64, error) {
+	if len(batch) == 0 {
+		return 0, nil
+	}
+	if err := s.wait(ctx, len(batch)); err != nil {
+		return 0, err
+	}
+	s.stats.Checked += len(batch)
+
+	// The members are passed as KEYS (so namespaced connections prefix them), then their reverse sets
+	args := make([]interface{}, 0, 2*len(batch)+3)
+	args = append(args, 2*len(batch)+1, set)
+	args = append(args, toInterfaces(batch)...)
+	for _, member := range batch {
+		args = append(args, ReverseDependencyPrefix+member)
+	}
+	args = append(args, len(batch))
+
+	reply, err := redis.Int64s(sweepBatchScript.Do(s.conn, args...))
+	if err != nil {
+		return 0, err
+	}
+	if len(reply) != 2 {
+		return 0, fmt.Errorf("%w: %d values", errUnexpectedSweepReply, len(reply))
+	}
+	s.stats.Removed += int(reply[0])
+	return reply[1], nil
+}
+
+// expireSet sets the TTL of the set to its longest-lived member
+//
+// Commands used:
+// https://redis.io/commands/pexpire
+// https://redis.io/commands/persist
+func (s *dependencySweep) expireSet(set string, longest int64) (err error) {
+	switch {
+	case longest == ttlPersistent:
+		_, err = s.conn.Do(PersistCommand, set)
+	case longest > 0:
+		if _, err = s.conn.Do(PExpireCommand, set, longest); err == nil {
+			s.stats.Expired++
+		}
+	}
+	return err
+}
+
+// wait blocks until n more members may be checked under the rate limit
+func (s *dependencySweep) wait(ctx context.Context, n int) error {
+	if s.opts.rate <= 0 {
+		return nil
+	}
+	now := time.Now()
+	if s.next.Before(now) {
+		s.next = now
+	}
+	delay := s.next.Sub(now)
+	s.next = s.next.Add(time.Duration(n) * time.Second / time.Duration(s.opts.rate))
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// longerTTL returns the longer of two TTLs (ms), where ttlPersistent outlives everything
+func longerTTL(a, b int64) int64 {
+	if a == ttlPersistent || b == ttlPersistent {
+		return ttlPersistent
+	}
+	return max(a, b)
+}
+
+// DependencySweeper runs SweepDependencies in the background (see StartDependencySweeper)
+type DependencySweeper struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// StartDependencySweeper sweeps the dependency sets every interval until Stop() is called or
+// ctx is done. Use WithSweepReport to receive the stats and errors of each sweep.
+// Returns ErrInvalidSweepInterval if the interval is not positive
+func StartDependencySweeper(ctx context.Context, client *Client, interval time.Duration,
+	opts ...SweepOption,
+) (*DependencySweeper, error) {
+	if interval <= 0 {
+		return nil, ErrInvalidSweepInterval
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	s := &DependencySweeper{cancel: cancel, done: make(chan struct{})}
+	o := sweepOptions{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				stats, err := SweepDependencies(ctx, client, opts...)
+				if o.report != nil && ctx.Err() == nil {
+					o.report(stats, err)
+				}
+			}
+		}
+	}()
+	return s, nil
+}
+
+// Stop stops the sweeper and waits for a running sweep to end
+// It is safe to call Stop multiple times.
+func (s *DependencySweeper) Stop() {
+	s.cancel()
+	<-s.done
+}
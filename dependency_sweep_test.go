@@ -0,0 +1,206 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/rafaeljusto/redigomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testSweepSha is the SHA of the sweep batch script
+var testSweepSha = redis.NewScript(-1, sweepBatchLua).Hash()
+
+// mockSweepBatch mocks the sweep of the members of the set, replying with the removed count and longest TTL
+func mockSweepBatch(conn *redigomock.Conn, set string, removed, longest int64, members ...string) *redigomock.Cmd {
+	args := []interface{}{testSweepSha, 2*len(members) + 1, set}
+	args = append(args, toInterfaces(members)...)
+	for _, member := range members {
+		args = append(args, ReverseDependencyPrefix+member)
+	}
+	args = append(args, len(members))
+	return conn.Command(EvalCommand, args...).Expect([]interface{}{removed, longest})
+}
+
+// TestSweepDependencies tests the method SweepDependencies()
+func TestSweepDependencies(t *testing.T) {
+	t.Run("removes members whose keys are gone", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.Command(ScanCommand, "0", "MATCH", DependencyPrefix+"*", "COUNT", defaultDeleteBatchSize).
+			Expect(scanPage("0", DependencyPrefix+"user"))
+		conn.Command(SetScanCommand, DependencyPrefix+"user", "0", "COUNT", defaultDeleteBatchSize).
+			Expect(scanPage("0", "a", "b"))
+		sweepCmd := mockSweepBatch(conn, DependencyPrefix+"user", 1, ttlPersistent, "a", "b")
+
+		stats, err := SweepDependencies(context.Background(), client)
+		require.NoError(t, err)
+		assert.Equal(t, SweepStats{Sets: 1, Checked: 2, Removed: 1}, stats)
+		assert.True(t, sweepCmd.Called)
+	})
+
+	t.Run("expires sets with their longest-lived member", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.Command(ScanCommand, "0", "MATCH", DependencyPrefix+"*", "COUNT", 1).
+			Expect(scanPage("0", DependencyPrefix+"user", DependencyPrefix+"team"))
+		conn.Command(SetScanCommand, DependencyPrefix+"user", "0", "COUNT", 1).Expect(scanPage("0", "a", "b"))
+		conn.Command(SetScanCommand, DependencyPrefix+"team", "0", "COUNT", 1).Expect(scanPage("0", "b", "c"))
+		mockSweepBatch(conn, DependencyPrefix+"user", 0, 5000, "a")
+		mockSweepBatch(conn, DependencyPrefix+"user", 0, 1000, "b")
+		mockSweepBatch(conn, DependencyPrefix+"team", 0, 1000, "b")
+		mockSweepBatch(conn, DependencyPrefix+"team", 0, ttlPersistent, "c")
+		expireCmd := conn.Command(PExpireCommand, DependencyPrefix+"user", int64(5000)).Expect(int64(1))
+		persistCmd := conn.Command(PersistCommand, DependencyPrefix+"team").Expect(int64(0))
+
+		stats, err := SweepDependenciesRaw(conn, WithSweepBatchSize(1), WithSweepExpireSets(),
+			WithSweepRateLimit(100000))
+		require.NoError(t, err)
+		assert.Equal(t, SweepStats{Sets: 2, Checked: 4, Expired: 1}, stats)
+		assert.True(t, expireCmd.Called)
+		assert.True(t, persistCmd.Called)
+	})
+
+	t.Run("rate limit respects the context", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.Command(ScanCommand, "0", "MATCH", DependencyPrefix+"*", "COUNT", 1).
+			Expect(scanPage("0", DependencyPrefix+"user"))
+		conn.Command(SetScanCommand, DependencyPrefix+"user", "0", "COUNT", 1).Expect(scanPage("0", "a", "b"))
+		mockSweepBatch(conn, DependencyPrefix+"user", 0, ttlPersistent, "a")
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		stats, err := SweepDependencies(ctx, client, WithSweepBatchSize(1), WithSweepRateLimit(1))
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Equal(t, 1, stats.Checked)
+	})
+
+	t.Run("ttl error", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.Command(ScanCommand, "0", "MATCH", DependencyPrefix+"*", "COUNT", defaultDeleteBatchSize).
+			Expect(scanPage("0", DependencyPrefix+"user"))
+		conn.Command(SetScanCommand, DependencyPrefix+"user", "0", "COUNT", defaultDeleteBatchSize).
+			Expect(scanPage("0", "a"))
+		conn.GenericCommand(EvalCommand).ExpectError(errTestLoader)
+
+		_, err := SweepDependenciesRaw(conn)
+		require.ErrorIs(t, err, errTestLoader)
+	})
+
+	t.Run("unexpected script reply", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.Command(ScanCommand, "0", "MATCH", DependencyPrefix+"*", "COUNT", defaultDeleteBatchSize).
+			Expect(scanPage("0", DependencyPrefix+"user"))
+		conn.Command(SetScanCommand, DependencyPrefix+"user", "0", "COUNT", defaultDeleteBatchSize).
+			Expect(scanPage("0", "a"))
+		conn.GenericCommand(EvalCommand).Expect([]interface{}{int64(1)})
+
+		_, err := SweepDependenciesRaw(conn)
+		require.ErrorIs(t, err, errUnexpectedSweepReply)
+	})
+
+	t.Run("sweep using real redis", func(t *testing.T) {
+		if testing.Short() {
+			t.Skip("skipping live local redis tests")
+		}
+
+		client, conn, err := loadRealRedis(t)
+		require.NoError(t, err)
+		defer client.CloseAll(conn)
+		require.NoError(t, clearRealRedis(conn))
+
+		// "gone" stands in for a key that expired
+		require.NoError(t, SetRaw(conn, "gone", testStringValue, "user"))
+		require.NoError(t, SetExpRaw(conn, "kept", testStringValue, time.Minute, "user"))
+		_, err = conn.Do(DeleteCommand, "gone")
+		require.NoError(t, err)
+
+		var stats SweepStats
+		stats, err = SweepDependencies(context.Background(), client, WithSweepExpireSets())
+		require.NoError(t, err)
+		assert.Equal(t, 1, stats.Removed)
+		assert.Equal(t, 1, stats.Expired)
+
+		var members []string
+		members, err = DependentsOfRaw(conn, "user")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"kept"}, members)
+
+		var ttl int64
+		ttl, err = redis.Int64(conn.Do(PTTLCommand, DependencyPrefix+"user"))
+		require.NoError(t, err)
+		assert.Positive(t, ttl)
+
+		var found bool
+		found, err = ExistsRaw(conn, ReverseDependencyPrefix+"gone")
+		require.NoError(t, err)
+		assert.False(t, found)
+	})
+}
+
+// TestStartDependencySweeper tests the method StartDependencySweeper()
+func TestStartDependencySweeper(t *testing.T) {
+	client, conn := loadMockRedis(t)
+	defer client.CloseAll(conn)
+
+	conn.Command(ScanCommand, "0", "MATCH", DependencyPrefix+"*", "COUNT", defaultDeleteBatchSize).
+		Expect(scanPage("0"))
+
+	_, err := StartDependencySweeper(context.Background(), client, 0)
+	require.ErrorIs(t, err, ErrInvalidSweepInterval)
+
+	reports := make(chan SweepStats, 1)
+	sweeper, err := StartDependencySweeper(context.Background(), client, time.Millisecond,
+		WithSweepReport(func(stats SweepStats, err error) {
+			if err != nil {
+				return
+			}
+			select {
+			case reports <- stats:
+			default:
+			}
+		}))
+	require.NoError(t, err)
+
+	select {
+	case stats := <-reports:
+		assert.Equal(t, SweepStats{}, stats)
+	case <-time.After(time.Second):
+		t.Fatal("sweeper did not run")
+	}
+	sweeper.Stop()
+	sweeper.Stop()
+}
+
+// ExampleSweepDependencies is an example of the method SweepDependencies()
+func ExampleSweepDependencies() {
+	// Load a mocked redis for testing/examples
+	client, conn := loadMockRedis()
+
+	// Close connections at end of request
+	defer client.CloseAll(conn)
+
+	// Mock a dependency set holding an expired key
+	conn.Command(ScanCommand, "0", "MATCH", DependencyPrefix+"*", "COUNT", defaultDeleteBatchSize).
+		Expect(scanPage("0", DependencyPrefix+"user"))
+	conn.Command(SetScanCommand, DependencyPrefix+"user", "0", "COUNT", defaultDeleteBatchSize).
+		Expect(scanPage("0", "expired-key"))
+	mockSweepBatch(conn, DependencyPrefix+"user", 1, 0, "expired-key")
+
+	// Remove the dangling members
+	stats, _ := SweepDependencies(context.Background(), client)
+	fmt.Printf("checked: %d, removed: %d", stats.Checked, stats.Removed)
+	// Output:checked: 1, removed: 1
+}
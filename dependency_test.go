@@ -4,11 +4,35 @@ import (
 	"context"
 	"fmt"
 	"testing"
+	"time"
 
+	"github.com/gomodule/redigo/redis"
+	"github.com/rafaeljusto/redigomock"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
+// mockReverseDependencies mocks the reverse index SADD sent by linkDependencies()
+func mockReverseDependencies(conn *redigomock.Conn, key string, dependencies ...string) *redigomock.Cmd {
+	args := append([]interface{}{ReverseDependencyPrefix + key}, toInterfaces(dependencies)...)
+	return conn.Command(AddToSetCommand, args...)
+}
+
+// mockReverseExpire mocks the EXPIRE of the reverse index sent by linkDependenciesExp()
+func mockReverseExpire(conn *redigomock.Conn, key string, ttl time.Duration) *redigomock.Cmd {
+	return conn.Command(ExpireCommand, ReverseDependencyPrefix+key, int64(ttl.Seconds()))
+}
+
+// mockLinkDependencies mocks the MULTI block sent by linkDependencies()
+func mockLinkDependencies(conn *redigomock.Conn, key string, dependencies ...string) []*redigomock.Cmd {
+	commands := []*redigomock.Cmd{conn.Command(MultiCommand)}
+	for _, dependency := range dependencies {
+		commands = append(commands, conn.Command(AddToSetCommand, DependencyPrefix+dependency, key))
+	}
+	commands = append(commands, mockReverseDependencies(conn, key, dependencies...))
+	return append(commands, conn.Command(ExecuteCommand))
+}
+
 // TestDelete tests the method Delete()
 func TestDelete(t *testing.T) {
 	// todo: mock delete
@@ -96,7 +120,100 @@ func ExampleDelete() {
 
 // TestKillByDependency tests the method KillByDependency()
 func TestKillByDependency(t *testing.T) {
-	// todo: mock kill by dependency
+	t.Run("runs the script by sha", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		evalCmd := conn.Command(EvalCommand, killByDependencySha, 2,
+			DependencyPrefix+testDependantKey, DependencyPrefix+testKey, testDependantKey, testKey).Expect(int64(3))
+		conn.Command(DeleteCommand, testDependantKey, testKey).Expect(int64(1))
+
+		total, err := KillByDependency(context.Background(), client, testDependantKey, testKey)
+		require.NoError(t, err)
+		assert.Equal(t, 4, total)
+		assert.True(t, evalCmd.Called)
+	})
+
+	t.Run("sends the script on NOSCRIPT", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.Command(EvalCommand, killByDependencySha, 1, DependencyPrefix+testDependantKey, testDependantKey).
+			ExpectError(redis.Error("NOSCRIPT No matching script. Please use EVAL."))
+		evalCmd := conn.Command("EVAL", killByDependencyLua, 1, DependencyPrefix+testDependantKey, testDependantKey).
+			Expect(int64(2))
+		conn.Command(DeleteCommand, testDependantKey).Expect(int64(0))
+
+		total, err := KillByDependency(context.Background(), client, testDependantKey)
+		require.NoError(t, err)
+		assert.Equal(t, 2, total)
+		assert.True(t, evalCmd.Called)
+	})
+
+	t.Run("script error", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.Command(EvalCommand, killByDependencySha, 1, DependencyPrefix+testDependantKey, testDependantKey).
+			ExpectError(errTestLoader)
+
+		_, err := KillByDependencyRaw(conn, testDependantKey)
+		require.ErrorIs(t, err, errTestLoader)
+	})
+
+	t.Run("after script flush - real redis", func(t *testing.T) {
+		if testing.Short() {
+			t.Skip("skipping live local redis tests")
+		}
+
+		// Load redis
+		client, conn, err := loadRealRedis(t)
+		require.NoError(t, err)
+		defer client.CloseAll(conn)
+
+		// Start with a fresh db and no scripts loaded
+		require.NoError(t, clearRealRedis(conn, t))
+		_, err = conn.Do(ScriptCommand, "FLUSH")
+		require.NoError(t, err)
+
+		require.NoError(t, SetRaw(conn, testKey, testStringValue, testDependantKey))
+
+		var total int
+		total, err = KillByDependency(context.Background(), client, testDependantKey)
+		require.NoError(t, err)
+		assert.Equal(t, 2, total)
+	})
+
+	t.Run("removes the reverse dependency sets - real redis", func(t *testing.T) {
+		if testing.Short() {
+			t.Skip("skipping live local redis tests")
+		}
+
+		// Load redis
+		client, conn, err := loadRealRedis(t)
+		require.NoError(t, err)
+		defer client.CloseAll(conn)
+		require.NoError(t, clearRealRedis(conn, t))
+
+		// The script finds the reverse sets with and without a namespace
+		ctx := context.Background()
+		for _, c := range []*Client{client, client.WithNamespace(testNamespace)} {
+			require.NoError(t, Set(ctx, c, testKey, testStringValue, testDependantKey))
+			require.NoError(t, Set(ctx, c, testDependantKey, testStringValue, "root"))
+
+			var total int
+			total, err = KillByDependency(ctx, c, testDependantKey)
+			require.NoError(t, err)
+			assert.Equal(t, 3, total)
+
+			for _, key := range []string{testKey, testDependantKey} {
+				var found bool
+				found, err = Exists(ctx, c, ReverseDependencyPrefix+key)
+				require.NoError(t, err)
+				assert.False(t, found, c.Namespace()+key)
+			}
+		}
+	})
 
 	t.Run("no keys - real redis", func(t *testing.T) {
 		if testing.Short() {
@@ -371,3 +488,238 @@ func TestHashMapDependencyManagement(t *testing.T) {
 		assert.False(t, found)
 	})
 }
+
+// TestUnlinkDependencies tests the method UnlinkDependencies()
+func TestUnlinkDependencies(t *testing.T) {
+	t.Run("removes both directions of the links", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.Command(MultiCommand).Expect("OK")
+		userCmd := conn.Command(RemoveMemberCommand, DependencyPrefix+"user", "orders").Expect("QUEUED")
+		teamCmd := conn.Command(RemoveMemberCommand, DependencyPrefix+"team", "orders").Expect("QUEUED")
+		reverseCmd := conn.Command(RemoveMemberCommand, ReverseDependencyPrefix+"orders", "user", "team").
+			Expect("QUEUED")
+		conn.Command(ExecuteCommand).Expect([]interface{}{int64(1), int64(1), int64(2)})
+
+		err := UnlinkDependencies(context.Background(), client, "orders", "user", "team")
+		require.NoError(t, err)
+		assert.True(t, userCmd.Called)
+		assert.True(t, teamCmd.Called)
+		assert.True(t, reverseCmd.Called)
+	})
+
+	t.Run("no dependencies", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		multiCmd := conn.GenericCommand(MultiCommand)
+
+		require.NoError(t, UnlinkDependenciesRaw(conn, "orders"))
+		assert.False(t, multiCmd.Called)
+	})
+
+	t.Run("unlinked keys survive kill by dependency - real redis", func(t *testing.T) {
+		if testing.Short() {
+			t.Skip("skipping live local redis tests")
+		}
+
+		client, conn, err := loadRealRedis(t)
+		require.NoError(t, err)
+		defer client.CloseAll(conn)
+		require.NoError(t, clearRealRedis(conn, t))
+
+		require.NoError(t, SetRaw(conn, "orders", testStringValue, "user", "team"))
+		require.NoError(t, UnlinkDependenciesRaw(conn, "orders", "user"))
+
+		var dependencies []string
+		dependencies, err = DependenciesOfRaw(conn, "orders")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"team"}, dependencies)
+
+		_, err = KillByDependencyRaw(conn, "user")
+		require.NoError(t, err)
+
+		var found bool
+		found, err = ExistsRaw(conn, "orders")
+		require.NoError(t, err)
+		assert.True(t, found)
+	})
+}
+
+// TestReplaceDependencies tests the method ReplaceDependencies()
+func TestReplaceDependencies(t *testing.T) {
+	t.Run("swaps the links in a transaction", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.Command(WatchCommand, ReverseDependencyPrefix+"orders").Expect("OK")
+		conn.Command(MembersCommand, ReverseDependencyPrefix+"orders").
+			Expect([]interface{}{[]byte("user"), []byte("team")})
+		conn.Command(PTTLCommand, "orders").Expect(ttlPersistent)
+		conn.Command(MultiCommand).Expect("OK")
+		unlinkCmd := conn.Command(RemoveMemberCommand, DependencyPrefix+"user", "orders").Expect("QUEUED")
+		keepCmd := conn.Command(RemoveMemberCommand, DependencyPrefix+"team", "orders").Expect("QUEUED")
+		conn.Command(AddToSetCommand, DependencyPrefix+"team", "orders").Expect("QUEUED")
+		conn.Command(AddToSetCommand, DependencyPrefix+"account", "orders").Expect("QUEUED")
+		removeCmd := conn.Command(RemoveMemberCommand, ReverseDependencyPrefix+"orders", "user").Expect("QUEUED")
+		deleteCmd := conn.Command(DeleteCommand, ReverseDependencyPrefix+"orders").Expect("QUEUED")
+		reverseCmd := mockReverseDependencies(conn, "orders", "team", "account").Expect("QUEUED")
+		expireCmd := conn.GenericCommand(PExpireCommand).Expect("QUEUED")
+		conn.Command(ExecuteCommand).Expect([]interface{}{int64(1), int64(0), int64(1), int64(1), int64(1)})
+
+		err := ReplaceDependencies(context.Background(), client, "orders", "team", "account")
+		require.NoError(t, err)
+		assert.True(t, unlinkCmd.Called)
+		assert.False(t, keepCmd.Called)
+		assert.True(t, removeCmd.Called)
+		assert.False(t, deleteCmd.Called)
+		assert.True(t, reverseCmd.Called)
+		assert.False(t, expireCmd.Called, "keys without a ttl keep a persistent reverse index")
+	})
+
+	t.Run("reverse index keeps the ttl of an expiring key", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.Command(WatchCommand, ReverseDependencyPrefix+"orders").Expect("OK")
+		conn.Command(MembersCommand, ReverseDependencyPrefix+"orders").Expect([]interface{}{[]byte("user")})
+		conn.Command(PTTLCommand, "orders").Expect(int64(60000))
+		conn.Command(MultiCommand).Expect("OK")
+		conn.Command(RemoveMemberCommand, DependencyPrefix+"user", "orders").Expect("QUEUED")
+		conn.Command(AddToSetCommand, DependencyPrefix+"team", "orders").Expect("QUEUED")
+		conn.Command(RemoveMemberCommand, ReverseDependencyPrefix+"orders", "user").Expect("QUEUED")
+		mockReverseDependencies(conn, "orders", "team").Expect("QUEUED")
+		expireCmd := conn.Command(PExpireCommand, ReverseDependencyPrefix+"orders", int64(60000)).Expect("QUEUED")
+		conn.Command(ExecuteCommand).Expect([]interface{}{int64(1), int64(1), int64(1), int64(1), int64(1)})
+
+		require.NoError(t, ReplaceDependenciesRaw(conn, "orders", "team"))
+		assert.True(t, expireCmd.Called)
+	})
+
+	t.Run("no dependencies removes every link", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.Command(WatchCommand, ReverseDependencyPrefix+"orders").Expect("OK")
+		conn.Command(MembersCommand, ReverseDependencyPrefix+"orders").Expect([]interface{}{[]byte("user")})
+		conn.Command(PTTLCommand, "orders").Expect(ttlPersistent)
+		conn.Command(MultiCommand).Expect("OK")
+		unlinkCmd := conn.Command(RemoveMemberCommand, DependencyPrefix+"user", "orders").Expect("QUEUED")
+		conn.Command(RemoveMemberCommand, ReverseDependencyPrefix+"orders", "user").Expect("QUEUED")
+		conn.Command(ExecuteCommand).Expect([]interface{}{int64(1), int64(1)})
+
+		require.NoError(t, ReplaceDependenciesRaw(conn, "orders"))
+		assert.True(t, unlinkCmd.Called)
+	})
+
+	t.Run("conflict", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.Command(WatchCommand, ReverseDependencyPrefix+"orders").Expect("OK")
+		conn.Command(MembersCommand, ReverseDependencyPrefix+"orders").Expect([]interface{}{})
+		conn.Command(PTTLCommand, "orders").Expect(ttlPersistent)
+		conn.Command(MultiCommand).Expect("OK")
+		conn.Command(AddToSetCommand, DependencyPrefix+"user", "orders").Expect("QUEUED")
+		mockReverseDependencies(conn, "orders", "user").Expect("QUEUED")
+		conn.Command(ExecuteCommand).Expect(nil)
+
+		err := ReplaceDependenciesRaw(conn, "orders", "user")
+		require.ErrorIs(t, err, ErrTxConflict)
+	})
+
+	t.Run("read error", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.Command(WatchCommand, ReverseDependencyPrefix+"orders").Expect("OK")
+		conn.Command(MembersCommand, ReverseDependencyPrefix+"orders").ExpectError(errTestLoader)
+		conn.Command(UnwatchCommand).Expect("OK")
+
+		err := ReplaceDependenciesRaw(conn, "orders", "user")
+		require.ErrorIs(t, err, errTestLoader)
+	})
+
+	t.Run("re-parent - real redis", func(t *testing.T) {
+		if testing.Short() {
+			t.Skip("skipping live local redis tests")
+		}
+
+		client, conn, err := loadRealRedis(t)
+		require.NoError(t, err)
+		defer client.CloseAll(conn)
+		require.NoError(t, clearRealRedis(conn, t))
+
+		require.NoError(t, SetRaw(conn, "orders", testStringValue, "user", "team"))
+		require.NoError(t, ReplaceDependencies(context.Background(), client, "orders", "team", "account"))
+
+		var dependencies []string
+		dependencies, err = DependenciesOfRaw(conn, "orders")
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"team", "account"}, dependencies)
+
+		var dependents []string
+		dependents, err = DependentsOfRaw(conn, "user")
+		require.NoError(t, err)
+		assert.Empty(t, dependents)
+
+		// The old parent no longer invalidates the key, the new one does
+		_, err = KillByDependencyRaw(conn, "user")
+		require.NoError(t, err)
+		var found bool
+		found, err = ExistsRaw(conn, "orders")
+		require.NoError(t, err)
+		assert.True(t, found)
+
+		_, err = KillByDependencyRaw(conn, "account")
+		require.NoError(t, err)
+		found, err = ExistsRaw(conn, "orders")
+		require.NoError(t, err)
+		assert.False(t, found)
+	})
+
+	t.Run("re-parent an expiring key - real redis", func(t *testing.T) {
+		if testing.Short() {
+			t.Skip("skipping live local redis tests")
+		}
+
+		client, conn, err := loadRealRedis(t)
+		require.NoError(t, err)
+		defer client.CloseAll(conn)
+		require.NoError(t, clearRealRedis(conn, t))
+
+		require.NoError(t, SetExpRaw(conn, "orders", testStringValue, time.Minute, "user"))
+		require.NoError(t, ReplaceDependencies(context.Background(), client, "orders", "team"))
+
+		var ttl int64
+		ttl, err = redis.Int64(conn.Do(PTTLCommand, ReverseDependencyPrefix+"orders"))
+		require.NoError(t, err)
+		assert.Positive(t, ttl)
+		assert.LessOrEqual(t, ttl, time.Minute.Milliseconds())
+	})
+}
+
+// ExampleReplaceDependencies is an example of the method ReplaceDependencies()
+func ExampleReplaceDependencies() {
+	// Load a mocked redis for testing/examples
+	client, conn := loadMockRedis()
+
+	// Close connections at end of request
+	defer client.CloseAll(conn)
+
+	// Mock the swap from "user" to "account"
+	conn.Command(WatchCommand, ReverseDependencyPrefix+"orders").Expect("OK")
+	conn.Command(MembersCommand, ReverseDependencyPrefix+"orders").Expect([]interface{}{[]byte("user")})
+	conn.Command(MultiCommand).Expect("OK")
+	conn.Command(RemoveMemberCommand, DependencyPrefix+"user", "orders").Expect("QUEUED")
+	conn.Command(AddToSetCommand, DependencyPrefix+"account", "orders").Expect("QUEUED")
+	conn.Command(DeleteCommand, ReverseDependencyPrefix+"orders").Expect("QUEUED")
+	mockReverseDependencies(conn, "orders", "account").Expect("QUEUED")
+	conn.Command(ExecuteCommand).Expect([]interface{}{int64(1), int64(1), int64(1), int64(1)})
+
+	// Re-parent the cached key
+	_ = ReplaceDependencies(context.Background(), client, "orders", "account")
+	fmt.Print("orders now depends on account")
+	// Output:orders now depends on account
+}
@@ -0,0 +1,278 @@
+package cache
+
+import (
+	"context"
+	"strings"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// LibraryName is the name of the function library shipped with this package (see LoadFunctions)
+const LibraryName = "gocache"
+
+// Functions of the shipped library
+const (
+	killByDependencyFunction = LibraryName + "_kill_by_dependency"
+	lockFunction             = LibraryName + "_lock"
+	releaseLockFunction      = LibraryName + "_release_lock"
+	fencedLockFunction       = LibraryName + "_fenced_lock"
+	setIfTokenFunction       = LibraryName + "_set_if_token_current"
+)
+
+// gocacheLibrary is the shipped function library (redis 7+): the dependency kill, the
+// lock and the fencing token scripts, registered as functions. Each function is the script
+// itself, taking KEYS and ARGV as its arguments.
+const gocacheLibrary = `#!lua name=` + LibraryName + `
+
+local function kill_by_dependency(KEYS, ARGV)` + killByDependencyBody + `end
+
+local function lock(KEYS, ARGV)` + lockScript + `end
+
+local function release_lock(KEYS, ARGV)` + releaseLockScript + `end
+
+local function fenced_lock(KEYS, ARGV)` + fencedLockScript + `end
+
+local function set_if_token_current(KEYS, ARGV)` + setIfTokenScript + `end
+
+redis.register_function("` + killByDependencyFunction + `", kill_by_dependency)
+redis.register_function("` + lockFunction + `", lock)
+redis.register_function("` + releaseLockFunction + `", release_lock)
+redis.register_function("` + fencedLockFunction + `", fenced_lock)
+redis.register_function("` + setIfTokenFunction + `", set_if_token_current)
+`
+
+// FunctionLibrary is a library returned by FunctionList()
+type FunctionLibrary struct {
+	Name      string
+	Engine    string
+	Functions []string
+}
+
+// shippedScript is a script that is also part of the shipped function library
+type shippedScript struct {
+	function string
+	script   *redis.Script // takes the number of keys as its first argument
+}
+
+// do runs the function when the library is loaded, otherwise the script (EVALSHA, falling
+// back to EVAL on NOSCRIPT). The function is skipped if the server no longer has it.
+func (s shippedScript) do(conn redis.Conn, functions bool, keys []string, args ...interface{}) (interface{}, error) {
+	keysAndArgs := make([]interface{}, 0, 1+len(keys)+len(args))
+	keysAndArgs = append(keysAndArgs, len(keys))
+	keysAndArgs = append(keysAndArgs, toInterfaces(keys)...)
+	keysAndArgs = append(keysAndArgs, args...)
+
+	if functions {
+		reply, err := conn.Do(FunctionCallCommand, append([]interface{}{s.function}, keysAndArgs...)...)
+		if !isMissingFunction(err) {
+			return reply, err
+		}
+	}
+	return s.script.Do(conn, keysAndArgs...)
+}
+
+// LoadFunctions loads (or replaces) the shipped function library and makes the client use it
+// for the dependency kill and locks. Functions survive restarts and replicate, unlike scripts.
+// Servers before redis 7 return an error and the client keeps using the scripts.
+// This method runs on Connect() (in dependency mode)
+func (c *Client) LoadFunctions(ctx context.Context) error {
+	if _, err := FunctionLoad(ctx, c, gocacheLibrary, true); err != nil {
+		return err
+	}
+	c.root().functions.Store(true)
+	return nil
+}
+
+// functionsLoaded reports whether the shipped library was loaded by LoadFunctions()
+func (c *Client) functionsLoaded() bool {
+	return c.root().functions.Load()
+}
+
+// FunctionLoad loads a function library (the code starts with "#!lua name=<library>")
+// Set replace to overwrite an existing library of the same name. Returns the library name.
+// Creates a new connection and closes connection at end of function call
+//
+// Custom connections use method: FunctionLoadRaw()
+func FunctionLoad(ctx context.Context, client *Client, code string, replace bool) (string, error) {
+	conn, err := client.GetConnectionWithContext(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer client.CloseConnection(conn)
+	return FunctionLoadRaw(conn, code, replace)
+}
+
+// FunctionLoadRaw loads a function library (the code starts with "#!lua name=<library>")
+// Uses existing connection (does not close connection)
+//
+// Spec: https://redis.io/commands/function-load
+func FunctionLoadRaw(conn redis.Conn, code string, replace bool) (string, error) {
+	args := []interface{}{LoadCommand}
+	if replace {
+		args = append(args, "REPLACE")
+	}
+	return redis.String(conn.Do(FunctionCommand, append(args, code)...))
+}
+
+// FunctionList returns the loaded function libraries, optionally filtered by a name pattern
+// Creates a new connection and closes connection at end of function call
+//
+// Custom connections use method: FunctionListRaw()
+func FunctionList(ctx context.Context, client *Client, pattern string) ([]FunctionLibrary, error) {
+	conn, err := client.GetConnectionWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer client.CloseConnection(conn)
+	return FunctionListRaw(conn, pattern)
+}
+
+// FunctionListRaw returns the loaded function libraries, optionally filtered by a name pattern
+// Uses existing connection (does not close connection)
+//
+// Spec: https://redis.io/commands/function-list
+func FunctionListRaw(conn redis.Conn, pattern string) ([]FunctionLibrary, error) {
+	args := []interface{}{"LIST"}
+	if len(pattern) > 0 {
+		args = append(args, "LIBRARYNAME", pattern)
+	}
+	return parseFunctionLibraries(conn.Do(FunctionCommand, args...))
+}
+
+// FunctionDelete deletes a function library
+// Deleting the shipped library makes the client fall back to the scripts
+// Creates a new connection and closes connection at end of function call
+//
+// Custom connections use method: FunctionDeleteRaw()
+func FunctionDelete(ctx context.Context, client *Client, library string) error {
+	conn, err := client.GetConnectionWithContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.CloseConnection(conn)
+	if err = FunctionDeleteRaw(conn, library); err == nil && library == LibraryName {
+		client.root().functions.Store(false)
+	}
+	return err
+}
+
+// FunctionDeleteRaw deletes a function library
+// Uses existing connection (does not close connection)
+//
+// Spec: https://redis.io/commands/function-delete
+func FunctionDeleteRaw(conn redis.Conn, library string) error {
+	_, err := conn.Do(FunctionCommand, "DELETE", library)
+	return err
+}
+
+// FunctionCall calls a function (keys are prefixed on namespaced clients)
+// Creates a new connection and closes connection at end of function call
+//
+// Custom connections use method: FunctionCallRaw()
+func FunctionCall(ctx context.Context, client *Client, function string, keys []string,
+	args ...interface{},
+) (interface{}, error) {
+	conn, err := client.GetConnectionWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer client.CloseConnection(conn)
+	return FunctionCallRaw(conn, function, keys, args...)
+}
+
+// FunctionCallRaw calls a function
+// Uses existing connection (does not close connection)
+//
+// Spec: https://redis.io/commands/fcall
+func FunctionCallRaw(conn redis.Conn, function string, keys []string, args ...interface{}) (interface{}, error) {
+	return conn.Do(FunctionCallCommand, functionCallArgs(function, keys, args)...)
+}
+
+// FunctionCallReadOnly calls a read-only function (can run on replicas)
+// Creates a new connection and closes connection at end of function call
+//
+// Custom connections use method: FunctionCallReadOnlyRaw()
+func FunctionCallReadOnly(ctx context.Context, client *Client, function string, keys []string,
+	args ...interface{},
+) (interface{}, error) {
+	conn, err := client.GetConnectionWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer client.CloseConnection(conn)
+	return FunctionCallReadOnlyRaw(conn, function, keys, args...)
+}
+
+// FunctionCallReadOnlyRaw calls a read-only function (can run on replicas)
+// Uses existing connection (does not close connection)
+//
+// Spec: https://redis.io/commands/fcall_ro
+func FunctionCallReadOnlyRaw(conn redis.Conn, function string, keys []string,
+	args ...interface{},
+) (interface{}, error) {
+	return conn.Do(FunctionCallReadOnlyCmd, functionCallArgs(function, keys, args)...)
+}
+
+// functionCallArgs builds the arguments of FCALL/FCALL_RO: function, numkeys, keys..., args...
+func functionCallArgs(function string, keys []string, args []interface{}) []interface{} {
+	out := make([]interface{}, 0, 2+len(keys)+len(args))
+	out = append(out, function, len(keys))
+	out = append(out, toInterfaces(keys)...)
+	return append(out, args...)
+}
+
+// parseFunctionLibraries parses the reply of FUNCTION LIST
+// Each library is a list of field/value pairs; "functions" holds one such list per function
+func parseFunctionLibraries(reply interface{}, err error) ([]FunctionLibrary, error) {
+	libraries, err := redis.Values(reply, err)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]FunctionLibrary, 0, len(libraries))
+	for _, library := range libraries {
+		var fields map[string]interface{}
+		if fields, err = pairsToMap(library); err != nil {
+			return nil, err
+		}
+		lib := FunctionLibrary{}
+		lib.Name, _ = redis.String(fields["library_name"], nil)
+		lib.Engine, _ = redis.String(fields["engine"], nil)
+		functions, _ := redis.Values(fields["functions"], nil)
+		for _, function := range functions {
+			var functionFields map[string]interface{}
+			if functionFields, err = pairsToMap(function); err != nil {
+				return nil, err
+			}
+			name, _ := redis.String(functionFields["name"], nil)
+			lib.Functions = append(lib.Functions, name)
+		}
+		out = append(out, lib)
+	}
+	return out, nil
+}
+
+// pairsToMap converts a field/value list reply into a map
+func pairsToMap(reply interface{}) (map[string]interface{}, error) {
+	values, err := redis.Values(reply, nil)
+	if err != nil {
+		return nil, err
+	}
+	fields := make(map[string]interface{}, len(values)/2)
+	for i := 0; i+1 < len(values); i += 2 {
+		var field string
+		if field, err = redis.String(values[i], nil); err != nil {
+			return nil, err
+		}
+		fields[field] = values[i+1]
+	}
+	return fields, nil
+}
+
+// isMissingFunction reports whether the server does not have the function (or functions at all)
+func isMissingFunction(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := err.Error()
+	return strings.HasPrefix(msg, "ERR Function not found") || strings.HasPrefix(msg, "ERR unknown command")
+}
@@ -0,0 +1,262 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testFunctionList is a FUNCTION LIST reply with the shipped library
+var testFunctionList = []interface{}{
+	[]interface{}{
+		[]byte("library_name"), []byte(LibraryName),
+		[]byte("engine"), []byte("LUA"),
+		[]byte("functions"), []interface{}{
+			[]interface{}{[]byte("name"), []byte(lockFunction), []byte("description"), nil},
+			[]interface{}{[]byte("name"), []byte(releaseLockFunction), []byte("description"), nil},
+		},
+	},
+}
+
+// TestFunctionLoad tests the methods FunctionLoad() and LoadFunctions()
+func TestFunctionLoad(t *testing.T) {
+	t.Run("load and replace", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.Command(FunctionCommand, LoadCommand, "code").Expect([]byte("lib"))
+		conn.Command(FunctionCommand, LoadCommand, "REPLACE", "code").Expect([]byte("lib"))
+
+		name, err := FunctionLoad(context.Background(), client, "code", false)
+		require.NoError(t, err)
+		assert.Equal(t, "lib", name)
+
+		name, err = FunctionLoadRaw(conn, "code", true)
+		require.NoError(t, err)
+		assert.Equal(t, "lib", name)
+	})
+
+	t.Run("load the shipped library", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.Command(FunctionCommand, LoadCommand, "REPLACE", gocacheLibrary).Expect([]byte(LibraryName))
+
+		require.NoError(t, client.LoadFunctions(context.Background()))
+		assert.True(t, client.functionsLoaded())
+		assert.True(t, client.WithNamespace(testNamespace).functionsLoaded())
+	})
+
+	t.Run("server without functions", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.Command(FunctionCommand, LoadCommand, "REPLACE", gocacheLibrary).
+			ExpectError(redis.Error("ERR unknown command 'FUNCTION'"))
+
+		require.Error(t, client.LoadFunctions(context.Background()))
+		assert.False(t, client.functionsLoaded())
+	})
+}
+
+// TestFunctionList tests the method FunctionList()
+func TestFunctionList(t *testing.T) {
+	client, conn := loadMockRedis(t)
+	defer client.CloseAll(conn)
+
+	conn.Command(FunctionCommand, "LIST").Expect(testFunctionList)
+	conn.Command(FunctionCommand, "LIST", "LIBRARYNAME", "go*").Expect([]interface{}{})
+
+	libraries, err := FunctionList(context.Background(), client, "")
+	require.NoError(t, err)
+	assert.Equal(t, []FunctionLibrary{{
+		Name:      LibraryName,
+		Engine:    "LUA",
+		Functions: []string{lockFunction, releaseLockFunction},
+	}}, libraries)
+
+	libraries, err = FunctionListRaw(conn, "go*")
+	require.NoError(t, err)
+	assert.Empty(t, libraries)
+
+	_, err = parseFunctionLibraries([]interface{}{[]byte("not a library")}, nil)
+	require.Error(t, err)
+}
+
+// TestFunctionDelete tests the method FunctionDelete()
+func TestFunctionDelete(t *testing.T) {
+	client, conn := loadMockRedis(t)
+	defer client.CloseAll(conn)
+	client.functions.Store(true)
+
+	conn.Command(FunctionCommand, "DELETE", "other").Expect("OK")
+	conn.Command(FunctionCommand, "DELETE", LibraryName).Expect("OK")
+
+	require.NoError(t, FunctionDelete(context.Background(), client, "other"))
+	assert.True(t, client.functionsLoaded())
+
+	require.NoError(t, FunctionDelete(context.Background(), client, LibraryName))
+	assert.False(t, client.functionsLoaded())
+}
+
+// TestFunctionCall tests the methods FunctionCall() and FunctionCallReadOnly()
+func TestFunctionCall(t *testing.T) {
+	t.Run("call", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.Command(FunctionCallCommand, "fn", 1, testKey, "arg").Expect(int64(1))
+		conn.Command(FunctionCallReadOnlyCmd, "fn_ro", 0).Expect([]byte("value"))
+
+		total, err := redis.Int(FunctionCall(context.Background(), client, "fn", []string{testKey}, "arg"))
+		require.NoError(t, err)
+		assert.Equal(t, 1, total)
+
+		var value string
+		value, err = redis.String(FunctionCallReadOnly(context.Background(), client, "fn_ro", nil))
+		require.NoError(t, err)
+		assert.Equal(t, "value", value)
+	})
+
+	t.Run("namespaced keys", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		callCmd := conn.Command(FunctionCallCommand, "fn", 2, testNamespace+"a", testNamespace+"b", "a").
+			Expect(int64(1))
+
+		ns := client.WithNamespace(testNamespace)
+		_, err := FunctionCall(context.Background(), ns, "fn", []string{"a", "b"}, "a")
+		require.NoError(t, err)
+		assert.True(t, callCmd.Called)
+	})
+}
+
+// TestShippedFunctions tests that locks and the dependency kill prefer the library functions
+func TestShippedFunctions(t *testing.T) {
+	t.Run("uses functions when loaded", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+		client.functions.Store(true)
+
+		lockCmd := conn.Command(FunctionCallCommand, lockFunction, 2, testKey, FencingTokenPrefix+testKey, "secret",
+			int64(10)).Expect(int64(1))
+		releaseCmd := conn.Command(FunctionCallCommand, releaseLockFunction, 1, testKey, "secret", LockReleaseChannel).
+			Expect(int64(1))
+		killCmd := conn.Command(FunctionCallCommand, killByDependencyFunction, 1, DependencyPrefix+testKey, testKey).
+			Expect(int64(2))
+		conn.Command(DeleteCommand, testKey).Expect(int64(1))
+
+		locked, err := WriteLock(context.Background(), client, testKey, "secret", 10)
+		require.NoError(t, err)
+		assert.True(t, locked)
+
+		var released bool
+		released, err = ReleaseLock(context.Background(), client, testKey, "secret")
+		require.NoError(t, err)
+		assert.True(t, released)
+
+		var total int
+		total, err = KillByDependency(context.Background(), client, testKey)
+		require.NoError(t, err)
+		assert.Equal(t, 3, total)
+
+		assert.True(t, lockCmd.Called)
+		assert.True(t, releaseCmd.Called)
+		assert.True(t, killCmd.Called)
+	})
+
+	t.Run("falls back to the script when the function is missing", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+		client.functions.Store(true)
+
+		conn.Command(FunctionCallCommand, lockFunction, 2, testKey, FencingTokenPrefix+testKey, "secret", int64(10)).
+			ExpectError(redis.Error("ERR Function not found"))
+		evalCmd := conn.Command(EvalCommand, redis.NewScript(2, lockScript).Hash(), 2, testKey, FencingTokenPrefix+testKey,
+			"secret", int64(10)).Expect(int64(1))
+
+		locked, err := WriteLock(context.Background(), client, testKey, "secret", 10)
+		require.NoError(t, err)
+		assert.True(t, locked)
+		assert.True(t, evalCmd.Called)
+	})
+
+	t.Run("function errors are returned", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+		client.functions.Store(true)
+
+		conn.Command(FunctionCallCommand, releaseLockFunction, 1, testKey, "secret", LockReleaseChannel).
+			ExpectError(errTestLoader)
+
+		_, err := ReleaseLock(context.Background(), client, testKey, "secret")
+		require.ErrorIs(t, err, errTestLoader)
+	})
+
+	t.Run("library functions are the scripts", func(t *testing.T) {
+		for _, script := range []string{
+			killByDependencyBody, lockScript, releaseLockScript, fencedLockScript, setIfTokenScript,
+		} {
+			assert.Contains(t, gocacheLibrary, "(KEYS, ARGV)"+script+"end\n")
+		}
+		assert.Contains(t, killByDependencyLua, killByDependencyBody)
+	})
+
+	t.Run("shipped library using real redis", func(t *testing.T) {
+		if testing.Short() {
+			t.Skip("skipping live local redis tests")
+		}
+
+		client, conn, err := loadRealRedis(t)
+		require.NoError(t, err)
+		defer client.CloseAll(conn)
+		require.NoError(t, clearRealRedis(conn))
+
+		require.NoError(t, client.LoadFunctions(context.Background()))
+		var libraries []FunctionLibrary
+		libraries, err = FunctionListRaw(conn, LibraryName)
+		require.NoError(t, err)
+		require.Len(t, libraries, 1)
+		assert.ElementsMatch(t, []string{
+			killByDependencyFunction, lockFunction, releaseLockFunction, fencedLockFunction, setIfTokenFunction,
+		}, libraries[0].Functions)
+
+		var locked bool
+		locked, err = WriteLock(context.Background(), client, testKey, "secret", 10)
+		require.NoError(t, err)
+		assert.True(t, locked)
+		_, err = WriteLock(context.Background(), client, testKey, "other", 10)
+		require.ErrorIs(t, err, ErrLockMismatch)
+
+		require.NoError(t, SetRaw(conn, "dependent", testStringValue, testDependantKey))
+		var total int
+		total, err = KillByDependency(context.Background(), client, testDependantKey)
+		require.NoError(t, err)
+		assert.Equal(t, 2, total)
+
+		require.NoError(t, FunctionDelete(context.Background(), client, LibraryName))
+		assert.False(t, client.functionsLoaded())
+	})
+}
+
+// ExampleFunctionCall is an example of the method FunctionCall()
+func ExampleFunctionCall() {
+	// Load a mocked redis for testing/examples
+	client, conn := loadMockRedis()
+
+	// Close connections at end of request
+	defer client.CloseAll(conn)
+
+	// Mock the reply
+	conn.Command(FunctionCallCommand, "incr_by", 1, testKey, 2).Expect(int64(2))
+
+	// Call a function from a library loaded with FunctionLoad()
+	total, _ := redis.Int(FunctionCall(context.Background(), client, "incr_by", []string{testKey}, 2))
+	fmt.Printf("total: %d", total)
+	// Output:total: 2
+}
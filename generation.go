@@ -0,0 +1,224 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+const (
+	// defaultGenerationCacheTTL is how long a generation is cached in-process (see SetGenerationCacheTTL)
+	defaultGenerationCacheTTL = time.Second
+
+	// maxGenerationCacheEntries is the maximum number of generations cached in-process
+	maxGenerationCacheEntries = 10000
+)
+
+// generationCache holds the generations read recently, keyed by their counter key (with the namespace)
+type generationCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration // 0: defaultGenerationCacheTTL, negative: disabled
+	entries map[string]generationEntry
+}
+
+// generationEntry is a cached generation
+type generationEntry struct {
+	generation int64
+	expires    time.Time
+}
+
+// get returns the cached generation of the counter key
+func (g *generationCache) get(key string, now time.Time) (int64, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	entry, ok := g.entries[key]
+	if !ok || !now.Before(entry.expires) {
+		return 0, false
+	}
+	return entry.generation, true
+}
+
+// set caches the generation of the counter key
+// A newer generation is never replaced by an older one read concurrently.
+func (g *generationCache) set(key string, generation int64, now time.Time) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	ttl := g.ttl
+	switch {
+	case ttl < 0:
+		return
+	case ttl == 0:
+		ttl = defaultGenerationCacheTTL
+	}
+	if g.entries == nil {
+		g.entries = make(map[string]generationEntry)
+	}
+	if entry, ok := g.entries[key]; ok && now.Before(entry.expires) && entry.generation > generation {
+		return
+	}
+	if _, ok := g.entries[key]; !ok && len(g.entries) >= maxGenerationCacheEntries {
+		g.evict(now)
+	}
+	g.entries[key] = generationEntry{generation: generation, expires: now.Add(ttl)}
+}
+
+// evict drops the expired entries, or any entry if none has expired, to make room for one more
+func (g *generationCache) evict(now time.Time) {
+	for key, entry := range g.entries {
+		if !now.Before(entry.expires) {
+			delete(g.entries, key)
+		}
+	}
+	for key := range g.entries {
+		if len(g.entries) < maxGenerationCacheEntries {
+			return
+		}
+		delete(g.entries, key)
+	}
+}
+
+// SetGenerationCacheTTL sets how long generations are cached in-process (default: 1 second)
+// Other processes see a bumped generation after at most this long. A negative ttl disables the cache.
+func (c *Client) SetGenerationCacheTTL(ttl time.Duration) {
+	g := &c.root().generations
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.ttl = ttl
+	g.entries = nil
+}
+
+// GenerationalKey returns the key stored for the generation of the namespace ("namespace":generation:key)
+// The namespace is quoted, so namespaces and keys containing ":" cannot produce the same key
+func GenerationalKey(namespace string, generation int64, key string) string {
+	return strconv.Quote(namespace) + ":" + strconv.FormatInt(generation, 10) + ":" + key
+}
+
+// Generation returns the current generation of the namespace (0 until it is first bumped)
+// The generation is cached in-process briefly (see SetGenerationCacheTTL)
+// Creates a new connection and closes connection at end of function call
+//
+// Custom connections use method: GenerationRaw()
+func Generation(ctx context.Context, client *Client, namespace string) (int64, error) {
+	key := client.physicalKeys(GenerationPrefix + namespace)[0]
+	if generation, ok := client.root().generations.get(key, time.Now()); ok {
+		return generation, nil
+	}
+
+	conn, err := client.GetConnectionWithContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer client.CloseConnection(conn)
+
+	var generation int64
+	if generation, err = GenerationRaw(conn, namespace); err != nil {
+		return 0, err
+	}
+	client.root().generations.set(key, generation, time.Now())
+	return generation, nil
+}
+
+// GenerationRaw returns the current generation of the namespace (0 until it is first bumped)
+// Uses existing connection (does not close connection)
+//
+// Spec: https://redis.io/commands/get
+func GenerationRaw(conn redis.Conn, namespace string) (int64, error) {
+	generation, err := redis.Int64(conn.Do(GetCommand, GenerationPrefix+namespace))
+	if errors.Is(err, redis.ErrNil) {
+		return 0, nil
+	}
+	return generation, err
+}
+
+// BumpGeneration invalidates every key of the namespace in O(1) by incrementing its generation
+// Keys of older generations are no longer read and are left to expire (set them with a ttl).
+// Returns the new generation, which this process uses immediately.
+// Creates a new connection and closes connection at end of function call
+//
+// Custom connections use method: BumpGenerationRaw()
+func BumpGeneration(ctx context.Context, client *Client, namespace string) (int64, error) {
+	conn, err := client.GetConnectionWithContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer client.CloseConnection(conn)
+
+	var generation int64
+	if generation, err = BumpGenerationRaw(conn, namespace); err != nil {
+		return 0, err
+	}
+	client.root().generations.set(client.physicalKeys(GenerationPrefix + namespace)[0], generation, time.Now())
+	return generation, nil
+}
+
+// BumpGenerationRaw invalidates every key of the namespace by incrementing its generation
+// Uses existing connection (does not close connection)
+//
+// Spec: https://redis.io/commands/incr
+func BumpGenerationRaw(conn redis.Conn, namespace string) (int64, error) {
+	return redis.Int64(conn.Do(IncrementCommand, GenerationPrefix+namespace))
+}
+
+// GetGenerational gets the key of the namespace's current generation and decodes it into T
+// A nil codec defaults to JSONCodec
+// Creates a new connection and closes connection at end of function call
+//
+// Custom connections use method: GetGenerationalRaw()
+func GetGenerational[T any](ctx context.Context, client *Client, codec Codec, namespace, key string) (T, error) {
+	generation, err := Generation(ctx, client, namespace)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return GetAs[T](ctx, client, codec, GenerationalKey(namespace, generation, key))
+}
+
+// GetGenerationalRaw gets the key of the namespace's current generation and decodes it into T
+// The generation is read on every call (no in-process cache)
+// Uses existing connection (does not close connection)
+//
+// Uses methods: GenerationRaw(), GetAsRaw()
+func GetGenerationalRaw[T any](conn redis.Conn, codec Codec, namespace, key string) (T, error) {
+	generation, err := GenerationRaw(conn, namespace)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return GetAsRaw[T](conn, codec, GenerationalKey(namespace, generation, key))
+}
+
+// SetGenerational encodes the value and stores it under the key of the namespace's current
+// generation, linking each dependency. Use a ttl so keys of older generations expire.
+// A nil codec defaults to JSONCodec, a ttl of 0 stores the key without expiration
+// Creates a new connection and closes connection at end of function call
+//
+// Custom connections use method: SetGenerationalRaw()
+func SetGenerational[T any](ctx context.Context, client *Client, codec Codec, namespace, key string, value T,
+	ttl time.Duration, dependencies ...string,
+) error {
+	generation, err := Generation(ctx, client, namespace)
+	if err != nil {
+		return err
+	}
+	return SetAs(ctx, client, codec, GenerationalKey(namespace, generation, key), value, ttl, dependencies...)
+}
+
+// SetGenerationalRaw encodes the value and stores it under the key of the namespace's current
+// generation, linking each dependency
+// The generation is read on every call (no in-process cache)
+// Uses existing connection (does not close connection)
+//
+// Uses methods: GenerationRaw(), SetAsRaw()
+func SetGenerationalRaw[T any](conn redis.Conn, codec Codec, namespace, key string, value T,
+	ttl time.Duration, dependencies ...string,
+) error {
+	generation, err := GenerationRaw(conn, namespace)
+	if err != nil {
+		return err
+	}
+	return SetAsRaw(conn, codec, GenerationalKey(namespace, generation, key), value, ttl, dependencies...)
+}
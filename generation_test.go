@@ -0,0 +1,225 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestGenerationalKey tests the method GenerationalKey()
+func TestGenerationalKey(t *testing.T) {
+	assert.Equal(t, `"tenant-1":0:orders`, GenerationalKey("tenant-1", 0, "orders"))
+	assert.Equal(t, `"tenant-1":42:orders`, GenerationalKey("tenant-1", 42, "orders"))
+	assert.NotEqual(t, GenerationalKey("t:1", 0, "k"), GenerationalKey("t", 1, "0:k"))
+	assert.NotEqual(t, GenerationalKey(`t":1`, 0, "k"), GenerationalKey("t", 1, `":0:k`))
+}
+
+// TestGeneration tests the methods Generation() and BumpGeneration()
+func TestGeneration(t *testing.T) {
+	t.Run("missing counter is generation 0", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.Command(GetCommand, GenerationPrefix+"tenant-1").Expect(nil)
+
+		generation, err := GenerationRaw(conn, "tenant-1")
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), generation)
+	})
+
+	t.Run("cached in-process", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		getCmd := conn.Command(GetCommand, GenerationPrefix+"tenant-1").Expect([]byte("3"))
+
+		for range 3 {
+			generation, err := Generation(context.Background(), client, "tenant-1")
+			require.NoError(t, err)
+			assert.Equal(t, int64(3), generation)
+		}
+		assert.Equal(t, 1, conn.Stats(getCmd))
+	})
+
+	t.Run("cache disabled", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+		client.SetGenerationCacheTTL(-1)
+
+		getCmd := conn.Command(GetCommand, GenerationPrefix+"tenant-1").Expect([]byte("3"))
+
+		for range 2 {
+			_, err := Generation(context.Background(), client, "tenant-1")
+			require.NoError(t, err)
+		}
+		assert.Equal(t, 2, conn.Stats(getCmd))
+	})
+
+	t.Run("bump is seen immediately by this process", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		getCmd := conn.Command(GetCommand, GenerationPrefix+"tenant-1").Expect([]byte("3"))
+		conn.Command(IncrementCommand, GenerationPrefix+"tenant-1").Expect(int64(4))
+
+		generation, err := Generation(context.Background(), client, "tenant-1")
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), generation)
+
+		generation, err = BumpGeneration(context.Background(), client, "tenant-1")
+		require.NoError(t, err)
+		assert.Equal(t, int64(4), generation)
+
+		generation, err = Generation(context.Background(), client, "tenant-1")
+		require.NoError(t, err)
+		assert.Equal(t, int64(4), generation)
+		assert.Equal(t, 1, conn.Stats(getCmd))
+	})
+
+	t.Run("namespaced clients keep separate counters", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+		ns := client.WithNamespace(testNamespace)
+
+		conn.Command(GetCommand, GenerationPrefix+"tenant-1").Expect([]byte("1"))
+		conn.Command(GetCommand, testNamespace+GenerationPrefix+"tenant-1").Expect([]byte("7"))
+
+		generation, err := Generation(context.Background(), client, "tenant-1")
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), generation)
+
+		generation, err = Generation(context.Background(), ns, "tenant-1")
+		require.NoError(t, err)
+		assert.Equal(t, int64(7), generation)
+	})
+
+	t.Run("read error", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.Command(GetCommand, GenerationPrefix+"tenant-1").ExpectError(errTestLoader)
+
+		_, err := Generation(context.Background(), client, "tenant-1")
+		require.ErrorIs(t, err, errTestLoader)
+		_, err = GetGenerational[string](context.Background(), client, nil, "tenant-1", "orders")
+		require.ErrorIs(t, err, errTestLoader)
+	})
+}
+
+// TestGenerationCache tests the in-process generation cache
+func TestGenerationCache(t *testing.T) {
+	now := time.Now()
+	var g generationCache
+
+	g.set("a", 2, now)
+	generation, ok := g.get("a", now)
+	assert.True(t, ok)
+	assert.Equal(t, int64(2), generation)
+
+	// An older generation read concurrently does not replace a newer one
+	g.set("a", 1, now)
+	generation, _ = g.get("a", now)
+	assert.Equal(t, int64(2), generation)
+
+	_, ok = g.get("a", now.Add(defaultGenerationCacheTTL))
+	assert.False(t, ok)
+	_, ok = g.get("b", now)
+	assert.False(t, ok)
+	// The cache is bounded: expired entries go first, then any entry
+	for i := 0; i < maxGenerationCacheEntries+10; i++ {
+		g.set(strconv.Itoa(i), 1, now)
+	}
+	assert.Len(t, g.entries, maxGenerationCacheEntries)
+	g.set("fresh", 1, now.Add(defaultGenerationCacheTTL))
+	assert.Len(t, g.entries, 1)
+}
+
+// TestGetGenerational tests the methods GetGenerational() and SetGenerational()
+func TestGetGenerational(t *testing.T) {
+	t.Run("reads and writes the current generation", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.Command(GetCommand, GenerationPrefix+"tenant-1").Expect([]byte("2"))
+		setCmd := conn.Command(SetExpirationCommand, `"tenant-1":2:orders`, int64(60), []byte(`[1,2]`)).Expect("OK")
+		conn.Command(GetCommand, `"tenant-1":2:orders`).Expect([]byte(`[1,2]`))
+
+		err := SetGenerational(context.Background(), client, nil, "tenant-1", "orders", []int{1, 2}, time.Minute)
+		require.NoError(t, err)
+		assert.True(t, setCmd.Called)
+
+		var orders []int
+		orders, err = GetGenerational[[]int](context.Background(), client, nil, "tenant-1", "orders")
+		require.NoError(t, err)
+		assert.Equal(t, []int{1, 2}, orders)
+	})
+
+	t.Run("raw reads the generation every time", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		getCmd := conn.Command(GetCommand, GenerationPrefix+"tenant-1").Expect([]byte("2"))
+		conn.Command(SetCommand, `"tenant-1":2:orders`, []byte(`"x"`)).Expect("OK")
+		conn.Command(GetCommand, `"tenant-1":2:orders`).Expect([]byte(`"x"`))
+
+		require.NoError(t, SetGenerationalRaw(conn, nil, "tenant-1", "orders", "x", 0))
+		value, err := GetGenerationalRaw[string](conn, nil, "tenant-1", "orders")
+		require.NoError(t, err)
+		assert.Equal(t, "x", value)
+		assert.Equal(t, 2, conn.Stats(getCmd))
+	})
+
+	t.Run("bump invalidates using real redis", func(t *testing.T) {
+		if testing.Short() {
+			t.Skip("skipping live local redis tests")
+		}
+
+		client, conn, err := loadRealRedis(t)
+		require.NoError(t, err)
+		defer client.CloseAll(conn)
+		require.NoError(t, clearRealRedis(conn, t))
+
+		ctx := context.Background()
+		require.NoError(t, SetGenerational(ctx, client, nil, "tenant-1", "orders", []int{1}, time.Minute))
+
+		var orders []int
+		orders, err = GetGenerational[[]int](ctx, client, nil, "tenant-1", "orders")
+		require.NoError(t, err)
+		assert.Equal(t, []int{1}, orders)
+
+		var generation int64
+		generation, err = BumpGeneration(ctx, client, "tenant-1")
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), generation)
+
+		_, err = GetGenerational[[]int](ctx, client, nil, "tenant-1", "orders")
+		require.Error(t, err, "the old generation is no longer read")
+
+		var found bool
+		found, err = ExistsRaw(conn, GenerationalKey("tenant-1", 0, "orders"))
+		require.NoError(t, err)
+		assert.True(t, found, "old keys are left to expire")
+	})
+}
+
+// ExampleBumpGeneration is an example of the method BumpGeneration()
+func ExampleBumpGeneration() {
+	// Load a mocked redis for testing/examples
+	client, conn := loadMockRedis()
+
+	// Close connections at end of request
+	defer client.CloseAll(conn)
+
+	// Mock the generation counter
+	conn.Command(IncrementCommand, GenerationPrefix+"tenant-1").Expect(int64(8))
+
+	// Invalidate every key of the tenant at once
+	generation, _ := BumpGeneration(context.Background(), client, "tenant-1")
+	fmt.Printf("keys now live under: %s", GenerationalKey("tenant-1", generation, "orders"))
+	// Output:keys now live under: "tenant-1":8:orders
+}
@@ -165,5 +165,5 @@ func HashMapSetExpRaw(conn redis.Conn, hashName string, pairs [][2]interface{},
 	}
 
 	// Link and return the error
-	return linkDependencies(conn, hashName, dependencies...)
+	return linkDependenciesExp(conn, hashName, ttl, dependencies...)
 }
@@ -23,6 +23,7 @@ func FuzzHashSet(f *testing.F) {
 
 		conn.Command(HashKeySetCommand, hashName, hashKey, value).Expect("OK")
 		conn.Command(AddToSetCommand, DependencyPrefix+dependency, hashName).Expect(int64(1))
+		mockReverseDependencies(conn, hashName, dependency).Expect(int64(1))
 		conn.Command(MultiCommand).Expect("QUEUED")
 		conn.Command(ExecuteCommand).Expect([]interface{}{int64(1)})
 
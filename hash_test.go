@@ -53,6 +53,7 @@ func TestHashSet(t *testing.T) {
 					for _, dep := range test.dependencies {
 						commands = append(commands, conn.Command(AddToSetCommand, DependencyPrefix+dep, test.hashName))
 					}
+					commands = append(commands, mockReverseDependencies(conn, test.hashName, test.dependencies...))
 					commands = append(commands, conn.Command(ExecuteCommand))
 
 					err := HashSetRaw(conn, test.hashName, test.key, test.value, test.dependencies...)
@@ -254,6 +255,7 @@ func TestHashMapSet(t *testing.T) {
 					for _, dep := range test.dependencies {
 						commands = append(commands, conn.Command(AddToSetCommand, DependencyPrefix+dep, test.hashName))
 					}
+					commands = append(commands, mockReverseDependencies(conn, test.hashName, test.dependencies...))
 					commands = append(commands, conn.Command(ExecuteCommand))
 
 					err := HashMapSetRaw(conn, test.hashName, test.pairs, test.dependencies...)
@@ -403,6 +405,8 @@ func TestHashMapSetExp(t *testing.T) {
 					for _, dep := range test.dependencies {
 						commands = append(commands, conn.Command(AddToSetCommand, DependencyPrefix+dep, test.hashName))
 					}
+					commands = append(commands, mockReverseDependencies(conn, test.hashName, test.dependencies...))
+					commands = append(commands, mockReverseExpire(conn, test.hashName, test.expiration))
 					commands = append(commands, conn.Command(ExecuteCommand))
 
 					err := HashMapSetExp(context.Background(), client, test.hashName, test.pairs, test.expiration, test.dependencies...)
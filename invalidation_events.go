@@ -0,0 +1,208 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+const (
+	// InvalidationChannel is the default pub/sub channel (or stream key) for invalidation events
+	InvalidationChannel = "go-cache:invalidations"
+
+	// invalidationEventField is the stream entry field holding the JSON encoded event
+	invalidationEventField = "event"
+)
+
+// InvalidationTransport selects how invalidation events are delivered
+type InvalidationTransport int
+
+// Invalidation event transports
+const (
+	InvalidationPublish InvalidationTransport = iota // PUBLISH on a channel (only live subscribers receive it)
+	InvalidationStream                               // XADD to a stream (durable, read with ReadInvalidations)
+)
+
+// InvalidationEventOptions configures the events sent by EnableInvalidationEvents()
+type InvalidationEventOptions struct {
+	Transport InvalidationTransport // Delivery (default: InvalidationPublish)
+	Channel   string                // Channel or stream key (default: InvalidationChannel)
+	MaxLen    int64                 // Streams only: trim to about MaxLen entries (0 = no cap)
+}
+
+// InvalidationEvent describes an invalidation (see EnableInvalidationEvents)
+type InvalidationEvent struct {
+	ID           string    `json:"-"`                   // Stream entry ID (ReadInvalidations only)
+	Namespace    string    `json:"namespace,omitempty"` // Namespace of the client that deleted the keys
+	Dependencies []string  `json:"dependencies"`        // Keys given to KillByDependency / Delete
+	Keys         []string  `json:"keys"`                // Invalidated keys
+	Timestamp    time.Time `json:"timestamp"`           // When the keys were deleted
+}
+
+// EnableInvalidationEvents sends an InvalidationEvent every time KillByDependency, Delete or
+// KillByDependencyCascade runs through the client (or a namespaced client sharing its pool),
+// so other services can drop derived caches (CDN, search index, in-process maps).
+//
+// Events list the dependents of the given keys and the keys themselves. Keys and dependencies
+// are sent without the namespace, which is set in the Namespace field of the event instead
+// (keys as stored in redis are Namespace + key). The channel or stream key is never prefixed,
+// so one subscriber receives the events of every namespace. Writes made through the Raw
+// methods on a custom connection send no events.
+func (c *Client) EnableInvalidationEvents(opts InvalidationEventOptions) {
+	if len(opts.Channel) == 0 {
+		opts.Channel = InvalidationChannel
+	}
+	root := c.root()
+	root.mu.Lock()
+	defer root.mu.Unlock()
+	root.events = &opts
+}
+
+// DisableInvalidationEvents stops sending invalidation events
+func (c *Client) DisableInvalidationEvents() {
+	root := c.root()
+	root.mu.Lock()
+	defer root.mu.Unlock()
+	root.events = nil
+}
+
+// invalidationEvents returns the event options, nil when events are disabled
+func (c *Client) invalidationEvents() *InvalidationEventOptions {
+	root := c.root()
+	root.mu.RLock()
+	defer root.mu.RUnlock()
+	return root.events
+}
+
+// sendInvalidation sends the invalidation event (if enabled)
+//
+// Commands used:
+// https://redis.io/commands/publish
+// https://redis.io/commands/xadd
+func (c *Client) sendInvalidation(conn redis.Conn, dependencies, keys []string) error {
+	opts := c.invalidationEvents()
+	if opts == nil || len(keys) == 0 {
+		return nil
+	}
+	payload, err := json.Marshal(InvalidationEvent{
+		Namespace:    c.namespace,
+		Dependencies: dependencies,
+		Keys:         keys,
+		Timestamp:    time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+
+	// The stream is shared by every namespace, like the channel
+	conn = unprefixedConn(conn)
+
+	fields := map[string]string{invalidationEventField: string(payload)}
+	switch {
+	case opts.Transport == InvalidationStream && opts.MaxLen > 0:
+		_, err = StreamAddCappedRaw(conn, opts.Channel, opts.MaxLen, fields)
+	case opts.Transport == InvalidationStream:
+		_, err = StreamAddRaw(conn, opts.Channel, fields)
+	default:
+		_, err = PublishRaw(conn, opts.Channel, payload)
+	}
+	return err
+}
+
+// InvalidationSubscription delivers the events published on an invalidation channel
+// Call Close() to unsubscribe and release resources.
+type InvalidationSubscription struct {
+	Events <-chan InvalidationEvent // Decoded events; closed when the subscription ends
+	Errors <-chan error             // Reconnection errors (see Subscription)
+
+	sub       *Subscription
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// SubscribeInvalidations subscribes to the invalidation events published on the channel
+// (default: InvalidationChannel). Undecodable messages are skipped.
+//
+// Uses methods: Subscribe()
+func SubscribeInvalidations(ctx context.Context, client *Client, channel string,
+	opts ...SubscriptionOption,
+) (*InvalidationSubscription, error) {
+	if len(channel) == 0 {
+		channel = InvalidationChannel
+	}
+	sub, err := Subscribe(ctx, client, []string{channel}, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(chan InvalidationEvent, cap(sub.Messages))
+	s := &InvalidationSubscription{Events: events, Errors: sub.Errors, sub: sub, done: make(chan struct{})}
+	go s.decode(sub.Messages, events)
+	return s, nil
+}
+
+// decode forwards the decodable messages as events until the messages end or Close() is called
+func (s *InvalidationSubscription) decode(messages <-chan Message, events chan<- InvalidationEvent) {
+	defer close(events)
+	for msg := range messages {
+		var event InvalidationEvent
+		if json.Unmarshal(msg.Data, &event) != nil {
+			continue
+		}
+		select {
+		case events <- event:
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// Close unsubscribes and stops delivering events
+func (s *InvalidationSubscription) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.done)
+	})
+	return s.sub.Close()
+}
+
+// ReadInvalidations reads the invalidation events appended to a stream after startID
+// Use "0" for startID to read from the beginning, then the ID of the last event read
+// Creates a new connection and closes connection at end of function call
+//
+// Custom connections use method: ReadInvalidationsRaw()
+func ReadInvalidations(ctx context.Context, client *Client, stream, startID string,
+	count int64,
+) ([]InvalidationEvent, error) {
+	conn, err := client.GetConnectionWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer client.CloseConnection(conn)
+	return ReadInvalidationsRaw(conn, stream, startID, count)
+}
+
+// ReadInvalidationsRaw reads the invalidation events appended to a stream after startID
+// Entries that are not invalidation events are skipped. The stream key is not prefixed on a
+// namespaced connection, as events of every namespace share the stream.
+// Uses existing connection (does not close connection)
+//
+// Uses methods: StreamReadRaw()
+func ReadInvalidationsRaw(conn redis.Conn, stream, startID string, count int64) ([]InvalidationEvent, error) {
+	entries, err := StreamReadRaw(unprefixedConn(conn), stream, startID, count)
+	if err != nil {
+		return nil, err
+	}
+	events := make([]InvalidationEvent, 0, len(entries))
+	for _, entry := range entries {
+		var event InvalidationEvent
+		if json.Unmarshal([]byte(entry.Fields[invalidationEventField]), &event) != nil {
+			continue
+		}
+		event.ID = entry.ID
+		events = append(events, event)
+	}
+	return events, nil
+}
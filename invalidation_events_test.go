@@ -0,0 +1,352 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// decodeInvalidation decodes the JSON payload of an invalidation event
+func decodeInvalidation(t *testing.T, payload interface{}) InvalidationEvent {
+	t.Helper()
+	data, ok := payload.([]byte)
+	if !ok {
+		data = []byte(fmt.Sprint(payload))
+	}
+	var event InvalidationEvent
+	require.NoError(t, json.Unmarshal(data, &event))
+	return event
+}
+
+// TestInvalidationEvents tests the method EnableInvalidationEvents()
+func TestInvalidationEvents(t *testing.T) {
+	t.Run("kill by dependency publishes the invalidated keys", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+		client.EnableInvalidationEvents(InvalidationEventOptions{})
+
+		conn.Command(MembersCommand, DependencyPrefix+"user").Expect([]interface{}{[]byte("orders")})
+		conn.Command(EvalCommand, killByDependencySha, 1, DependencyPrefix+"user", "user").Expect(int64(2))
+		conn.Command(DeleteCommand, "user").Expect(int64(1))
+		var published []interface{}
+		conn.GenericCommand(PublishCommand).Handle(func(args []interface{}) (interface{}, error) {
+			published = args
+			return int64(1), nil
+		})
+
+		before := time.Now()
+		total, err := Delete(context.Background(), client, "user")
+		require.NoError(t, err)
+		assert.Equal(t, 3, total)
+
+		require.Len(t, published, 2)
+		assert.Equal(t, InvalidationChannel, published[0])
+		event := decodeInvalidation(t, published[1])
+		assert.Equal(t, []string{"user"}, event.Dependencies)
+		assert.Equal(t, []string{"orders", "user"}, event.Keys)
+		assert.False(t, event.Timestamp.Before(before.Truncate(time.Second)))
+	})
+
+	t.Run("stream transport with a namespace", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+		ns := client.WithNamespace(testNamespace)
+		ns.EnableInvalidationEvents(InvalidationEventOptions{Transport: InvalidationStream, Channel: "events", MaxLen: 100})
+
+		conn.Command(MembersCommand, testNamespace+DependencyPrefix+"user").Expect([]interface{}{})
+		conn.Command(EvalCommand, killByDependencySha, 1, testNamespace+DependencyPrefix+"user", "user").Expect(int64(0))
+		conn.Command(DeleteCommand, testNamespace+"user").Expect(int64(1))
+		var added []interface{}
+		conn.GenericCommand(StreamAddCommand).Handle(func(args []interface{}) (interface{}, error) {
+			added = args
+			return []byte("1-0"), nil
+		})
+
+		_, err := KillByDependency(context.Background(), ns, "user")
+		require.NoError(t, err)
+		require.Len(t, added, 7)
+		assert.Equal(t, []interface{}{"events", "MAXLEN", "~", int64(100), "*", "event"}, added[:6],
+			"the stream is shared by every namespace")
+		event := decodeInvalidation(t, added[6])
+		assert.Equal(t, testNamespace, event.Namespace)
+		assert.Equal(t, []string{"user"}, event.Dependencies)
+		assert.Equal(t, []string{"user"}, event.Keys)
+	})
+
+	t.Run("publish transport with a namespace", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+		client.EnableInvalidationEvents(InvalidationEventOptions{})
+		ns := client.WithNamespace(testNamespace)
+
+		conn.Command(MembersCommand, testNamespace+DependencyPrefix+"user").
+			Expect([]interface{}{[]byte(testNamespace + "orders")})
+		conn.Command(EvalCommand, killByDependencySha, 1, testNamespace+DependencyPrefix+"user", "user").Expect(int64(2))
+		conn.Command(DeleteCommand, testNamespace+"user").Expect(int64(1))
+		var published []interface{}
+		conn.GenericCommand(PublishCommand).Handle(func(args []interface{}) (interface{}, error) {
+			published = args
+			return int64(1), nil
+		})
+
+		_, err := KillByDependency(context.Background(), ns, "user")
+		require.NoError(t, err)
+		require.Len(t, published, 2)
+		assert.Equal(t, InvalidationChannel, published[0])
+		event := decodeInvalidation(t, published[1])
+		assert.Equal(t, testNamespace, event.Namespace)
+		assert.Equal(t, []string{"user"}, event.Dependencies)
+		assert.Equal(t, []string{"orders", "user"}, event.Keys)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+		client.EnableInvalidationEvents(InvalidationEventOptions{})
+		client.DisableInvalidationEvents()
+
+		conn.Command(EvalCommand, killByDependencySha, 1, DependencyPrefix+"user", "user").Expect(int64(0))
+		conn.Command(DeleteCommand, "user").Expect(int64(1))
+		membersCmd := conn.GenericCommand(MembersCommand)
+		publishCmd := conn.GenericCommand(PublishCommand)
+
+		_, err := KillByDependency(context.Background(), client, "user")
+		require.NoError(t, err)
+		assert.False(t, membersCmd.Called)
+		assert.False(t, publishCmd.Called)
+	})
+
+	t.Run("publish error", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+		client.EnableInvalidationEvents(InvalidationEventOptions{})
+
+		conn.Command(MembersCommand, DependencyPrefix+"user").Expect([]interface{}{})
+		conn.Command(EvalCommand, killByDependencySha, 1, DependencyPrefix+"user", "user").Expect(int64(0))
+		conn.Command(DeleteCommand, "user").Expect(int64(1))
+		conn.GenericCommand(PublishCommand).ExpectError(errTestLoader)
+
+		total, err := KillByDependency(context.Background(), client, "user")
+		require.ErrorIs(t, err, errTestLoader)
+		assert.Equal(t, 1, total)
+	})
+
+	t.Run("publish error still drops the local entries", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+		client.EnableInvalidationEvents(InvalidationEventOptions{})
+		lc := loadMockLocalCache(client, LocalCacheOptions{})
+		for _, key := range []string{"user", "orders"} {
+			lc.store.set(key, testStringValue, lc.store.currentEpoch())
+		}
+
+		conn.Command(MembersCommand, DependencyPrefix+"user").Expect([]interface{}{[]byte("orders")})
+		conn.Command(EvalCommand, killByDependencySha, 1, DependencyPrefix+"user", "user").Expect(int64(2))
+		conn.Command(DeleteCommand, "user").Expect(int64(1))
+		conn.GenericCommand(PublishCommand).ExpectError(errTestLoader)
+
+		_, err := KillByDependency(context.Background(), client, "user")
+		require.ErrorIs(t, err, errTestLoader)
+		for _, key := range []string{"user", "orders"} {
+			_, ok := lc.store.get(key)
+			assert.False(t, ok, key)
+		}
+	})
+
+	t.Run("cascade publishes the deleted keys", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+		client.EnableInvalidationEvents(InvalidationEventOptions{})
+
+		conn.Command(MembersCommand, DependencyPrefix+"user").Expect([]interface{}{})
+		conn.GenericCommand(EvalCommand).Expect([]interface{}{int64(1)})
+		var published []interface{}
+		conn.GenericCommand(PublishCommand).Handle(func(args []interface{}) (interface{}, error) {
+			published = args
+			return int64(1), nil
+		})
+
+		result, err := KillByDependencyCascade(context.Background(), client, []string{"user"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"user"}, result.Deleted)
+		require.Len(t, published, 2)
+		assert.Equal(t, []string{"user"}, decodeInvalidation(t, published[1]).Keys)
+	})
+}
+
+// TestSubscribeInvalidations tests the method SubscribeInvalidations()
+func TestSubscribeInvalidations(t *testing.T) {
+	t.Run("decodes events and skips bad payloads", func(t *testing.T) {
+		messages := make(chan Message, 2)
+		messages <- Message{Channel: InvalidationChannel, Data: []byte("not json")}
+		messages <- Message{
+			Channel: InvalidationChannel,
+			Data:    []byte(`{"dependencies":["user"],"keys":["orders"],"timestamp":"2026-01-02T03:04:05Z"}`),
+		}
+		close(messages)
+
+		events := make(chan InvalidationEvent, 2)
+		s := &InvalidationSubscription{done: make(chan struct{})}
+		s.decode(messages, events)
+
+		event, ok := <-events
+		require.True(t, ok)
+		assert.Equal(t, []string{"user"}, event.Dependencies)
+		assert.Equal(t, []string{"orders"}, event.Keys)
+		assert.Equal(t, time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC), event.Timestamp)
+		_, ok = <-events
+		assert.False(t, ok)
+	})
+
+	t.Run("stops on close", func(t *testing.T) {
+		messages := make(chan Message, 1)
+		messages <- Message{Data: []byte(`{"keys":["orders"]}`)}
+
+		events := make(chan InvalidationEvent)
+		s := &InvalidationSubscription{done: make(chan struct{})}
+		close(s.done)
+		s.decode(messages, events)
+
+		_, ok := <-events
+		assert.False(t, ok)
+	})
+
+	t.Run("publish and subscribe using real redis", func(t *testing.T) {
+		if testing.Short() {
+			t.Skip("skipping live local redis tests")
+		}
+
+		client, conn, err := loadRealRedis(t)
+		require.NoError(t, err)
+		defer client.CloseAll(conn)
+		require.NoError(t, clearRealRedis(conn, t))
+		client.EnableInvalidationEvents(InvalidationEventOptions{})
+
+		var sub *InvalidationSubscription
+		sub, err = SubscribeInvalidations(context.Background(), client, InvalidationChannel)
+		require.NoError(t, err)
+		defer func() { _ = sub.Close() }()
+
+		require.NoError(t, SetRaw(conn, "orders", testStringValue, "user"))
+		_, err = KillByDependency(context.Background(), client, "user")
+		require.NoError(t, err)
+
+		select {
+		case event := <-sub.Events:
+			assert.Equal(t, []string{"user"}, event.Dependencies)
+			assert.Equal(t, []string{"orders", "user"}, event.Keys)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for the event")
+		}
+	})
+}
+
+// TestReadInvalidations tests the method ReadInvalidations()
+func TestReadInvalidations(t *testing.T) {
+	t.Run("decodes stream entries", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.Command(StreamReadCommand, "COUNT", int64(10), "STREAMS", InvalidationChannel, "0").
+			Expect([]interface{}{
+				[]interface{}{
+					[]byte(InvalidationChannel),
+					[]interface{}{
+						[]interface{}{[]byte("1-0"), []interface{}{[]byte("event"), []byte(`{"keys":["orders"]}`)}},
+						[]interface{}{[]byte("2-0"), []interface{}{[]byte("other"), []byte("value")}},
+					},
+				},
+			})
+
+		events, err := ReadInvalidations(context.Background(), client, InvalidationChannel, "0", 10)
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		assert.Equal(t, "1-0", events[0].ID)
+		assert.Equal(t, []string{"orders"}, events[0].Keys)
+	})
+
+	t.Run("namespaced client reads the shared stream", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		readCmd := conn.Command(StreamReadCommand, "COUNT", int64(10), "STREAMS", InvalidationChannel, "0").
+			Expect([]interface{}{})
+
+		_, err := ReadInvalidations(context.Background(), client.WithNamespace(testNamespace),
+			InvalidationChannel, "0", 10)
+		require.NoError(t, err)
+		assert.True(t, readCmd.Called)
+	})
+
+	t.Run("read error", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.GenericCommand(StreamReadCommand).ExpectError(errTestLoader)
+
+		_, err := ReadInvalidationsRaw(conn, InvalidationChannel, "0", 10)
+		require.ErrorIs(t, err, errTestLoader)
+	})
+
+	t.Run("durable delivery using real redis", func(t *testing.T) {
+		if testing.Short() {
+			t.Skip("skipping live local redis tests")
+		}
+
+		client, conn, err := loadRealRedis(t)
+		require.NoError(t, err)
+		defer client.CloseAll(conn)
+		require.NoError(t, clearRealRedis(conn, t))
+		client.EnableInvalidationEvents(InvalidationEventOptions{Transport: InvalidationStream})
+
+		require.NoError(t, SetRaw(conn, "orders", testStringValue, "user"))
+		_, err = KillByDependency(context.Background(), client, "user")
+		require.NoError(t, err)
+
+		var events []InvalidationEvent
+		events, err = ReadInvalidationsRaw(conn, InvalidationChannel, "0", 10)
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		assert.NotEmpty(t, events[0].ID)
+		assert.Equal(t, []string{"orders", "user"}, events[0].Keys)
+
+		var length int64
+		length, err = redis.Int64(conn.Do(StreamLenCommand, InvalidationChannel))
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), length)
+	})
+}
+
+// ExampleClient_EnableInvalidationEvents is an example of the method EnableInvalidationEvents()
+func ExampleClient_EnableInvalidationEvents() {
+	// Load a mocked redis for testing/examples
+	client, conn := loadMockRedis()
+
+	// Close connections at end of request
+	defer client.CloseAll(conn)
+
+	// Publish an event every time keys are invalidated
+	client.EnableInvalidationEvents(InvalidationEventOptions{Channel: "invalidations"})
+
+	// Mock the dependency kill and print the event
+	conn.Command(MembersCommand, DependencyPrefix+"user").Expect([]interface{}{[]byte("orders")})
+	conn.Command(EvalCommand, killByDependencySha, 1, DependencyPrefix+"user", "user").Expect(int64(1))
+	conn.Command(DeleteCommand, "user").Expect(int64(0))
+	conn.GenericCommand(PublishCommand).Handle(func(args []interface{}) (interface{}, error) {
+		var event InvalidationEvent
+		data, _ := args[1].([]byte)
+		_ = json.Unmarshal(data, &event)
+		fmt.Printf("%s: %v depends on %v", args[0], event.Keys[:1], event.Dependencies)
+		return int64(1), nil
+	})
+
+	// Delete the user and everything depending on it
+	_, _ = Delete(context.Background(), client, "user")
+	// Output:invalidations: [orders] depends on [user]
+}
@@ -0,0 +1,541 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// Define static errors to avoid dynamic error creation
+var (
+	ErrLocalCacheEnabled    = errors.New("local cache is already enabled")
+	errUnexpectedLocalReply = errors.New("unexpected local cache read reply")
+)
+
+const (
+	// LocalCacheChannel is the default pub/sub channel used to broadcast local cache invalidations
+	LocalCacheChannel = "go-cache:local-invalidate"
+
+	// defaultLocalCacheEntries is the default maximum number of local entries
+	defaultLocalCacheEntries = 10000
+)
+
+// LocalCachePolicy selects which entry is evicted when the local cache is full
+type LocalCachePolicy int
+
+// Local cache eviction policies
+const (
+	LocalCacheLRU LocalCachePolicy = iota // Evict the least recently used entry
+	LocalCacheLFU                         // Evict the least frequently used entry (ties: least recently used)
+)
+
+// LocalCacheOptions configures the in-process (L1) cache in front of redis
+type LocalCacheOptions struct {
+	MaxEntries int              // Maximum number of entries held in memory (default: 10,000)
+	TTL        time.Duration    // Maximum age of a local entry (0 = until the redis key expires, evicted or invalidated)
+	Policy     LocalCachePolicy // Eviction policy (default: LRU)
+	Channel    string           // Pub/sub channel for invalidations (default: LocalCacheChannel)
+}
+
+// localInvalidation is the payload broadcast on the invalidation channel
+type localInvalidation struct {
+	Keys []string `json:"keys,omitempty"`
+	All  bool     `json:"all,omitempty"`
+}
+
+// EnableLocalCache puts a bounded in-memory (L1) cache in front of Get(), GetBytes() and
+// the methods built on them. Entries are invalidated in every process that enabled the local
+// cache on the same channel whenever Set*, Delete, Expire, KillByDependency or DestroyCache
+// runs through a client. Local entries never outlive the redis key: its remaining ttl is read
+// with the value.
+//
+// Writes made through the Raw methods on a custom connection bypass the invalidation
+// broadcast; use the TTL option to bound staleness if those are used.
+//
+// The invalidation subscription lives until DisableLocalCache(), Close() or ctx is canceled.
+// While it is disconnected reads go straight to redis, and after a reconnect the whole
+// local cache is flushed, since invalidations may have been missed.
+//
+// Uses methods: Subscribe()
+func (c *Client) EnableLocalCache(ctx context.Context, opts LocalCacheOptions) error {
+	if len(opts.Channel) == 0 {
+		opts.Channel = LocalCacheChannel
+	}
+	return c.root().enableLocal(ctx, newLocalCache(opts), opts.Channel, subscriptionHooks{})
+}
+
+// enableLocal subscribes the local cache to its invalidation channel and attaches it to the client
+func (c *Client) enableLocal(ctx context.Context, lc *localCache, channel string, hooks subscriptionHooks) error {
+	c.mu.RLock()
+	enabled := c.local != nil
+	c.mu.RUnlock()
+	if enabled {
+		return ErrLocalCacheEnabled
+	}
+
+	hooks.disconnect = lc.offline
+	hooks.reconnect = lc.resync
+	sub, err := Subscribe(ctx, c, []string{channel}, withHooks(hooks))
+	if err != nil {
+		return err
+	}
+	lc.sub = sub
+	go lc.listen()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.local != nil {
+		_ = sub.Close()
+		return ErrLocalCacheEnabled
+	}
+	c.local = lc
+	return nil
+}
+
+// DisableLocalCache stops the invalidation subscription and drops all local entries
+func (c *Client) DisableLocalCache() {
+	c.root().disableLocal(true)
+}
+
+// disableLocal stops the local cache of the root client
+// With untrack, client tracking is turned off on the idle pooled connections (not needed
+// when the pool is about to be closed, the connections take their tracking with them)
+func (c *Client) disableLocal(untrack bool) {
+	c.mu.Lock()
+	lc := c.local
+	c.local = nil
+	c.mu.Unlock()
+	if lc == nil {
+		return
+	}
+	lc.close()
+	if untrack && lc.tracker != nil {
+		untrackIdleConnections(c)
+	}
+}
+
+// localCache returns the local cache, or nil when it is not enabled
+// Namespaced clients share the local cache of the client they were derived from
+func (c *Client) localCache() *localCache {
+	root := c.root()
+	root.mu.RLock()
+	defer root.mu.RUnlock()
+	return root.local
+}
+
+// localGet returns the locally cached value for the key
+// Local entries are stored under the key as stored in redis (with the namespace)
+func (c *Client) localGet(key string) (string, bool) {
+	if lc := c.localCache(); lc != nil && lc.online.Load() {
+		return lc.store.get(c.namespace + key)
+	}
+	return "", false
+}
+
+// localLoad gets the key on conn and caches the value locally (when enabled)
+// The value and the key's remaining ttl are read in one round trip, so the local entry expires
+// with the redis key. Values are not cached if an invalidation arrived while reading.
+func (c *Client) localLoad(conn redis.Conn, key string) (string, error) {
+	lc := c.localCache()
+	if lc == nil || !lc.online.Load() {
+		return GetRaw(conn, key)
+	}
+	if lc.tracker != nil {
+		if err := lc.tracker.track(conn); err != nil {
+			return "", err
+		}
+	}
+	epoch := lc.store.currentEpoch()
+	if err := conn.Send(GetCommand, key); err != nil {
+		return "", err
+	}
+	if err := conn.Send(PTTLCommand, key); err != nil {
+		return "", err
+	}
+
+	// Do("") returns the replies of every queued command, the tracking ones included
+	replies, err := redis.Values(conn.Do(""))
+	if err != nil {
+		return "", err
+	} else if len(replies) < 2 {
+		return "", fmt.Errorf("%w: %d replies", errUnexpectedLocalReply, len(replies))
+	}
+	var value string
+	if value, err = redis.String(replies[len(replies)-2], nil); err != nil {
+		return "", err
+	}
+	if ttl, ttlErr := redis.Int64(replies[len(replies)-1], nil); ttlErr == nil {
+		lc.store.setExp(c.namespace+key, value, keyLifetime(ttl), epoch)
+	}
+	return value, nil
+}
+
+// localLoadMany fetches the keys (chunked MGET) and caches the found values locally (when enabled)
+func (c *Client) localLoadMany(conn redis.Conn, keys []string) (map[string]string, error) {
+	lc := c.localCache()
+	if lc == nil || !lc.online.Load() {
+		return GetManyRaw(conn, keys...)
+	}
+	var before func() error
+	if lc.tracker != nil {
+		before = func() error { return lc.tracker.track(conn) }
+	}
+	epoch := lc.store.currentEpoch()
+	values, err := getManyRaw(conn, keys, before, redis.String)
+	if err != nil || len(values) == 0 {
+		return values, err
+	}
+
+	// The entries expire with their redis keys; without their ttls nothing is cached
+	found := make([]string, 0, len(values))
+	for key := range values {
+		found = append(found, key)
+	}
+	var ttls []int64
+	if ttls, err = keyTTLs(conn, found); err != nil {
+		return values, nil //nolint:nilerr // the values were read, they are only not cached
+	}
+	for i, key := range found {
+		lc.store.setExp(c.namespace+key, values[key], keyLifetime(ttls[i]), epoch)
+	}
+	return values, nil
+}
+
+// keyTTLs returns the remaining ttl (ms) of each key (pipelined PTTL)
+func keyTTLs(conn redis.Conn, keys []string) ([]int64, error) {
+	for _, key := range keys {
+		if err := conn.Send(PTTLCommand, key); err != nil {
+			return nil, err
+		}
+	}
+	if err := conn.Flush(); err != nil {
+		return nil, err
+	}
+	ttls := make([]int64, len(keys))
+	for i := range keys {
+		var err error
+		if ttls[i], err = redis.Int64(conn.Receive()); err != nil {
+			return nil, err
+		}
+	}
+	return ttls, nil
+}
+
+// keyLifetime converts a PTTL reply into the lifetime of a local entry
+// Keys without an expiry get 0 (no limit); keys already gone get a lifetime that has run out
+func keyLifetime(ttl int64) time.Duration {
+	switch {
+	case ttl == ttlPersistent:
+		return 0
+	case ttl <= 0:
+		return -1
+	}
+	return time.Duration(ttl) * time.Millisecond
+}
+
+// invalidateLocal drops the keys from the local cache and broadcasts the invalidation
+func (c *Client) invalidateLocal(ctx context.Context, keys ...string) error {
+	lc := c.localCache()
+	if lc == nil || len(keys) == 0 {
+		return nil
+	}
+	keys = c.physicalKeys(keys...)
+	lc.store.remove(keys...)
+	return lc.publish(ctx, c, localInvalidation{Keys: keys})
+}
+
+// flushLocal drops every entry from the local cache and broadcasts the flush
+func (c *Client) flushLocal(ctx context.Context) error {
+	lc := c.localCache()
+	if lc == nil {
+		return nil
+	}
+	lc.store.clear()
+	return lc.publish(ctx, c, localInvalidation{All: true})
+}
+
+// localCache ties a localStore to its invalidation subscription
+type localCache struct {
+	channel string      // broadcast channel (empty when redis sends the invalidations)
+	online  atomic.Bool // false while the invalidation subscription is disconnected
+	store   *localStore
+	sub     *Subscription
+	tracker *clientTracker // set when using server-assisted client tracking
+}
+
+// newLocalCache creates a local cache (without subscription)
+func newLocalCache(opts LocalCacheOptions) *localCache {
+	lc := &localCache{
+		channel: opts.Channel,
+		store:   newLocalStore(opts.MaxEntries, opts.TTL, opts.Policy),
+	}
+	lc.online.Store(true)
+	return lc
+}
+
+// offline stops serving local entries until the subscription is re-established
+func (lc *localCache) offline() {
+	lc.online.Store(false)
+	lc.store.clear()
+}
+
+// resync drops entries that may have missed invalidations and resumes serving locally
+func (lc *localCache) resync() {
+	lc.store.clear()
+	lc.online.Store(true)
+}
+
+// listen applies invalidations until the subscription is closed
+func (lc *localCache) listen() {
+	for msg := range lc.sub.Messages {
+		lc.apply(msg.Data)
+	}
+}
+
+// apply decodes an invalidation payload and evicts the matching entries
+// Undecodable payloads flush everything, since the safe choice is to drop local state
+func (lc *localCache) apply(data []byte) {
+	var inv localInvalidation
+	if err := json.Unmarshal(data, &inv); err != nil || inv.All {
+		lc.store.clear()
+		return
+	}
+	lc.store.remove(inv.Keys...)
+}
+
+// publish broadcasts an invalidation to every process sharing the channel
+// Nothing is sent in client tracking mode, since redis invalidates every tracking client itself
+func (lc *localCache) publish(ctx context.Context, client *Client, inv localInvalidation) error {
+	if len(lc.channel) == 0 {
+		return nil
+	}
+	payload, err := json.Marshal(inv)
+	if err != nil {
+		return err
+	}
+	_, err = Publish(ctx, client, lc.channel, payload)
+	return err
+}
+
+// close stops the subscription and drops all entries
+func (lc *localCache) close() {
+	if lc.sub != nil {
+		_ = lc.sub.Close()
+	}
+	lc.store.clear()
+}
+
+// localEntry is a single value held by the localStore
+type localEntry struct {
+	key     string
+	value   string
+	expires time.Time // zero when there is no ttl
+	freq    int       // access count (LFU only)
+	elem    *list.Element
+}
+
+// localStore is a bounded, concurrency-safe LRU/LFU map with optional ttl
+//
+// LRU keeps a single recency list. LFU keeps one recency list per access frequency
+// and tracks the lowest non-empty frequency, so eviction stays O(1).
+type localStore struct {
+	mu         sync.Mutex
+	maxEntries int
+	ttl        time.Duration
+	policy     LocalCachePolicy
+	items      map[string]*localEntry
+	recency    *list.List         // LRU: front is most recently used
+	freqs      map[int]*list.List // LFU: access frequency -> recency list
+	minFreq    int                // LFU: lowest frequency with entries
+	epoch      uint64             // bumped on every invalidation
+}
+
+// newLocalStore creates an empty localStore
+func newLocalStore(maxEntries int, ttl time.Duration, policy LocalCachePolicy) *localStore {
+	if maxEntries <= 0 {
+		maxEntries = defaultLocalCacheEntries
+	}
+	return &localStore{
+		maxEntries: maxEntries,
+		ttl:        ttl,
+		policy:     policy,
+		items:      make(map[string]*localEntry),
+		recency:    list.New(),
+		freqs:      make(map[int]*list.List),
+	}
+}
+
+// get returns the value for the key if present and not expired
+func (s *localStore) get(key string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.items[key]
+	if !ok {
+		return "", false
+	}
+	if !entry.expires.IsZero() && !time.Now().Before(entry.expires) {
+		s.unlink(entry)
+		return "", false
+	}
+	s.touch(entry)
+	return entry.value, true
+}
+
+// currentEpoch returns the invalidation epoch, to be passed to set()
+func (s *localStore) currentEpoch() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.epoch
+}
+
+// set stores the value unless an invalidation happened since epoch was read
+func (s *localStore) set(key, value string, epoch uint64) {
+	s.setExp(key, value, 0, epoch)
+}
+
+// setExp stores the value for at most ttl (0 = no limit besides the store ttl, < 0 = not at all)
+// unless an invalidation happened since epoch was read
+func (s *localStore) setExp(key, value string, ttl time.Duration, epoch uint64) {
+	if ttl < 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if epoch != s.epoch {
+		return
+	}
+	if entry, ok := s.items[key]; ok {
+		s.unlink(entry)
+	}
+	for len(s.items) >= s.maxEntries {
+		if !s.evict() {
+			break
+		}
+	}
+
+	entry := &localEntry{key: key, value: value}
+	if s.ttl > 0 && (ttl <= 0 || s.ttl < ttl) {
+		ttl = s.ttl
+	}
+	if ttl > 0 {
+		entry.expires = time.Now().Add(ttl)
+	}
+	if s.policy == LocalCacheLFU {
+		entry.freq = 1
+		entry.elem = s.freqList(1).PushFront(entry)
+		s.minFreq = 1
+	} else {
+		entry.elem = s.recency.PushFront(entry)
+	}
+	s.items[key] = entry
+}
+
+// remove drops the keys and starts a new invalidation epoch
+func (s *localStore) remove(keys ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.epoch++
+	for _, key := range keys {
+		if entry, ok := s.items[key]; ok {
+			s.unlink(entry)
+		}
+	}
+}
+
+// clear drops every entry and starts a new invalidation epoch
+func (s *localStore) clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.epoch++
+	s.items = make(map[string]*localEntry)
+	s.recency.Init()
+	s.freqs = make(map[int]*list.List)
+	s.minFreq = 0
+}
+
+// len returns the number of entries (including expired ones not yet evicted)
+func (s *localStore) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.items)
+}
+
+// touch records an access to the entry
+func (s *localStore) touch(entry *localEntry) {
+	if s.policy != LocalCacheLFU {
+		s.recency.MoveToFront(entry.elem)
+		return
+	}
+	current := s.freqs[entry.freq]
+	current.Remove(entry.elem)
+	if current.Len() == 0 {
+		delete(s.freqs, entry.freq)
+		if s.minFreq == entry.freq {
+			s.minFreq++
+		}
+	}
+	entry.freq++
+	entry.elem = s.freqList(entry.freq).PushFront(entry)
+}
+
+// evict removes the entry chosen by the policy, returns false if nothing could be evicted
+func (s *localStore) evict() bool {
+	var victims *list.List
+	if s.policy == LocalCacheLFU {
+		victims = s.freqs[s.minFreq]
+	} else {
+		victims = s.recency
+	}
+	if victims == nil || victims.Len() == 0 {
+		return false
+	}
+	entry, _ := victims.Back().Value.(*localEntry)
+	s.unlink(entry)
+	return true
+}
+
+// unlink removes the entry from the map and its list
+func (s *localStore) unlink(entry *localEntry) {
+	delete(s.items, entry.key)
+	if s.policy != LocalCacheLFU {
+		s.recency.Remove(entry.elem)
+		return
+	}
+	if l, ok := s.freqs[entry.freq]; ok {
+		l.Remove(entry.elem)
+		if l.Len() == 0 {
+			delete(s.freqs, entry.freq)
+			if s.minFreq == entry.freq {
+				s.minFreq = s.lowestFreq()
+			}
+		}
+	}
+}
+
+// lowestFreq finds the lowest populated frequency (only needed after out-of-order removals)
+func (s *localStore) lowestFreq() int {
+	lowest := 0
+	for freq := range s.freqs {
+		if lowest == 0 || freq < lowest {
+			lowest = freq
+		}
+	}
+	return lowest
+}
+
+// freqList returns the recency list for the frequency, creating it if needed
+func (s *localStore) freqList(freq int) *list.List {
+	l, ok := s.freqs[freq]
+	if !ok {
+		l = list.New()
+		s.freqs[freq] = l
+	}
+	return l
+}
@@ -0,0 +1,362 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// loadMockLocalCache attaches a local cache (without subscription) to a mocked client
+func loadMockLocalCache(client *Client, opts LocalCacheOptions) *localCache {
+	if len(opts.Channel) == 0 {
+		opts.Channel = LocalCacheChannel
+	}
+	client.local = newLocalCache(opts)
+	return client.local
+}
+
+// TestLocalStore tests the in-memory store behind the local cache
+func TestLocalStore(t *testing.T) {
+	t.Run("lru evicts least recently used", func(t *testing.T) {
+		s := newLocalStore(2, 0, LocalCacheLRU)
+		s.set("a", "1", s.currentEpoch())
+		s.set("b", "2", s.currentEpoch())
+		_, ok := s.get("a")
+		require.True(t, ok)
+		s.set("c", "3", s.currentEpoch())
+
+		_, ok = s.get("b")
+		assert.False(t, ok)
+		_, ok = s.get("a")
+		assert.True(t, ok)
+		_, ok = s.get("c")
+		assert.True(t, ok)
+		assert.Equal(t, 2, s.len())
+	})
+
+	t.Run("lfu evicts least frequently used", func(t *testing.T) {
+		s := newLocalStore(2, 0, LocalCacheLFU)
+		s.set("a", "1", s.currentEpoch())
+		s.set("b", "2", s.currentEpoch())
+		for i := 0; i < 3; i++ {
+			_, _ = s.get("b")
+		}
+		_, _ = s.get("a")
+		s.set("c", "3", s.currentEpoch())
+
+		_, ok := s.get("a")
+		assert.False(t, ok)
+		_, ok = s.get("b")
+		assert.True(t, ok)
+		_, ok = s.get("c")
+		assert.True(t, ok)
+	})
+
+	t.Run("lfu tracks minimum after removal", func(t *testing.T) {
+		s := newLocalStore(2, 0, LocalCacheLFU)
+		s.set("a", "1", s.currentEpoch())
+		s.set("b", "2", s.currentEpoch())
+		_, _ = s.get("b")
+		s.remove("a")
+		s.set("c", "3", s.currentEpoch())
+		s.set("d", "4", s.currentEpoch())
+
+		_, ok := s.get("c")
+		assert.False(t, ok)
+		_, ok = s.get("b")
+		assert.True(t, ok)
+	})
+
+	t.Run("overwrite replaces value", func(t *testing.T) {
+		s := newLocalStore(1, 0, LocalCacheLRU)
+		s.set("a", "1", s.currentEpoch())
+		s.set("a", "2", s.currentEpoch())
+		val, ok := s.get("a")
+		assert.True(t, ok)
+		assert.Equal(t, "2", val)
+		assert.Equal(t, 1, s.len())
+	})
+
+	t.Run("entries expire after ttl", func(t *testing.T) {
+		s := newLocalStore(0, 10*time.Millisecond, LocalCacheLRU)
+		s.set("a", "1", s.currentEpoch())
+		_, ok := s.get("a")
+		require.True(t, ok)
+
+		time.Sleep(20 * time.Millisecond)
+		_, ok = s.get("a")
+		assert.False(t, ok)
+		assert.Equal(t, 0, s.len())
+	})
+
+	t.Run("entries expire at the shorter of the key and store ttl", func(t *testing.T) {
+		s := newLocalStore(0, time.Hour, LocalCacheLRU)
+		s.setExp("a", "1", 10*time.Millisecond, s.currentEpoch())
+		s.setExp("b", "2", 0, s.currentEpoch())
+		s.setExp("gone", "3", -1, s.currentEpoch())
+
+		time.Sleep(20 * time.Millisecond)
+		_, ok := s.get("a")
+		assert.False(t, ok)
+		_, ok = s.get("b")
+		assert.True(t, ok)
+		_, ok = s.get("gone")
+		assert.False(t, ok)
+	})
+
+	t.Run("set is skipped after an invalidation", func(t *testing.T) {
+		s := newLocalStore(0, 0, LocalCacheLRU)
+		epoch := s.currentEpoch()
+		s.remove("other")
+		s.set("a", "1", epoch)
+		_, ok := s.get("a")
+		assert.False(t, ok)
+	})
+
+	t.Run("clear drops everything", func(t *testing.T) {
+		s := newLocalStore(0, 0, LocalCacheLFU)
+		s.set("a", "1", s.currentEpoch())
+		s.set("b", "2", s.currentEpoch())
+		s.clear()
+		assert.Equal(t, 0, s.len())
+		s.set("c", "3", s.currentEpoch())
+		_, ok := s.get("c")
+		assert.True(t, ok)
+	})
+}
+
+// TestLocalCacheApply tests decoding invalidation payloads
+func TestLocalCacheApply(t *testing.T) {
+	tests := []struct {
+		name      string
+		payload   string
+		remaining []string
+	}{
+		{"keys", `{"keys":["a"]}`, []string{"b"}},
+		{"all", `{"all":true}`, nil},
+		{"bad payload flushes", `not-json`, nil},
+		{"empty payload", `{}`, []string{"a", "b"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			lc := newLocalCache(LocalCacheOptions{})
+			lc.store.set("a", "1", lc.store.currentEpoch())
+			lc.store.set("b", "2", lc.store.currentEpoch())
+
+			lc.apply([]byte(test.payload))
+			assert.Equal(t, len(test.remaining), lc.store.len())
+			for _, key := range test.remaining {
+				_, ok := lc.store.get(key)
+				assert.True(t, ok)
+			}
+		})
+	}
+}
+
+// TestLocalCache tests the client methods with a local cache in front of redis
+func TestLocalCache(t *testing.T) {
+	t.Run("get is served locally after first read", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+		loadMockLocalCache(client, LocalCacheOptions{})
+
+		getCmd := conn.Command(GetCommand, testKey).Expect(testStringValue)
+		conn.Command(PTTLCommand, testKey).Expect(ttlPersistent)
+
+		for i := 0; i < 3; i++ {
+			val, err := Get(context.Background(), client, testKey)
+			require.NoError(t, err)
+			assert.Equal(t, testStringValue, val)
+		}
+		assert.Equal(t, 1, conn.Stats(getCmd))
+
+		data, err := GetBytes(context.Background(), client, testKey)
+		require.NoError(t, err)
+		assert.Equal(t, []byte(testStringValue), data)
+		assert.Equal(t, 1, conn.Stats(getCmd))
+	})
+
+	t.Run("misses are not cached", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+		loadMockLocalCache(client, LocalCacheOptions{})
+
+		getCmd := conn.Command(GetCommand, testKey).Expect(nil)
+		conn.Command(PTTLCommand, testKey).Expect(int64(-2))
+
+		_, err := Get(context.Background(), client, testKey)
+		require.Error(t, err)
+		_, err = Get(context.Background(), client, testKey)
+		require.Error(t, err)
+		assert.Equal(t, 2, conn.Stats(getCmd))
+	})
+
+	t.Run("local entries expire with the redis key", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+		loadMockLocalCache(client, LocalCacheOptions{})
+
+		getCmd := conn.Command(GetCommand, testKey).Expect(testStringValue)
+		conn.Command(PTTLCommand, testKey).Expect(int64(20))
+
+		_, err := Get(context.Background(), client, testKey)
+		require.NoError(t, err)
+		_, err = Get(context.Background(), client, testKey)
+		require.NoError(t, err)
+		assert.Equal(t, 1, conn.Stats(getCmd))
+
+		time.Sleep(30 * time.Millisecond)
+		_, err = Get(context.Background(), client, testKey)
+		require.NoError(t, err)
+		assert.Equal(t, 2, conn.Stats(getCmd))
+	})
+
+	t.Run("get many entries expire with the redis keys", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+		lc := loadMockLocalCache(client, LocalCacheOptions{})
+
+		conn.Command(MultiGetCommand, "a", "b", "c").Expect([]interface{}{[]byte("1"), []byte("2"), nil})
+		conn.GenericCommand(PTTLCommand).Handle(func(args []interface{}) (interface{}, error) {
+			if args[0] == "a" {
+				return int64(20), nil
+			}
+			return ttlPersistent, nil
+		})
+
+		_, err := GetMany(context.Background(), client, "a", "b", "c")
+		require.NoError(t, err)
+
+		time.Sleep(30 * time.Millisecond)
+		_, ok := lc.store.get("a")
+		assert.False(t, ok)
+		_, ok = lc.store.get("b")
+		assert.True(t, ok)
+	})
+
+	t.Run("expire invalidates and broadcasts", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+		lc := loadMockLocalCache(client, LocalCacheOptions{})
+		lc.store.set(testKey, "old", lc.store.currentEpoch())
+
+		conn.Command(ExpireCommand, testKey, int64(10)).Expect(int64(1))
+		pubCmd := conn.GenericCommand(PublishCommand).Expect(int64(1))
+
+		require.NoError(t, Expire(context.Background(), client, testKey, 10*time.Second))
+		assert.True(t, pubCmd.Called)
+		_, ok := lc.store.get(testKey)
+		assert.False(t, ok)
+	})
+
+	t.Run("set invalidates and broadcasts", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+		lc := loadMockLocalCache(client, LocalCacheOptions{})
+		lc.store.set(testKey, "old", lc.store.currentEpoch())
+
+		setCmd := conn.Command(SetCommand, testKey, testStringValue)
+		pubCmd := conn.Command(PublishCommand, LocalCacheChannel, []byte(`{"keys":["`+testKey+`"]}`)).Expect(int64(1))
+
+		err := Set(context.Background(), client, testKey, testStringValue)
+		require.NoError(t, err)
+		assert.True(t, setCmd.Called)
+		assert.True(t, pubCmd.Called)
+
+		_, ok := lc.store.get(testKey)
+		assert.False(t, ok)
+	})
+
+	t.Run("failed set does not broadcast", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+		loadMockLocalCache(client, LocalCacheOptions{})
+
+		conn.Command(SetCommand, testKey, testStringValue).ExpectError(errTestLoader)
+		pubCmd := conn.GenericCommand(PublishCommand).Expect(int64(1))
+
+		err := Set(context.Background(), client, testKey, testStringValue)
+		require.Error(t, err)
+		assert.False(t, pubCmd.Called)
+	})
+
+	t.Run("kill by dependency invalidates dependent keys", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+		lc := loadMockLocalCache(client, LocalCacheOptions{})
+		lc.store.set(testKey, testStringValue, lc.store.currentEpoch())
+
+		conn.Command(MembersCommand, DependencyPrefix+testDependantKey).Expect([]interface{}{[]byte(testKey)})
+		conn.Command(EvalCommand, killByDependencySha, 1, DependencyPrefix+testDependantKey, testDependantKey).
+			Expect(int64(1))
+		conn.Command(DeleteCommand, testDependantKey).Expect(int64(0))
+		pubCmd := conn.Command(PublishCommand, LocalCacheChannel,
+			[]byte(`{"keys":["`+testKey+`","`+testDependantKey+`"]}`)).Expect(int64(1))
+
+		total, err := KillByDependency(context.Background(), client, testDependantKey)
+		require.NoError(t, err)
+		assert.Equal(t, 1, total)
+		assert.True(t, pubCmd.Called)
+
+		_, ok := lc.store.get(testKey)
+		assert.False(t, ok)
+	})
+
+	t.Run("destroy cache flushes", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+		lc := loadMockLocalCache(client, LocalCacheOptions{})
+		lc.store.set(testKey, testStringValue, lc.store.currentEpoch())
+
+		conn.Command(FlushAllCommand)
+		pubCmd := conn.Command(PublishCommand, LocalCacheChannel, []byte(`{"all":true}`)).Expect(int64(1))
+
+		err := DestroyCache(context.Background(), client)
+		require.NoError(t, err)
+		assert.True(t, pubCmd.Called)
+		assert.Equal(t, 0, lc.store.len())
+	})
+
+	t.Run("disable drops local cache", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+		loadMockLocalCache(client, LocalCacheOptions{})
+
+		client.DisableLocalCache()
+		assert.Nil(t, client.localCache())
+	})
+
+	t.Run("enable with real redis", func(t *testing.T) {
+		if testing.Short() {
+			t.Skip("skipping live local redis tests")
+		}
+
+		writer, conn, err := loadRealRedis(t)
+		require.NoError(t, err)
+		defer writer.CloseAll(conn)
+		require.NoError(t, clearRealRedis(conn))
+
+		reader, readerConn, err := loadRealRedis(t)
+		require.NoError(t, err)
+		defer reader.CloseAll(readerConn)
+
+		require.NoError(t, reader.EnableLocalCache(context.Background(), LocalCacheOptions{}))
+		require.ErrorIs(t, reader.EnableLocalCache(context.Background(), LocalCacheOptions{}), ErrLocalCacheEnabled)
+
+		require.NoError(t, Set(context.Background(), writer, testKey, "first"))
+		val, err := Get(context.Background(), reader, testKey)
+		require.NoError(t, err)
+		assert.Equal(t, "first", val)
+
+		require.NoError(t, writer.EnableLocalCache(context.Background(), LocalCacheOptions{}))
+		require.NoError(t, Set(context.Background(), writer, testKey, "second"))
+
+		assert.Eventually(t, func() bool {
+			val, err = Get(context.Background(), reader, testKey)
+			return err == nil && val == "second"
+		}, 2*time.Second, 10*time.Millisecond)
+	})
+}
@@ -0,0 +1,414 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// ErrNamespaceFlush is returned when FLUSHALL or FLUSHDB is sent on a namespaced connection
+var ErrNamespaceFlush = errors.New("flushing the database is not allowed on a namespaced connection")
+
+// Commands whose only key is the first argument
+var namespaceFirstKeyCommands = map[string]bool{
+	AddToSetCommand:          true,
+	ExpireCommand:            true,
+	GetCommand:               true,
+	HashGetCommand:           true,
+	HashKeySetCommand:        true,
+	HashMapGetCommand:        true,
+	HashMapSetCommand:        true,
+	HashScanCommand:          true,
+	IsMemberCommand:          true,
+	ListPushCommand:          true,
+	ListRangeCommand:         true,
+	MembersCommand:           true,
+	RemoveMemberCommand:      true,
+	SetCommand:               true,
+	SetExpirationCommand:     true,
+	SetScanCommand:           true,
+	SortedSetAddCommand:      true,
+	SortedSetCardCommand:     true,
+	SortedSetPopMinCommand:   true,
+	SortedSetRangeByScoreCmd: true,
+	SortedSetRangeCommand:    true,
+	SortedSetRemCommand:      true,
+	SortedSetScanCommand:     true,
+	SortedSetScoreCommand:    true,
+	StreamAddCommand:         true,
+	StreamLenCommand:         true,
+	StreamTrimCommand:        true,
+
+	// Common single-key commands used through custom connections
+	"APPEND": true, "DECR": true, "DECRBY": true, "GETDEL": true, "GETEX": true, "GETSET": true,
+	"HDEL": true, "HEXISTS": true, "HGETALL": true, "HINCRBY": true, "HKEYS": true, "HLEN": true,
+	"HVALS": true, "INCR": true, "INCRBY": true, "LLEN": true, "LPOP": true, "LPUSH": true,
+	"PERSIST": true, "PEXPIRE": true, "PTTL": true, "RPOP": true, "SCARD": true, "SETNX": true,
+	"STRLEN": true, "TTL": true, "TYPE": true, "ZINCRBY": true, "ZRANK": true, "ZREVRANGE": true,
+}
+
+// Commands where every argument is a key
+var namespaceAllKeysCommands = map[string]bool{
+	DeleteCommand:   true,
+	ExistsCommand:   true,
+	MultiGetCommand: true,
+	UnlinkCommand:   true,
+	WatchCommand:    true,
+}
+
+// Commands laid out as: script or function, numkeys, keys..., args...
+var namespaceScriptCommands = map[string]bool{
+	EvalCommand:             true,
+	"EVAL":                  true,
+	"EVALSHA_RO":            true,
+	"EVAL_RO":               true,
+	FunctionCallCommand:     true,
+	FunctionCallReadOnlyCmd: true,
+}
+
+// WithNamespace returns a client that transparently prefixes every key with the namespace
+// (e.g. "svc:"), including dependency sets, the keys used by the dependency and lock scripts,
+// and lock names. Keys returned by Scan, GetAllKeys and the members of dependency sets have
+// the prefix stripped again. Pub/sub channel names are not prefixed.
+//
+// Commands sent on a connection from the namespaced client (GetConnectionWithContext) are
+// rewritten as well, so Raw methods work unchanged. Commands this package does not know are
+// sent as-is, and FLUSHALL/FLUSHDB are refused (DestroyCache only deletes the namespace).
+//
+// The returned client shares the pool and local cache of c. Closing it does not close the
+// pool; close the original client when done. Namespaces nest: c.WithNamespace("a:").WithNamespace("b:")
+// prefixes keys with "a:b:".
+func (c *Client) WithNamespace(namespace string) *Client {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return &Client{
+		DependencyScriptSha: c.DependencyScriptSha,
+		Pool:                c.Pool,
+		ScriptsLoaded:       append([]string(nil), c.ScriptsLoaded...),
+		namespace:           c.namespace + namespace,
+		parent:              c.root(),
+	}
+}
+
+// Namespace returns the key prefix of the client (empty when not namespaced)
+func (c *Client) Namespace() string {
+	return c.namespace
+}
+
+// root returns the client that owns the pool and local cache
+func (c *Client) root() *Client {
+	if c.parent != nil {
+		return c.parent
+	}
+	return c
+}
+
+// physicalKeys returns the keys as stored in redis (with the namespace)
+func (c *Client) physicalKeys(keys ...string) []string {
+	if len(c.namespace) == 0 {
+		return keys
+	}
+	out := make([]string, len(keys))
+	for i, key := range keys {
+		out[i] = c.namespace + key
+	}
+	return out
+}
+
+// unprefixedConn returns the connection without the namespace rewriting (for keys shared by
+// every namespace, like the invalidation stream)
+func unprefixedConn(conn redis.Conn) redis.Conn {
+	if nc, ok := conn.(*namespaceConn); ok {
+		return nc.Conn
+	}
+	return conn
+}
+
+// replyFunc post-processes a reply (e.g. strips the namespace from returned keys)
+type replyFunc func(reply interface{}, err error) (interface{}, error)
+
+// namespaceConn prefixes the keys of every command sent through it
+type namespaceConn struct {
+	redis.Conn
+	namespace string
+	pending   []replyFunc // reply handlers of commands queued with Send()
+}
+
+// newNamespaceConn wraps the connection
+func newNamespaceConn(conn redis.Conn, namespace string) *namespaceConn {
+	return &namespaceConn{Conn: conn, namespace: namespace}
+}
+
+// Do rewrites the command and sends it
+func (c *namespaceConn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	if len(commandName) == 0 {
+		return c.flushPending(c.Conn.Do(""))
+	}
+	args, handle, err := c.rewrite(commandName, args)
+	if err != nil {
+		return nil, err
+	}
+	c.pending = nil // Do only returns the reply of the last command
+	return handle(c.Conn.Do(commandName, args...))
+}
+
+// DoContext rewrites the command and sends it, respecting ctx (when supported by the connection)
+func (c *namespaceConn) DoContext(ctx context.Context, commandName string, args ...interface{}) (interface{}, error) {
+	args, handle, err := c.rewrite(commandName, args)
+	if err != nil {
+		return nil, err
+	}
+	c.pending = nil
+	return handle(redis.DoContext(c.Conn, ctx, commandName, args...))
+}
+
+// DoWithTimeout rewrites the command and sends it with a read timeout (when supported by the connection)
+func (c *namespaceConn) DoWithTimeout(timeout time.Duration, commandName string,
+	args ...interface{},
+) (interface{}, error) {
+	args, handle, err := c.rewrite(commandName, args)
+	if err != nil {
+		return nil, err
+	}
+	c.pending = nil
+	return handle(redis.DoWithTimeout(c.Conn, timeout, commandName, args...))
+}
+
+// Send rewrites the command and queues it
+func (c *namespaceConn) Send(commandName string, args ...interface{}) error {
+	args, handle, err := c.rewrite(commandName, args)
+	if err != nil {
+		return err
+	}
+	if err = c.Conn.Send(commandName, args...); err != nil {
+		return err
+	}
+	c.pending = append(c.pending, handle)
+	return nil
+}
+
+// Receive returns the next reply, processed for the command it belongs to
+func (c *namespaceConn) Receive() (interface{}, error) {
+	return c.nextHandler()(c.Conn.Receive())
+}
+
+// ReceiveContext returns the next reply, respecting ctx (when supported by the connection)
+func (c *namespaceConn) ReceiveContext(ctx context.Context) (interface{}, error) {
+	return c.nextHandler()(redis.ReceiveContext(c.Conn, ctx))
+}
+
+// ReceiveWithTimeout returns the next reply with a read timeout (when supported by the connection)
+func (c *namespaceConn) ReceiveWithTimeout(timeout time.Duration) (interface{}, error) {
+	return c.nextHandler()(redis.ReceiveWithTimeout(c.Conn, timeout))
+}
+
+// nextHandler pops the reply handler of the oldest queued command
+// Replies without a queued command (e.g. pub/sub messages) are returned unchanged
+func (c *namespaceConn) nextHandler() replyFunc {
+	if len(c.pending) == 0 {
+		return passReply
+	}
+	handle := c.pending[0]
+	c.pending = c.pending[1:]
+	return handle
+}
+
+// flushPending processes the replies returned by Do("") for every queued command
+func (c *namespaceConn) flushPending(reply interface{}, err error) (interface{}, error) {
+	handlers := c.pending
+	c.pending = nil
+	replies, ok := reply.([]interface{})
+	if err != nil || !ok {
+		return reply, err
+	}
+	for i := range replies {
+		if i < len(handlers) {
+			replies[i], _ = handlers[i](replies[i], nil)
+		}
+	}
+	return replies, nil
+}
+
+// rewrite prefixes the keys in args and returns the handler for the reply
+func (c *namespaceConn) rewrite(commandName string, args []interface{}) ([]interface{}, replyFunc, error) {
+	command := strings.ToUpper(commandName)
+	switch {
+	case command == FlushAllCommand || command == "FLUSHDB":
+		return nil, nil, ErrNamespaceFlush
+	case namespaceAllKeysCommands[command]:
+		return c.prefixArgs(args, 0, len(args)), passReply, nil
+	case command == MultiSetCommand:
+		return c.prefixPairs(args), passReply, nil
+	case command == ScanCommand:
+		return c.prefixMatch(args, 1), c.stripScan, nil
+	case command == KeysCommand:
+		return c.prefixPattern(args), c.stripList, nil
+	case namespaceScriptCommands[command]:
+		return c.prefixScriptKeys(args), passReply, nil
+	case command == StreamReadCommand:
+		return c.prefixStreams(args), passReply, nil
+	case namespaceFirstKeyCommands[command]:
+		return c.prefixFirstKey(command, args)
+	}
+	return args, passReply, nil
+}
+
+// prefixFirstKey prefixes the first argument, and the members of dependency sets (which are keys too)
+func (c *namespaceConn) prefixFirstKey(command string, args []interface{}) ([]interface{}, replyFunc, error) {
+	if len(args) == 0 {
+		return args, passReply, nil
+	}
+	if !isDependencySet(args[0]) {
+		return c.prefixArgs(args, 0, 1), passReply, nil
+	}
+	switch command {
+	case AddToSetCommand, RemoveMemberCommand, IsMemberCommand:
+		return c.prefixArgs(args, 0, len(args)), passReply, nil
+	case MembersCommand:
+		return c.prefixArgs(args, 0, 1), c.stripList, nil
+	case SetScanCommand:
+		return c.prefixMatch(c.prefixArgs(args, 0, 1), 2), c.stripScan, nil
+	}
+	return c.prefixArgs(args, 0, 1), passReply, nil
+}
+
+// prefixArgs returns a copy of args with args[from:to] prefixed
+func (c *namespaceConn) prefixArgs(args []interface{}, from, to int) []interface{} {
+	out := make([]interface{}, len(args))
+	copy(out, args)
+	for i := from; i < to && i < len(out); i++ {
+		out[i] = c.key(out[i])
+	}
+	return out
+}
+
+// prefixPairs prefixes every key of a key/value argument list (MSET)
+func (c *namespaceConn) prefixPairs(args []interface{}) []interface{} {
+	out := make([]interface{}, len(args))
+	copy(out, args)
+	for i := 0; i < len(out); i += 2 {
+		out[i] = c.key(out[i])
+	}
+	return out
+}
+
+// prefixMatch prefixes the MATCH pattern (options start at args[start]), adding one if missing
+func (c *namespaceConn) prefixMatch(args []interface{}, start int) []interface{} {
+	out := make([]interface{}, len(args), len(args)+2)
+	copy(out, args)
+	for i := start; i+1 < len(out); i++ {
+		if option, ok := out[i].(string); ok && strings.EqualFold(option, "MATCH") {
+			out[i+1] = escapePattern(c.namespace) + argString(out[i+1])
+			return out
+		}
+	}
+	return append(out, "MATCH", escapePattern(c.namespace)+"*")
+}
+
+// prefixPattern prefixes the KEYS pattern
+func (c *namespaceConn) prefixPattern(args []interface{}) []interface{} {
+	if len(args) == 0 {
+		return args
+	}
+	out := make([]interface{}, len(args))
+	copy(out, args)
+	out[0] = escapePattern(c.namespace) + argString(out[0])
+	return out
+}
+
+// prefixScriptKeys prefixes the KEYS of EVAL/EVALSHA/FCALL (script, numkeys, keys..., args...)
+func (c *namespaceConn) prefixScriptKeys(args []interface{}) []interface{} {
+	if len(args) < 2 {
+		return args
+	}
+	numKeys, ok := argInt(args[1])
+	if !ok {
+		return args
+	}
+	return c.prefixArgs(args, 2, 2+numKeys)
+}
+
+// prefixStreams prefixes the stream keys of XREAD (... STREAMS key [key ...] id [id ...])
+func (c *namespaceConn) prefixStreams(args []interface{}) []interface{} {
+	for i, arg := range args {
+		if option, ok := arg.(string); ok && strings.EqualFold(option, "STREAMS") {
+			keys := (len(args) - i - 1) / 2
+			return c.prefixArgs(args, i+1, i+1+keys)
+		}
+	}
+	return args
+}
+
+// key prefixes a single key argument
+func (c *namespaceConn) key(arg interface{}) interface{} {
+	if b, ok := arg.([]byte); ok {
+		return append([]byte(c.namespace), b...)
+	}
+	return c.namespace + argString(arg)
+}
+
+// stripList strips the namespace from a list of keys
+func (c *namespaceConn) stripList(reply interface{}, err error) (interface{}, error) {
+	values, ok := reply.([]interface{})
+	if err != nil || !ok {
+		return reply, err
+	}
+	out := make([]interface{}, len(values))
+	prefix := []byte(c.namespace)
+	for i, value := range values {
+		if b, isBytes := value.([]byte); isBytes {
+			value = bytes.TrimPrefix(b, prefix)
+		}
+		out[i] = value
+	}
+	return out, nil
+}
+
+// stripScan strips the namespace from a [cursor, [keys...]] reply
+func (c *namespaceConn) stripScan(reply interface{}, err error) (interface{}, error) {
+	values, ok := reply.([]interface{})
+	if err != nil || !ok || len(values) != 2 {
+		return reply, err
+	}
+	page, err := c.stripList(values[1], nil)
+	return []interface{}{values[0], page}, err
+}
+
+// passReply returns the reply unchanged
+func passReply(reply interface{}, err error) (interface{}, error) {
+	return reply, err
+}
+
+// isDependencySet reports whether the (un-prefixed) key is a dependency set
+func isDependencySet(key interface{}) bool {
+	return strings.HasPrefix(argString(key), DependencyPrefix)
+}
+
+// argString formats a command argument the way it is sent to redis
+func argString(arg interface{}) string {
+	switch v := arg.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	}
+	return fmt.Sprint(arg)
+}
+
+// argInt parses an integer command argument
+func argInt(arg interface{}) (int, bool) {
+	switch v := arg.(type) {
+	case int:
+		return v, true
+	case int64:
+		return int(v), true
+	}
+	n, err := strconv.Atoi(argString(arg))
+	return n, err == nil
+}
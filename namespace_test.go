@@ -0,0 +1,251 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testNamespace is the namespace used in the tests
+const testNamespace = "svc:"
+
+// TestNamespaceConnRewrite tests how commands are rewritten on a namespaced connection
+func TestNamespaceConnRewrite(t *testing.T) {
+	tests := []struct {
+		name     string
+		command  string
+		args     []interface{}
+		expected []interface{}
+	}{
+		{"get", GetCommand, []interface{}{"k"}, []interface{}{"svc:k"}},
+		{"lower case command", "get", []interface{}{"k"}, []interface{}{"svc:k"}},
+		{"set only prefixes the key", SetCommand, []interface{}{"k", "v"}, []interface{}{"svc:k", "v"}},
+		{"bytes key", GetCommand, []interface{}{[]byte("k")}, []interface{}{[]byte("svc:k")}},
+		{"hash fields are not keys", HashMapGetCommand, []interface{}{"h", "f1", "f2"}, []interface{}{"svc:h", "f1", "f2"}},
+		{"multi get", MultiGetCommand, []interface{}{"a", "b"}, []interface{}{"svc:a", "svc:b"}},
+		{"multi set", MultiSetCommand, []interface{}{"a", "1", "b", "2"}, []interface{}{"svc:a", "1", "svc:b", "2"}},
+		{"delete all keys", DeleteCommand, []interface{}{"a", "b"}, []interface{}{"svc:a", "svc:b"}},
+		{"plain set members", AddToSetCommand, []interface{}{"s", "m"}, []interface{}{"svc:s", "m"}},
+		{
+			"dependency set members", AddToSetCommand,
+			[]interface{}{DependencyPrefix + "d", "k"},
+			[]interface{}{"svc:" + DependencyPrefix + "d", "svc:k"},
+		},
+		{
+			"dependency set scan", SetScanCommand,
+			[]interface{}{DependencyPrefix + "d", "0", "MATCH", "user:*"},
+			[]interface{}{"svc:" + DependencyPrefix + "d", "0", "MATCH", "svc:user:*"},
+		},
+		{"scan adds match", ScanCommand, []interface{}{"0", "COUNT", 10}, []interface{}{"0", "COUNT", 10, "MATCH", "svc:*"}},
+		{"scan prefixes match", ScanCommand, []interface{}{"0", "MATCH", "a*"}, []interface{}{"0", "MATCH", "svc:a*"}},
+		{"keys", KeysCommand, []interface{}{"*"}, []interface{}{"svc:*"}},
+		{
+			"script keys", EvalCommand,
+			[]interface{}{"sha", 2, "a", "b", "arg"},
+			[]interface{}{"sha", 2, "svc:a", "svc:b", "arg"},
+		},
+		{
+			"stream read", StreamReadCommand,
+			[]interface{}{"COUNT", 5, "STREAMS", "s1", "s2", "0", "0"},
+			[]interface{}{"COUNT", 5, "STREAMS", "svc:s1", "svc:s2", "0", "0"},
+		},
+		{"channels are not prefixed", PublishCommand, []interface{}{"ch", "msg"}, []interface{}{"ch", "msg"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newNamespaceConn(nil, testNamespace)
+			args, handle, err := c.rewrite(test.command, test.args)
+			require.NoError(t, err)
+			assert.NotNil(t, handle)
+			assert.Equal(t, test.expected, args)
+		})
+	}
+
+	t.Run("flush is refused", func(t *testing.T) {
+		c := newNamespaceConn(nil, testNamespace)
+		_, _, err := c.rewrite(FlushAllCommand, nil)
+		require.ErrorIs(t, err, ErrNamespaceFlush)
+		_, _, err = c.rewrite("flushdb", nil)
+		require.ErrorIs(t, err, ErrNamespaceFlush)
+	})
+}
+
+// TestWithNamespace tests the method WithNamespace()
+func TestWithNamespace(t *testing.T) {
+	t.Run("prefixes keys and dependencies", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+		ns := client.WithNamespace(testNamespace)
+		assert.Equal(t, testNamespace, ns.Namespace())
+
+		setCmd := conn.Command(SetCommand, "svc:"+testKey, testStringValue).Expect("OK")
+		conn.Command(MultiCommand).Expect("OK")
+		addCmd := conn.Command(AddToSetCommand, "svc:"+DependencyPrefix+testDependantKey, "svc:"+testKey).Expect("QUEUED")
+		reverseCmd := conn.Command(AddToSetCommand, "svc:"+ReverseDependencyPrefix+testKey, testDependantKey).
+			Expect("QUEUED")
+		conn.Command(ExecuteCommand).Expect([]interface{}{int64(1)})
+		conn.Command(GetCommand, "svc:"+testKey).Expect([]byte(testStringValue))
+
+		ctx := context.Background()
+		require.NoError(t, Set(ctx, ns, testKey, testStringValue, testDependantKey))
+		value, err := Get(ctx, ns, testKey)
+		require.NoError(t, err)
+		assert.Equal(t, testStringValue, value)
+		assert.True(t, setCmd.Called)
+		assert.True(t, addCmd.Called)
+		assert.True(t, reverseCmd.Called)
+	})
+
+	t.Run("kill by dependency", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+		ns := client.WithNamespace(testNamespace)
+
+		evalCmd := conn.Command(EvalCommand, killByDependencySha, 1, "svc:"+DependencyPrefix+testDependantKey,
+			testDependantKey).
+			Expect(int64(2))
+		delCmd := conn.Command(DeleteCommand, "svc:"+testDependantKey).Expect(int64(1))
+
+		total, err := KillByDependency(context.Background(), ns, testDependantKey)
+		require.NoError(t, err)
+		assert.Equal(t, 3, total)
+		assert.True(t, evalCmd.Called)
+		assert.True(t, delCmd.Called)
+	})
+
+	t.Run("strips the namespace from scans and dependency sets", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+		ns := client.WithNamespace(testNamespace)
+
+		conn.Command(ScanCommand, "0", "MATCH", "svc:user:*").Expect(scanPage("0", "svc:user:1", "svc:user:2"))
+		conn.Command(MembersCommand, "svc:"+DependencyPrefix+testDependantKey).Expect([]interface{}{[]byte("svc:" + testKey)})
+		conn.Command(MembersCommand, "svc:plain").Expect([]interface{}{[]byte("svc:member")})
+
+		ctx := context.Background()
+		var keys []string
+		for key, err := range Scan(ctx, ns, ScanOptions{Match: "user:*"}) {
+			require.NoError(t, err)
+			keys = append(keys, key)
+		}
+		assert.Equal(t, []string{"user:1", "user:2"}, keys)
+
+		members, err := SetMembers(ctx, ns, DependencyPrefix+testDependantKey)
+		require.NoError(t, err)
+		assert.Equal(t, []string{testKey}, members)
+
+		// Members of plain sets are values, not keys
+		members, err = SetMembers(ctx, ns, "plain")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"svc:member"}, members)
+	})
+
+	t.Run("pipelined replies", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+		ns := client.WithNamespace(testNamespace)
+
+		conn.Command(KeysCommand, "svc:a*").Expect([]interface{}{[]byte("svc:a1")})
+		conn.Command(GetCommand, "svc:a1").Expect([]byte(testStringValue))
+
+		nsConn, err := ns.GetConnectionWithContext(context.Background())
+		require.NoError(t, err)
+		defer ns.CloseConnection(nsConn)
+
+		require.NoError(t, nsConn.Send(KeysCommand, "a*"))
+		require.NoError(t, nsConn.Send(GetCommand, "a1"))
+		require.NoError(t, nsConn.Flush())
+		keys, err := nsConn.Receive()
+		require.NoError(t, err)
+		assert.Equal(t, []interface{}{[]byte("a1")}, keys)
+		value, err := nsConn.Receive()
+		require.NoError(t, err)
+		assert.Equal(t, []byte(testStringValue), value)
+	})
+
+	t.Run("destroy cache only deletes the namespace", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+		ns := client.WithNamespace(testNamespace)
+
+		flushCmd := conn.Command(FlushAllCommand).Expect("OK")
+		conn.Command(ScanCommand, "0", "MATCH", "svc:*", "COUNT", defaultDeleteBatchSize).
+			Expect(scanPage("0", "svc:"+testKey))
+		conn.Command(MembersCommand, "svc:"+ReverseDependencyPrefix+testKey).Expect([]interface{}{[]byte(testDependantKey)})
+		unlinkCmd := conn.Command(UnlinkCommand, "svc:"+testKey).Expect(int64(1))
+		reverseCmd := conn.Command(UnlinkCommand, "svc:"+ReverseDependencyPrefix+testKey).Expect(int64(0))
+		remCmd := conn.Command(RemoveMemberCommand, "svc:"+DependencyPrefix+testDependantKey, "svc:"+testKey).
+			Expect(int64(1))
+
+		require.NoError(t, DestroyCache(context.Background(), ns))
+		assert.True(t, unlinkCmd.Called)
+		assert.True(t, reverseCmd.Called)
+		assert.True(t, remCmd.Called)
+		assert.False(t, flushCmd.Called)
+
+		nsConn, err := ns.GetConnectionWithContext(context.Background())
+		require.NoError(t, err)
+		defer ns.CloseConnection(nsConn)
+		require.ErrorIs(t, DestroyCacheRaw(nsConn), ErrNamespaceFlush)
+	})
+
+	t.Run("namespaces nest and share the pool", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+		nested := client.WithNamespace("a:").WithNamespace("b:")
+		assert.Equal(t, "a:b:", nested.Namespace())
+
+		getCmd := conn.Command(GetCommand, "a:b:"+testKey).Expect([]byte(testStringValue))
+		_, err := Get(context.Background(), nested, testKey)
+		require.NoError(t, err)
+		assert.True(t, getCmd.Called)
+
+		// Closing the namespaced client leaves the original usable
+		nested.Close()
+		_, err = nested.GetConnectionWithContext(context.Background())
+		require.ErrorIs(t, err, ErrRedisPoolNil)
+		assert.NotNil(t, client.Pool)
+	})
+
+	t.Run("local cache uses the namespaced key", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+		lc := loadMockLocalCache(client, LocalCacheOptions{})
+		ns := client.WithNamespace(testNamespace)
+
+		getCmd := conn.Command(GetCommand, "svc:"+testKey).Expect([]byte(testStringValue))
+		conn.Command(PTTLCommand, "svc:"+testKey).Expect(ttlPersistent)
+		_, err := Get(context.Background(), ns, testKey)
+		require.NoError(t, err)
+		assert.True(t, getCmd.Called)
+
+		value, ok := lc.store.get("svc:" + testKey)
+		assert.True(t, ok)
+		assert.Equal(t, testStringValue, value)
+		_, ok = client.localGet(testKey)
+		assert.False(t, ok)
+	})
+}
+
+// ExampleClient_WithNamespace is an example of the method WithNamespace()
+func ExampleClient_WithNamespace() {
+	// Load a mocked redis for testing/examples
+	client, conn := loadMockRedis()
+
+	// Close connections at end of request
+	defer client.CloseAll(conn)
+
+	// Every key used through the namespaced client is prefixed
+	users := client.WithNamespace("users:")
+	conn.Command(SetCommand, "users:"+testKey, testStringValue).Expect("OK")
+
+	// Set the key
+	if err := Set(context.Background(), users, testKey, testStringValue); err != nil {
+		return
+	}
+	fmt.Printf("set key: %s", testKey)
+	// Output:set key: test-key-name
+}
@@ -0,0 +1,181 @@
+package cache
+
+import (
+	"errors"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// ErrPipelineNotExecuted is returned by Future.Result() before Pipeline.Exec() has run
+var ErrPipelineNotExecuted = errors.New("pipeline has not been executed")
+
+// Pipeline queues commands on a connection and sends them all in a single round trip
+//
+// Queue commands (Get, HashGet, ... or Do), then call Exec() and read each Future.
+// A Pipeline is not safe for concurrent use, and the connection should not be used
+// for anything else until Exec() returns.
+type Pipeline struct {
+	conn    redis.Conn
+	pending []func(reply interface{}, err error) // resolves the futures, in the order sent
+	err     error                                // first error returned by Send()
+}
+
+// Future holds the result of a queued command, available once Pipeline.Exec() has run
+type Future[T any] struct {
+	value    T
+	err      error
+	resolved bool
+}
+
+// Result returns the value and error of the command
+// Returns ErrPipelineNotExecuted if Exec() has not run yet
+func (f *Future[T]) Result() (T, error) {
+	if !f.resolved {
+		var zero T
+		return zero, ErrPipelineNotExecuted
+	}
+	return f.value, f.err
+}
+
+// NewPipeline creates a pipeline on the connection
+// Uses existing connection (does not close connection)
+func NewPipeline(conn redis.Conn) *Pipeline {
+	return &Pipeline{conn: conn}
+}
+
+// Len returns the number of commands waiting for Exec()
+func (p *Pipeline) Len() int {
+	return len(p.pending)
+}
+
+// Exec flushes all queued commands and resolves their futures
+// Redis errors for a single command are only returned by its Future. A connection error
+// fails every remaining future and is returned.
+func (p *Pipeline) Exec() error {
+	pending, sendErr := p.pending, p.err
+	p.pending, p.err = nil, nil
+	if len(pending) == 0 {
+		return sendErr
+	}
+
+	if err := p.conn.Flush(); err != nil {
+		failAll(pending, err)
+		return err
+	}
+	for i, resolve := range pending {
+		reply, err := p.conn.Receive()
+		resolve(reply, err)
+
+		var redisErr redis.Error
+		if err != nil && !errors.As(err, &redisErr) {
+			failAll(pending[i+1:], err)
+			return err
+		}
+	}
+	return sendErr
+}
+
+// Do queues any command, the future holds the raw reply
+//
+// Spec: https://redis.io/commands
+func (p *Pipeline) Do(command string, args ...interface{}) *Future[interface{}] {
+	return queueCommand(p, passReply, command, args...)
+}
+
+// Get queues a GET of the key
+//
+// Spec: https://redis.io/commands/get
+func (p *Pipeline) Get(key string) *Future[string] {
+	return queueCommand(p, redis.String, GetCommand, key)
+}
+
+// GetBytes queues a GET of the key formatted in bytes
+//
+// Spec: https://redis.io/commands/get
+func (p *Pipeline) GetBytes(key string) *Future[[]byte] {
+	return queueCommand(p, redis.Bytes, GetCommand, key)
+}
+
+// HashGet queues an HGET of the field in the hash
+//
+// Spec: https://redis.io/commands/hget
+func (p *Pipeline) HashGet(hash, key string) *Future[string] {
+	return queueCommand(p, redis.String, HashGetCommand, hash, key)
+}
+
+// Exists queues an EXISTS of the key
+//
+// Spec: https://redis.io/commands/exists
+func (p *Pipeline) Exists(key string) *Future[bool] {
+	return queueCommand(p, redis.Bool, ExistsCommand, key)
+}
+
+// SetIsMember queues a SISMEMBER of the member in the set
+//
+// Spec: https://redis.io/commands/sismember
+func (p *Pipeline) SetIsMember(set, member interface{}) *Future[bool] {
+	return queueCommand(p, redis.Bool, IsMemberCommand, set, member)
+}
+
+// SortedSetCard queues a ZCARD of the sorted set
+//
+// Spec: https://redis.io/commands/zcard
+func (p *Pipeline) SortedSetCard(key string) *Future[int64] {
+	return queueCommand(p, redis.Int64, SortedSetCardCommand, key)
+}
+
+// SortedSetRangeWithScores queues a ZRANGE WITHSCORES of the sorted set
+//
+// Spec: https://redis.io/commands/zrange
+func (p *Pipeline) SortedSetRangeWithScores(key string, start, stop int64) *Future[[]SortedSetMember] {
+	return queueCommand(p, parseScanSortedSet, SortedSetRangeCommand, key, start, stop, "WITHSCORES")
+}
+
+// StreamLen queues an XLEN of the stream
+//
+// Spec: https://redis.io/commands/xlen
+func (p *Pipeline) StreamLen(key string) *Future[int64] {
+	return queueCommand(p, redis.Int64, StreamLenCommand, key)
+}
+
+// StreamRead queues an XREAD of the stream starting at startID (non-blocking)
+//
+// Spec: https://redis.io/commands/xread
+func (p *Pipeline) StreamRead(key, startID string, count int64) *Future[[]StreamEntry] {
+	return queueCommand(p, parseStreamReply, StreamReadCommand, "COUNT", count, "STREAMS", key, startID)
+}
+
+// queueCommand sends the command and registers how its reply is converted
+func queueCommand[T any](p *Pipeline, convert func(reply interface{}, err error) (T, error),
+	command string, args ...interface{},
+) *Future[T] {
+	future := &Future[T]{}
+	if err := p.conn.Send(command, args...); err != nil {
+		future.err, future.resolved = err, true
+		if p.err == nil {
+			p.err = err
+		}
+		return future
+	}
+	p.pending = append(p.pending, func(reply interface{}, err error) {
+		future.value, future.err = convert(reply, err)
+		future.resolved = true
+	})
+	return future
+}
+
+// failAll resolves every future with the error
+func failAll(pending []func(reply interface{}, err error), err error) {
+	for _, resolve := range pending {
+		resolve(nil, err)
+	}
+}
+
+// parseStreamReply parses an XREAD reply
+func parseStreamReply(reply interface{}, err error) ([]StreamEntry, error) {
+	var values []interface{}
+	if values, err = redis.Values(reply, err); err != nil {
+		return nil, err
+	}
+	return parseStreamEntries(values)
+}
@@ -0,0 +1,282 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestPipeline tests the Pipeline type
+func TestPipeline(t *testing.T) {
+	t.Run("typed futures", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.Command(GetCommand, testKey).Expect([]byte(testStringValue))
+		conn.Command(HashGetCommand, "hash", "field").Expect([]byte("value"))
+		conn.Command(IsMemberCommand, "set", "member").Expect(int64(1))
+		conn.Command(SortedSetCardCommand, "zset").Expect(int64(2))
+		conn.Command(SortedSetRangeCommand, "zset", int64(0), int64(-1), "WITHSCORES").
+			Expect([]interface{}{[]byte("m1"), []byte("1.5")})
+		conn.Command(StreamReadCommand, "COUNT", int64(10), "STREAMS", "stream", "0").Expect([]interface{}{
+			[]interface{}{[]byte("stream"), []interface{}{
+				[]interface{}{[]byte("1-0"), []interface{}{[]byte("f"), []byte("v")}},
+			}},
+		})
+
+		p := NewPipeline(conn)
+		get := p.Get(testKey)
+		hashGet := p.HashGet("hash", "field")
+		isMember := p.SetIsMember("set", "member")
+		card := p.SortedSetCard("zset")
+		members := p.SortedSetRangeWithScores("zset", 0, -1)
+		entries := p.StreamRead("stream", "0", 10)
+		assert.Equal(t, 6, p.Len())
+
+		_, err := get.Result()
+		require.ErrorIs(t, err, ErrPipelineNotExecuted)
+
+		require.NoError(t, p.Exec())
+		assert.Equal(t, 0, p.Len())
+
+		value, err := get.Result()
+		require.NoError(t, err)
+		assert.Equal(t, testStringValue, value)
+
+		value, err = hashGet.Result()
+		require.NoError(t, err)
+		assert.Equal(t, "value", value)
+
+		found, err := isMember.Result()
+		require.NoError(t, err)
+		assert.True(t, found)
+
+		count, err := card.Result()
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), count)
+
+		scored, err := members.Result()
+		require.NoError(t, err)
+		assert.Equal(t, []SortedSetMember{{Member: "m1", Score: 1.5}}, scored)
+
+		streamEntries, err := entries.Result()
+		require.NoError(t, err)
+		assert.Equal(t, []StreamEntry{{ID: "1-0", Fields: map[string]string{"f": "v"}}}, streamEntries)
+	})
+
+	t.Run("command errors stay with their future", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.Command(GetCommand, "bad").ExpectError(redis.Error("WRONGTYPE"))
+		conn.Command(GetCommand, testKey).Expect([]byte(testStringValue))
+
+		p := NewPipeline(conn)
+		bad := p.Get("bad")
+		good := p.Get(testKey)
+		require.NoError(t, p.Exec())
+
+		_, err := bad.Result()
+		require.Error(t, err)
+		value, err := good.Result()
+		require.NoError(t, err)
+		assert.Equal(t, testStringValue, value)
+	})
+
+	t.Run("missing key", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.Command(GetCommand, testKey).Expect(nil)
+
+		p := NewPipeline(conn)
+		get := p.Get(testKey)
+		require.NoError(t, p.Exec())
+		_, err := get.Result()
+		require.ErrorIs(t, err, redis.ErrNil)
+	})
+
+	t.Run("empty pipeline", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		require.NoError(t, NewPipeline(conn).Exec())
+	})
+
+	t.Run("pipeline using real redis", func(t *testing.T) {
+		if testing.Short() {
+			t.Skip("skipping live local redis tests")
+		}
+
+		client, conn, err := loadRealRedis(t)
+		require.NoError(t, err)
+		defer client.CloseAll(conn)
+		require.NoError(t, clearRealRedis(conn))
+
+		require.NoError(t, SetRaw(conn, testKey, testStringValue))
+		require.NoError(t, HashSetRaw(conn, "hash", "field", "value"))
+
+		p := NewPipeline(conn)
+		get := p.Get(testKey)
+		hashGet := p.HashGet("hash", "field")
+		exists := p.Exists("missing")
+		wrongType := p.HashGet(testKey, "field")
+		require.NoError(t, p.Exec())
+
+		value, _ := get.Result()
+		assert.Equal(t, testStringValue, value)
+		value, _ = hashGet.Result()
+		assert.Equal(t, "value", value)
+		found, _ := exists.Result()
+		assert.False(t, found)
+		_, err = wrongType.Result()
+		require.Error(t, err)
+	})
+}
+
+// TestBatcher tests the Batcher type
+func TestBatcher(t *testing.T) {
+	t.Run("coalesces concurrent callers", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		const callers = 5
+		for i := 0; i < callers; i++ {
+			conn.Command(GetCommand, fmt.Sprintf("key:%d", i)).Expect([]byte(fmt.Sprintf("value:%d", i)))
+		}
+
+		b := NewBatcher(client, BatcherOptions{Window: time.Minute, MaxSize: callers})
+		defer b.Close()
+
+		var wg sync.WaitGroup
+		values := make([]string, callers)
+		errs := make([]error, callers)
+		for i := 0; i < callers; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				values[i], errs[i] = b.Get(context.Background(), fmt.Sprintf("key:%d", i))
+			}(i)
+		}
+		wg.Wait()
+
+		for i := 0; i < callers; i++ {
+			require.NoError(t, errs[i])
+			assert.Equal(t, fmt.Sprintf("value:%d", i), values[i])
+		}
+	})
+
+	t.Run("sends when the window expires", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.Command(HashGetCommand, "hash", "field").Expect([]byte("value"))
+
+		b := NewBatcher(client, BatcherOptions{})
+		defer b.Close()
+
+		value, err := b.HashGet(context.Background(), "hash", "field")
+		require.NoError(t, err)
+		assert.Equal(t, "value", value)
+	})
+
+	t.Run("context canceled", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		getCmd := conn.Command(GetCommand, testKey).Expect([]byte(testStringValue))
+
+		b := NewBatcher(client, BatcherOptions{Window: time.Minute})
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := b.Get(ctx, testKey)
+		require.ErrorIs(t, err, context.Canceled)
+		b.Close()
+		assert.False(t, getCmd.Called)
+	})
+
+	t.Run("context canceled while queued", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		canceledCmd := conn.Command(GetCommand, "canceled").Expect([]byte(testStringValue))
+		conn.Command(GetCommand, testKey).Expect([]byte(testStringValue))
+
+		b := NewBatcher(client, BatcherOptions{Window: time.Minute, MaxSize: 2})
+		defer b.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		canceled := make(chan error, 1)
+		go func() {
+			_, err := b.Get(ctx, "canceled")
+			canceled <- err
+		}()
+		require.Eventually(t, func() bool {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			return len(b.queue) == 1
+		}, time.Second, time.Millisecond)
+		cancel()
+		require.ErrorIs(t, <-canceled, context.Canceled)
+
+		value, err := b.Get(context.Background(), testKey)
+		require.NoError(t, err)
+		assert.Equal(t, testStringValue, value)
+		assert.False(t, canceledCmd.Called, "the canceled command is dropped from the batch")
+	})
+
+	t.Run("closed batcher", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		b := NewBatcher(client, BatcherOptions{})
+		b.Close()
+
+		_, err := b.Do(context.Background(), GetCommand, testKey)
+		require.ErrorIs(t, err, ErrBatcherClosed)
+	})
+
+	t.Run("pool error", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		client.CloseAll(conn)
+
+		b := NewBatcher(client, BatcherOptions{})
+		defer b.Close()
+
+		_, err := b.Get(context.Background(), testKey)
+		require.ErrorIs(t, err, ErrRedisPoolNil)
+	})
+}
+
+// ExampleNewPipeline is an example of the method NewPipeline()
+func ExampleNewPipeline() {
+	// Load a mocked redis for testing/examples
+	client, conn := loadMockRedis()
+
+	// Close connections at end of request
+	defer client.CloseAll(conn)
+
+	// Mock the replies
+	conn.Command(GetCommand, testKey).Expect([]byte(testStringValue))
+	conn.Command(HashGetCommand, "hash", "field").Expect([]byte("value"))
+
+	// Queue both reads and send them in one round trip
+	p := NewPipeline(conn)
+	get := p.Get(testKey)
+	hashGet := p.HashGet("hash", "field")
+	if err := p.Exec(); err != nil {
+		return
+	}
+
+	value, _ := get.Result()
+	field, _ := hashGet.Result()
+	fmt.Printf("got: %s, %s", value, field)
+	// Output:got: test-string-value, value
+}
@@ -7,6 +7,7 @@ import (
 	"net/url"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gomodule/redigo/redis"
@@ -22,18 +23,34 @@ var (
 
 // Client is used to store the redis.Pool and additional fields/information
 type Client struct {
-	DependencyScriptSha string       // Stored SHA of the script after loaded
-	Pool                nrredis.Pool // Redis pool for the client (get connections)
-	ScriptsLoaded       []string     // List of scripts that have been loaded
-	mu                  sync.RWMutex // guards Pool and ScriptsLoaded
+	DependencyScriptSha string                    // Stored SHA of the script after loaded
+	Pool                nrredis.Pool              // Redis pool for the client (get connections)
+	ScriptsLoaded       []string                  // List of scripts that have been loaded
+	events              *InvalidationEventOptions // invalidation events (EnableInvalidationEvents)
+	flights             flightGroup               // collapses concurrent read-through loads (GetOrSet)
+	functions           atomic.Bool               // the shipped function library is loaded (LoadFunctions)
+	generations         generationCache           // generations read recently (Generation)
+	local               *localCache               // optional in-process cache (EnableLocalCache)
+	lockWaiters         lockWaiters               // shared lock release subscription (AcquireLock)
+	mu                  sync.RWMutex              // guards Pool, ScriptsLoaded, events, local and scripts
+	scripts             *scriptRegistry           // named scripts (RegisterNamedScript), created on first use
+	refreshes           refreshGate               // background refreshes of stale values (GetOrSetStale)
+	namespace           string                    // prefix added to every key (WithNamespace)
+	parent              *Client                   // owner of the pool, local cache and scripts (namespaced clients only)
 }
 
-// Close closes the connection pool
+// Close closes the connection pool (and the local cache, if enabled)
+// Namespaced clients (WithNamespace) only release their reference, the pool stays open
 func (c *Client) Close() {
+	if c.parent == nil {
+		c.disableLocal(false)
+	}
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	if c.Pool != nil {
-		_ = c.Pool.Close()
+		if c.parent == nil {
+			_ = c.Pool.Close()
+		}
 		c.Pool = nil
 	}
 }
@@ -48,7 +65,11 @@ func (c *Client) CloseAll(conn redis.Conn) redis.Conn {
 // The connection must be closed when you're finished
 // Deprecated: use GetConnectionWithContext()
 func (c *Client) GetConnection() redis.Conn {
-	return c.Pool.Get()
+	conn := c.Pool.Get()
+	if len(c.namespace) > 0 {
+		return newNamespaceConn(conn, c.namespace)
+	}
+	return conn
 }
 
 // GetConnectionWithContext will return a connection from the pool. (convenience method)
@@ -56,10 +77,14 @@ func (c *Client) GetConnection() redis.Conn {
 func (c *Client) GetConnectionWithContext(ctx context.Context) (redis.Conn, error) {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
-	if c.Pool != nil {
-		return c.Pool.GetContext(ctx)
+	if c.Pool == nil {
+		return nil, ErrRedisPoolNil
 	}
-	return nil, ErrRedisPoolNil
+	conn, err := c.Pool.GetContext(ctx)
+	if err != nil || len(c.namespace) == 0 {
+		return conn, err
+	}
+	return newNamespaceConn(conn, c.namespace), nil
 }
 
 // CloseConnection will close a previously open connection
@@ -129,6 +154,9 @@ func Connect(ctx context.Context, redisURL string,
 		}
 	}
 
+	// New connections reload the named scripts if they reach a server that lost them
+	redisPool.Dial = client.dialWithScripts(redisPool.Dial)
+
 	// Register scripts if enabled
 	if dependencyMode {
 		if err = client.RegisterScripts(ctx); err != nil {
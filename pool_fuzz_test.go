@@ -236,7 +236,7 @@ func FuzzConnectionManagement(f *testing.F) {
 }
 
 func FuzzScriptShaValidation(f *testing.F) {
-	f.Add("a648f768f57e73e2497ccaa113d5ad9e731c5cd8")
+	f.Add("62ffb9bb8e146d0048fe2c00b624e0fee9cffdb7")
 	f.Add("")
 	f.Add("invalid-sha")
 	f.Add("1234567890abcdef")
@@ -257,7 +257,7 @@ func FuzzScriptShaValidation(f *testing.F) {
 				assert.Contains(t, client.ScriptsLoaded, sha)
 			}
 
-			assert.Equal(t, killByDependencySha, "a648f768f57e73e2497ccaa113d5ad9e731c5cd8")
+			assert.Equal(t, killByDependencySha, "62ffb9bb8e146d0048fe2c00b624e0fee9cffdb7")
 		})
 	})
 }
@@ -18,6 +18,16 @@ type SubscriptionOption func(*subscriptionOptions)
 
 type subscriptionOptions struct {
 	messageBufferSize int
+	hooks             subscriptionHooks
+}
+
+// subscriptionHooks let internal subscribers (e.g. local cache invalidation) follow the
+// connection lifecycle of a Subscription. Every hook is optional.
+type subscriptionHooks struct {
+	connect    func(conn redis.Conn) error       // runs on every new connection before subscribing
+	disconnect func()                            // runs when the connection drops, before reconnecting
+	reconnect  func()                            // runs after the subscription is re-established
+	receive    func(conn redis.Conn) interface{} // replaces PubSubConn.Receive() in the read loop
 }
 
 func defaultSubscriptionOptions() subscriptionOptions {
@@ -34,6 +44,15 @@ func WithMessageBuffer(n int) SubscriptionOption {
 	}
 }
 
+// withHooks registers lifecycle hooks on the Subscription.
+// Messages published while disconnected are lost; the hooks let internal
+// subscribers resynchronize.
+func withHooks(hooks subscriptionHooks) SubscriptionOption {
+	return func(o *subscriptionOptions) {
+		o.hooks = hooks
+	}
+}
+
 const (
 	// pubSubMessageBufferSize is the default number of messages to buffer before blocking
 	pubSubMessageBufferSize = 100
@@ -69,6 +88,7 @@ type Subscription struct {
 	connOnce  sync.Once      // guards conn.Close(); separate from closeOnce to avoid deadlock
 	wg        sync.WaitGroup // tracks the readLoop goroutine; Wait() before conn.Close()
 	errCh     chan error     // internal; receives reconnection errors for visibility
+	hooks     subscriptionHooks
 }
 
 // Publish sends a message to the given channel.
@@ -107,10 +127,20 @@ func Subscribe(ctx context.Context, client *Client, channels []string, opts ...S
 	if err := ctx.Err(); err != nil {
 		return nil, err
 	}
+	o := defaultSubscriptionOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
 	conn, err := client.GetConnectionWithContext(ctx)
 	if err != nil {
 		return nil, err
 	}
+	if o.hooks.connect != nil {
+		if err = o.hooks.connect(conn); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+	}
 
 	psc := redis.PubSubConn{Conn: conn}
 	if err = psc.Subscribe(toInterfaces(channels)...); err != nil {
@@ -132,11 +162,8 @@ func Subscribe(ctx context.Context, client *Client, channels []string, opts ...S
 		}
 	}
 
-	o := defaultSubscriptionOptions()
-	for _, opt := range opts {
-		opt(&o)
-	}
 	sub := newSubscription(client, conn, psc, channels, nil, o.messageBufferSize)
+	sub.hooks = o.hooks
 	sub.start(ctx)
 	return sub, nil
 }
@@ -154,10 +181,20 @@ func PSubscribe(ctx context.Context, client *Client, patterns []string, opts ...
 	if err := ctx.Err(); err != nil {
 		return nil, err
 	}
+	o := defaultSubscriptionOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
 	conn, err := client.GetConnectionWithContext(ctx)
 	if err != nil {
 		return nil, err
 	}
+	if o.hooks.connect != nil {
+		if err = o.hooks.connect(conn); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+	}
 
 	psc := redis.PubSubConn{Conn: conn}
 	if err = psc.PSubscribe(toInterfaces(patterns)...); err != nil {
@@ -178,11 +215,8 @@ func PSubscribe(ctx context.Context, client *Client, patterns []string, opts ...
 		}
 	}
 
-	o := defaultSubscriptionOptions()
-	for _, opt := range opts {
-		opt(&o)
-	}
 	sub := newSubscription(client, conn, psc, nil, patterns, o.messageBufferSize)
+	sub.hooks = o.hooks
 	sub.start(ctx)
 	return sub, nil
 }
@@ -278,6 +312,9 @@ func (s *Subscription) start(ctx context.Context) {
 			}
 
 			// Connection dropped — attempt reconnect with exponential backoff.
+			if s.hooks.disconnect != nil {
+				s.hooks.disconnect()
+			}
 			select {
 			case <-s.done:
 				return
@@ -296,6 +333,9 @@ func (s *Subscription) start(ctx context.Context) {
 
 			// Reconnected — reset backoff.
 			backoff = pubSubReconnectMin
+			if s.hooks.reconnect != nil {
+				s.hooks.reconnect()
+			}
 		}
 	}()
 }
@@ -314,7 +354,7 @@ func (s *Subscription) start(ctx context.Context) {
 // root cause of the "use of closed network connection" error during Close().
 func (s *Subscription) readLoop() {
 	for {
-		switch msg := s.psc.Receive().(type) {
+		switch msg := s.receive().(type) {
 		case redis.Message:
 			// Both regular (SUBSCRIBE) and pattern (PSUBSCRIBE) messages arrive as
 			// redis.Message; Pattern is non-empty only for pattern-matched messages.
@@ -338,6 +378,14 @@ func (s *Subscription) readLoop() {
 	}
 }
 
+// receive reads the next notification from the current connection.
+func (s *Subscription) receive() interface{} {
+	if s.hooks.receive != nil {
+		return s.hooks.receive(s.conn)
+	}
+	return s.psc.Receive()
+}
+
 // isNetTimeout reports whether err is a network timeout error.
 func isNetTimeout(err error) bool {
 	if err == nil {
@@ -358,6 +406,13 @@ func (s *Subscription) reconnect(ctx context.Context) error {
 		return err
 	}
 
+	if s.hooks.connect != nil {
+		if err = s.hooks.connect(conn); err != nil {
+			_ = conn.Close()
+			return err
+		}
+	}
+
 	psc := redis.PubSubConn{Conn: conn}
 
 	if len(s.channels) > 0 {
@@ -0,0 +1,496 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// ErrLoaderPanic is the error returned to every caller sharing a loader call that panicked
+var ErrLoaderPanic = errors.New("loader panicked")
+
+const (
+	// readThroughLockPrefix is prepended to the key to build the lock name used by GetOrSet
+	readThroughLockPrefix = "lock:read-through:"
+
+	// readThroughLockPoll is the default poll interval while waiting on another process
+	readThroughLockPoll = 50 * time.Millisecond
+
+	// envelopePrefix marks values written with read-through metadata (compute time, expiry)
+	// The leading NUL byte keeps it from colliding with ordinary text values
+	envelopePrefix = "\x00gc1:"
+
+	// DefaultXFetchBeta is the recommended beta for GetOrSetXFetch(), values > 1 favor earlier refreshes
+	DefaultXFetchBeta = 1.0
+
+	// refreshBackoffMin is the default wait after a failed background refresh (see RefreshBackoff)
+	refreshBackoffMin = time.Second
+
+	// refreshBackoffMax caps the wait between failed background refreshes
+	refreshBackoffMax = time.Minute
+)
+
+// Loader computes the value for a key on a cache miss
+type Loader func(ctx context.Context) (string, error)
+
+// CacheStatus describes where a read-through value came from
+type CacheStatus int
+
+// Read-through cache statuses
+const (
+	CacheMiss  CacheStatus = iota // Key was not cached; the value (if any) came from the loader
+	CacheFresh                    // Value was cached and within its (soft) ttl
+	CacheStale                    // Value was cached but past its soft ttl; it is refreshed in the background
+)
+
+// String returns the name of the status
+func (s CacheStatus) String() string {
+	switch s {
+	case CacheMiss:
+		return "miss"
+	case CacheFresh:
+		return "fresh"
+	case CacheStale:
+		return "stale"
+	}
+	return "unknown"
+}
+
+// GetOrSetOptions configures GetOrSetWithOptions()
+type GetOrSetOptions struct {
+	LockTTL  time.Duration // When > 0 a redis lock (WriteLock) is held while loading, so only one process recomputes
+	LockWait time.Duration // How long to wait for another process holding the lock to fill the key (default: LockTTL)
+	LockPoll time.Duration // How often to check for the value while waiting (default: 50ms)
+	Beta     float64       // When > 0 (and ttl > 0) enables XFetch probabilistic early recomputation
+	SoftTTL  time.Duration // When > 0 (and < ttl) values older than SoftTTL are served stale while refreshing
+
+	// RefreshBackoff is the wait after a failed background refresh before the next one, doubled per
+	// consecutive failure up to a minute (default: 1s)
+	RefreshBackoff time.Duration
+}
+
+// GetOrSet gets the key from redis, or on a miss runs the loader and stores the result
+// with the ttl (0 = no expiration) and dependencies
+//
+// Concurrent misses for the same key within this process are collapsed into a single
+// loader call; every caller receives the same result. The shared load runs with the
+// context of the first caller.
+//
+// Uses methods: GetOrSetWithOptions()
+func GetOrSet(ctx context.Context, client *Client, key string, ttl time.Duration,
+	loader Loader, dependencies ...string,
+) (string, error) {
+	return GetOrSetWithOptions(ctx, client, key, ttl, loader, nil, dependencies...)
+}
+
+// GetOrSetWithOptions is GetOrSet() with additional options (nil options are allowed)
+//
+// With LockTTL set, the loading process also takes a short redis lock so only one process
+// across the fleet recomputes the key. Processes that lose the lock wait up to LockWait for
+// the value to appear, then fall back to running the loader themselves.
+// If the value is loaded but cannot be stored, the loaded value is returned with the error.
+//
+// With Beta set, the value is stored together with its compute time and expiry, and readers
+// refresh it before it expires with a probability that rises as expiry approaches (XFetch).
+// A failed early refresh is not an error: the still-valid cached value is returned instead.
+//
+// With SoftTTL set, see GetOrSetStale(). Keys written with Beta or SoftTTL carry metadata
+// and should only be read through the GetOrSet methods.
+//
+// Uses methods: Get(), SetExpRaw() or Set(), WriteLock(), ReleaseLock()
+func GetOrSetWithOptions(ctx context.Context, client *Client, key string, ttl time.Duration,
+	loader Loader, opts *GetOrSetOptions, dependencies ...string,
+) (string, error) {
+	value, _, err := readThrough(ctx, client, key, ttl, loader, opts, dependencies...)
+	return value, err
+}
+
+// GetOrSetStale is a read-through with stale-while-revalidate and stale-if-error semantics
+//
+// Values are stored for hardTTL and considered fresh for softTTL. Once past softTTL the stale
+// value is returned right away (CacheStale) and the loader runs in the background. If the
+// loader fails the stale value is kept and served until hardTTL, after which the key is a
+// miss (CacheMiss) and the loader runs synchronously again.
+//
+// Only one background refresh per key runs at a time in this process. After a failed refresh
+// stale reads wait out a backoff (1s, doubled per failure up to a minute) before the loader
+// runs again, so an unavailable backend is not called on every read.
+//
+// The background refresh is detached from ctx cancellation but keeps its values.
+//
+// Uses methods: Get(), SetExpRaw()
+func GetOrSetStale(ctx context.Context, client *Client, key string, softTTL, hardTTL time.Duration,
+	loader Loader, dependencies ...string,
+) (string, CacheStatus, error) {
+	return readThrough(ctx, client, key, hardTTL, loader, &GetOrSetOptions{SoftTTL: softTTL}, dependencies...)
+}
+
+// readThrough implements the GetOrSet methods and reports where the value came from
+func readThrough(ctx context.Context, client *Client, key string, ttl time.Duration,
+	loader Loader, opts *GetOrSetOptions, dependencies ...string,
+) (string, CacheStatus, error) {
+	if opts == nil {
+		opts = &GetOrSetOptions{}
+	}
+
+	value, err := Get(ctx, client, key)
+	if errors.Is(err, redis.ErrNil) {
+		value, err = client.flights.do(key, func() (string, error) {
+			return loadAndStore(ctx, client, key, ttl, loader, opts, false, dependencies...)
+		})
+		return value, CacheMiss, err
+	} else if err != nil {
+		return "", CacheMiss, err
+	}
+
+	envelope, ok := decodeEnvelope(value)
+	if !ok || !opts.useEnvelope(ttl) {
+		return envelope.value, CacheFresh, nil
+	}
+
+	// Values stored without a soft ttl (zero softExpiry, e.g. written with Beta only) never turn stale
+	now := time.Now()
+	if opts.useSoftTTL(ttl) && !envelope.softExpiry.IsZero() && !now.Before(envelope.softExpiry) {
+		// Stale-while-revalidate: refresh in the background, errors keep the stale value and
+		// back off before the next refresh
+		if client.refreshes.start(key, now) {
+			refreshCtx := context.WithoutCancel(ctx)
+			go func() {
+				_, refreshErr := client.flights.do(key, func() (string, error) {
+					return loadAndStore(refreshCtx, client, key, ttl, loader, opts, true, dependencies...)
+				})
+				client.refreshes.finish(key, refreshErr, time.Now(), opts.RefreshBackoff)
+			}()
+		}
+		return envelope.value, CacheStale, nil
+	}
+
+	if opts.Beta > 0 && envelope.refreshEarly(opts.Beta, now) {
+		// Early refresh: whoever loses the race (or fails) keeps serving the current value
+		refreshed, refreshErr := client.flights.do(key, func() (string, error) {
+			return loadAndStore(ctx, client, key, ttl, loader, opts, true, dependencies...)
+		})
+		if refreshErr == nil {
+			return refreshed, CacheFresh, nil
+		}
+	}
+	return envelope.value, CacheFresh, nil
+}
+
+// GetOrSetXFetch is GetOrSet() with XFetch probabilistic early recomputation
+// beta scales how early refreshes happen (DefaultXFetchBeta is a good start), ttl must be > 0
+//
+// Uses methods: GetOrSetWithOptions()
+func GetOrSetXFetch(ctx context.Context, client *Client, key string, ttl time.Duration, beta float64,
+	loader Loader, dependencies ...string,
+) (string, error) {
+	return GetOrSetWithOptions(ctx, client, key, ttl, loader, &GetOrSetOptions{Beta: beta}, dependencies...)
+}
+
+// loadAndStore runs the loader (optionally under a redis lock) and stores the result
+// When early is set the key still holds a valid value, so a lock held elsewhere is not waited on
+func loadAndStore(ctx context.Context, client *Client, key string, ttl time.Duration,
+	loader Loader, opts *GetOrSetOptions, early bool, dependencies ...string,
+) (string, error) {
+	if opts.LockTTL > 0 {
+		value, release, err := acquireReadThroughLock(ctx, client, key, opts, early)
+		if release != nil {
+			defer release()
+		}
+		if err == nil {
+			return value, nil
+		} else if !errors.Is(err, redis.ErrNil) {
+			return "", err
+		}
+	}
+
+	started := time.Now()
+	value, err := loader(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	if opts.useEnvelope(ttl) {
+		var conn redis.Conn
+		if conn, err = client.GetConnectionWithContext(ctx); err != nil {
+			return value, err
+		}
+		defer client.CloseConnection(conn)
+
+		now := time.Now()
+		stored := cacheEnvelope{value: value, delta: now.Sub(started), expiry: now.Add(ttl)}
+		if opts.useSoftTTL(ttl) {
+			stored.softExpiry = now.Add(opts.SoftTTL)
+		}
+		if err = SetExpRaw(conn, key, stored.encode(), ttl, dependencies...); err != nil {
+			return value, err
+		}
+		return value, client.invalidateLocal(ctx, key)
+	} else if ttl > 0 {
+		err = SetExp(ctx, client, key, value, ttl, dependencies...)
+	} else {
+		err = Set(ctx, client, key, value, dependencies...)
+	}
+	return value, err
+}
+
+// acquireReadThroughLock takes the read-through lock for the key
+//
+// Returns the cached value if another process filled the key in the meantime, or
+// redis.ErrNil when the caller should run the loader. release is non-nil when the
+// lock was acquired and must be called once loading is done.
+func acquireReadThroughLock(ctx context.Context, client *Client, key string,
+	opts *GetOrSetOptions, early bool,
+) (value string, release func(), err error) {
+	var secret string
+	if secret, err = newLockSecret(); err != nil {
+		return "", nil, err
+	}
+	lockName := readThroughLockPrefix + key
+
+	// Lock TTLs are in whole seconds; round up so the lock covers at least LockTTL
+	lockTTL := int64(math.Ceil(opts.LockTTL.Seconds()))
+	if _, err = WriteLock(ctx, client, lockName, secret, lockTTL); err == nil {
+		release = func() {
+			_, _ = ReleaseLock(context.WithoutCancel(ctx), client, lockName, secret)
+		}
+
+		// Another process may have filled the key between our miss and the lock
+		if !early {
+			if value, err = getReadThroughValue(ctx, client, key); err == nil || !errors.Is(err, redis.ErrNil) {
+				return value, release, err
+			}
+		}
+		return "", release, redis.ErrNil
+	} else if !errors.Is(err, ErrLockMismatch) || early {
+		return "", nil, err
+	}
+
+	// Someone else is loading: wait for the value to show up
+	value, err = waitForValue(ctx, client, key, opts)
+	return value, nil, err
+}
+
+// waitForValue polls for the key until it exists or the lock wait elapses
+// Returns redis.ErrNil if the key never showed up
+func waitForValue(ctx context.Context, client *Client, key string, opts *GetOrSetOptions) (string, error) {
+	wait := opts.LockWait
+	if wait <= 0 {
+		wait = opts.LockTTL
+	}
+	poll := opts.LockPoll
+	if poll <= 0 {
+		poll = readThroughLockPoll
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	ticker := time.NewTicker(poll)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-timer.C:
+			return "", redis.ErrNil
+		case <-ticker.C:
+			value, err := getReadThroughValue(ctx, client, key)
+			if !errors.Is(err, redis.ErrNil) {
+				return value, err
+			}
+		}
+	}
+}
+
+// getReadThroughValue gets the key, stripping the read-through metadata (if any) from the value
+func getReadThroughValue(ctx context.Context, client *Client, key string) (string, error) {
+	value, err := Get(ctx, client, key)
+	if err != nil {
+		return "", err
+	}
+	envelope, _ := decodeEnvelope(value)
+	return envelope.value, nil
+}
+
+// useEnvelope reports whether values must carry read-through metadata
+func (o *GetOrSetOptions) useEnvelope(ttl time.Duration) bool {
+	return ttl > 0 && (o.Beta > 0 || o.useSoftTTL(ttl))
+}
+
+// useSoftTTL reports whether stale-while-revalidate applies
+func (o *GetOrSetOptions) useSoftTTL(ttl time.Duration) bool {
+	return o.SoftTTL > 0 && o.SoftTTL < ttl
+}
+
+// cacheEnvelope is a read-through value with the metadata needed to refresh it early or stale
+type cacheEnvelope struct {
+	value      string
+	delta      time.Duration // how long the loader took to compute the value
+	expiry     time.Time     // when the key expires in redis (hard ttl)
+	softExpiry time.Time     // when the value turns stale (zero when unused)
+}
+
+// encode formats the envelope as: prefix <delta ms>:<expiry unix ms>:<soft expiry unix ms>:<value>
+func (e cacheEnvelope) encode() string {
+	var softMs int64
+	if !e.softExpiry.IsZero() {
+		softMs = e.softExpiry.UnixMilli()
+	}
+	return envelopePrefix +
+		strconv.FormatInt(e.delta.Milliseconds(), 10) + ":" +
+		strconv.FormatInt(e.expiry.UnixMilli(), 10) + ":" +
+		strconv.FormatInt(softMs, 10) + ":" +
+		e.value
+}
+
+// refreshEarly implements the XFetch test: now - delta * beta * ln(rand()) >= expiry
+func (e cacheEnvelope) refreshEarly(beta float64, now time.Time) bool {
+	r := 1 - rand.Float64() //nolint:gosec // randomness is for load spreading, not security
+	gap := time.Duration(-float64(e.delta) * beta * math.Log(r))
+	return !now.Add(gap).Before(e.expiry)
+}
+
+// decodeEnvelope parses a value written by cacheEnvelope.encode()
+// ok is false for plain values, which are returned untouched in envelope.value
+func decodeEnvelope(raw string) (envelope cacheEnvelope, ok bool) {
+	envelope.value = raw
+	rest, found := strings.CutPrefix(raw, envelopePrefix)
+	if !found {
+		return envelope, false
+	}
+	parts := strings.SplitN(rest, ":", 4)
+	if len(parts) != 4 {
+		return envelope, false
+	}
+	var fields [3]int64
+	for i := range fields {
+		var err error
+		if fields[i], err = strconv.ParseInt(parts[i], 10, 64); err != nil {
+			return envelope, false
+		}
+	}
+	envelope = cacheEnvelope{
+		value:  parts[3],
+		delta:  time.Duration(fields[0]) * time.Millisecond,
+		expiry: time.UnixMilli(fields[1]),
+	}
+	if fields[2] > 0 {
+		envelope.softExpiry = time.UnixMilli(fields[2])
+	}
+	return envelope, true
+}
+
+// flightGroup collapses concurrent calls for the same key into a single execution
+// The zero value is ready to use
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+// flightCall is an in-flight or completed flightGroup call
+type flightCall struct {
+	wg    sync.WaitGroup
+	value string
+	err   error
+}
+
+// do runs fn once per key at a time; callers arriving while fn runs wait for its result
+// A panic in fn is recovered and returned to every caller as ErrLoaderPanic
+func (g *flightGroup) do(key string, fn func() (string, error)) (value string, err error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*flightCall)
+	}
+	if call, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		call.wg.Wait()
+		return call.value, call.err
+	}
+	call := new(flightCall)
+	call.wg.Add(1)
+	g.calls[key] = call
+	g.mu.Unlock()
+
+	defer func() {
+		if r := recover(); r != nil {
+			call.value, call.err = "", fmt.Errorf("%w: %v", ErrLoaderPanic, r)
+		}
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		call.wg.Done()
+		value, err = call.value, call.err
+	}()
+	call.value, call.err = fn()
+	return call.value, call.err
+}
+
+// refreshGate limits stale background refreshes to one per key at a time, and backs off
+// after failed refreshes so an unavailable backend is not called on every stale read
+// The zero value is ready to use
+type refreshGate struct {
+	mu      sync.Mutex
+	entries map[string]*refreshState
+}
+
+// refreshState tracks the background refreshes of a key
+type refreshState struct {
+	running  bool
+	failures int       // consecutive failed refreshes
+	retryAt  time.Time // no refresh starts before this time
+}
+
+// start reports whether a background refresh of the key may start now, and marks it running
+func (g *refreshGate) start(key string, now time.Time) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.entries == nil {
+		g.entries = make(map[string]*refreshState)
+	}
+	state, ok := g.entries[key]
+	if !ok {
+		state = new(refreshState)
+		g.entries[key] = state
+	}
+	if state.running || now.Before(state.retryAt) {
+		return false
+	}
+	state.running = true
+	return true
+}
+
+// finish records the result of a refresh: a success forgets the key, a failure backs off
+// (backoff, default refreshBackoffMin, doubled per consecutive failure up to refreshBackoffMax)
+func (g *refreshGate) finish(key string, err error, now time.Time, backoff time.Duration) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	state, ok := g.entries[key]
+	if !ok {
+		return
+	}
+	if err == nil {
+		delete(g.entries, key)
+		return
+	}
+
+	if backoff <= 0 {
+		backoff = refreshBackoffMin
+	}
+	limit := max(refreshBackoffMax, backoff)
+	state.running = false
+	state.failures++
+	wait := backoff
+	for i := 1; i < state.failures && wait < limit; i++ {
+		wait *= 2
+	}
+	state.retryAt = now.Add(min(wait, limit))
+}
@@ -0,0 +1,742 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errTestLoader = errors.New("loader failed")
+
+// TestGetOrSet tests the method GetOrSet()
+func TestGetOrSet(t *testing.T) {
+	t.Run("cache hit does not call loader", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.Command(GetCommand, testKey).Expect(testStringValue)
+
+		var calls int
+		val, err := GetOrSet(context.Background(), client, testKey, time.Minute, func(context.Context) (string, error) {
+			calls++
+			return "loaded", nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, testStringValue, val)
+		assert.Equal(t, 0, calls)
+	})
+
+	t.Run("cache miss loads and stores with dependencies", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.Command(GetCommand, testKey).Expect(nil)
+		setCmd := conn.Command(SetExpirationCommand, testKey, int64(60), "loaded")
+		addCmd := conn.Command(AddToSetCommand, DependencyPrefix+testDependantKey, testKey)
+		mockReverseDependencies(conn, testKey, testDependantKey)
+		reverseExpireCmd := mockReverseExpire(conn, testKey, time.Minute)
+		conn.Command(MultiCommand)
+		conn.Command(ExecuteCommand)
+
+		val, err := GetOrSet(context.Background(), client, testKey, time.Minute, func(context.Context) (string, error) {
+			return "loaded", nil
+		}, testDependantKey)
+		require.NoError(t, err)
+		assert.Equal(t, "loaded", val)
+		assert.True(t, setCmd.Called)
+		assert.True(t, addCmd.Called)
+		assert.True(t, reverseExpireCmd.Called)
+	})
+
+	t.Run("cache miss without ttl", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.Command(GetCommand, testKey).Expect(nil)
+		setCmd := conn.Command(SetCommand, testKey, "loaded")
+
+		val, err := GetOrSet(context.Background(), client, testKey, 0, func(context.Context) (string, error) {
+			return "loaded", nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "loaded", val)
+		assert.True(t, setCmd.Called)
+	})
+
+	t.Run("loader error", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.Command(GetCommand, testKey).Expect(nil)
+
+		val, err := GetOrSet(context.Background(), client, testKey, time.Minute, func(context.Context) (string, error) {
+			return "", errTestLoader
+		})
+		require.ErrorIs(t, err, errTestLoader)
+		assert.Empty(t, val)
+	})
+
+	t.Run("get error is returned", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.Command(GetCommand, testKey).ExpectError(errTestLoader)
+
+		_, err := GetOrSet(context.Background(), client, testKey, time.Minute, func(context.Context) (string, error) {
+			return "loaded", nil
+		})
+		require.ErrorIs(t, err, errTestLoader)
+	})
+
+	t.Run("closed client", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		client.CloseAll(conn)
+
+		_, err := GetOrSet(context.Background(), client, testKey, time.Minute, func(context.Context) (string, error) {
+			return "loaded", nil
+		})
+		require.ErrorIs(t, err, ErrRedisPoolNil)
+	})
+}
+
+// TestGetOrSetWithOptions tests the method GetOrSetWithOptions()
+func TestGetOrSetWithOptions(t *testing.T) {
+	t.Run("lock acquired, loader runs", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.Command(GetCommand, testKey).Expect(nil)
+		lockCmd := conn.GenericCommand(EvalCommand).Expect(int64(1))
+		setCmd := conn.Command(SetExpirationCommand, testKey, int64(60), "loaded")
+
+		val, err := GetOrSetWithOptions(context.Background(), client, testKey, time.Minute,
+			func(context.Context) (string, error) {
+				return "loaded", nil
+			}, &GetOrSetOptions{LockTTL: time.Second},
+		)
+		require.NoError(t, err)
+		assert.Equal(t, "loaded", val)
+		assert.True(t, setCmd.Called)
+		assert.Equal(t, 2, conn.Stats(lockCmd)) // lock + release
+	})
+
+	t.Run("lock held elsewhere, value appears", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.Command(GetCommand, testKey).Expect(nil).Expect(testStringValue)
+		conn.GenericCommand(EvalCommand).Expect(int64(0))
+
+		var calls int
+		val, err := GetOrSetWithOptions(context.Background(), client, testKey, time.Minute,
+			func(context.Context) (string, error) {
+				calls++
+				return "loaded", nil
+			}, &GetOrSetOptions{LockTTL: time.Second, LockPoll: time.Millisecond},
+		)
+		require.NoError(t, err)
+		assert.Equal(t, testStringValue, val)
+		assert.Equal(t, 0, calls)
+	})
+
+	t.Run("lock held elsewhere, wait times out and loader runs", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.Command(GetCommand, testKey).Expect(nil)
+		conn.GenericCommand(EvalCommand).Expect(int64(0))
+		setCmd := conn.Command(SetExpirationCommand, testKey, int64(60), "loaded")
+
+		val, err := GetOrSetWithOptions(context.Background(), client, testKey, time.Minute,
+			func(context.Context) (string, error) {
+				return "loaded", nil
+			}, &GetOrSetOptions{LockTTL: time.Second, LockWait: 10 * time.Millisecond, LockPoll: time.Millisecond},
+		)
+		require.NoError(t, err)
+		assert.Equal(t, "loaded", val)
+		assert.True(t, setCmd.Called)
+	})
+
+	t.Run("lock acquired after another process filled the key with metadata", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		stored := cacheEnvelope{value: testStringValue, delta: time.Second, expiry: time.Now().Add(time.Minute)}
+		conn.Command(GetCommand, testKey).Expect(nil).Expect(stored.encode())
+		conn.GenericCommand(EvalCommand).Expect(int64(1))
+
+		var calls int
+		val, err := GetOrSetWithOptions(context.Background(), client, testKey, time.Minute,
+			func(context.Context) (string, error) {
+				calls++
+				return "loaded", nil
+			}, &GetOrSetOptions{LockTTL: time.Second, Beta: DefaultXFetchBeta},
+		)
+		require.NoError(t, err)
+		assert.Equal(t, testStringValue, val)
+		assert.Equal(t, 0, calls)
+	})
+
+	t.Run("lock error is returned", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.Command(GetCommand, testKey).Expect(nil)
+		conn.GenericCommand(EvalCommand).ExpectError(errTestLoader)
+
+		_, err := GetOrSetWithOptions(context.Background(), client, testKey, time.Minute,
+			func(context.Context) (string, error) {
+				return "loaded", nil
+			}, &GetOrSetOptions{LockTTL: time.Second},
+		)
+		require.ErrorIs(t, err, errTestLoader)
+	})
+
+	t.Run("get or set - real redis", func(t *testing.T) {
+		if testing.Short() {
+			t.Skip("skipping live local redis tests")
+		}
+
+		client, conn, err := loadRealRedis(t)
+		require.NoError(t, err)
+		defer client.CloseAll(conn)
+
+		err = clearRealRedis(conn, t)
+		require.NoError(t, err)
+
+		var calls int32
+		loader := func(context.Context) (string, error) {
+			atomic.AddInt32(&calls, 1)
+			return testStringValue, nil
+		}
+
+		var val string
+		for i := 0; i < 3; i++ {
+			val, err = GetOrSetWithOptions(context.Background(), client, testKey, time.Minute, loader,
+				&GetOrSetOptions{LockTTL: time.Second}, testDependantKey)
+			require.NoError(t, err)
+			assert.Equal(t, testStringValue, val)
+		}
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	})
+}
+
+// TestGetOrSetXFetch tests the method GetOrSetXFetch()
+func TestGetOrSetXFetch(t *testing.T) {
+	t.Run("cache miss stores value with metadata", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		var stored string
+		conn.Command(GetCommand, testKey).Expect(nil)
+		setCmd := conn.GenericCommand(SetExpirationCommand).Handle(func(args []interface{}) (interface{}, error) {
+			stored, _ = args[2].(string)
+			return "OK", nil
+		})
+		addCmd := conn.Command(AddToSetCommand, DependencyPrefix+testDependantKey, testKey)
+		mockReverseDependencies(conn, testKey, testDependantKey)
+		conn.GenericCommand(ExpireCommand)
+		conn.Command(MultiCommand)
+		conn.Command(ExecuteCommand)
+
+		val, err := GetOrSetXFetch(context.Background(), client, testKey, time.Minute, DefaultXFetchBeta,
+			func(context.Context) (string, error) {
+				return testStringValue, nil
+			}, testDependantKey)
+		require.NoError(t, err)
+		assert.Equal(t, testStringValue, val)
+		assert.True(t, setCmd.Called)
+		assert.True(t, addCmd.Called)
+
+		envelope, ok := decodeEnvelope(stored)
+		require.True(t, ok)
+		assert.Equal(t, testStringValue, envelope.value)
+		assert.WithinDuration(t, time.Now().Add(time.Minute), envelope.expiry, 5*time.Second)
+	})
+
+	t.Run("fresh value is served without refresh", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		stored := cacheEnvelope{value: testStringValue, expiry: time.Now().Add(time.Hour)}
+		conn.Command(GetCommand, testKey).Expect(stored.encode())
+
+		var calls int
+		val, err := GetOrSetXFetch(context.Background(), client, testKey, time.Minute, DefaultXFetchBeta,
+			func(context.Context) (string, error) {
+				calls++
+				return "refreshed", nil
+			})
+		require.NoError(t, err)
+		assert.Equal(t, testStringValue, val)
+		assert.Equal(t, 0, calls)
+	})
+
+	t.Run("value at expiry is refreshed early", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		stored := cacheEnvelope{value: testStringValue, delta: time.Second, expiry: time.Now().Add(-time.Millisecond)}
+		conn.Command(GetCommand, testKey).Expect(stored.encode())
+		setCmd := conn.GenericCommand(SetExpirationCommand).Expect("OK")
+
+		val, err := GetOrSetXFetch(context.Background(), client, testKey, time.Minute, DefaultXFetchBeta,
+			func(context.Context) (string, error) {
+				return "refreshed", nil
+			})
+		require.NoError(t, err)
+		assert.Equal(t, "refreshed", val)
+		assert.True(t, setCmd.Called)
+	})
+
+	t.Run("failed early refresh serves the current value", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		stored := cacheEnvelope{value: testStringValue, delta: time.Second, expiry: time.Now().Add(-time.Millisecond)}
+		conn.Command(GetCommand, testKey).Expect(stored.encode())
+
+		val, err := GetOrSetXFetch(context.Background(), client, testKey, time.Minute, DefaultXFetchBeta,
+			func(context.Context) (string, error) {
+				return "", errTestLoader
+			})
+		require.NoError(t, err)
+		assert.Equal(t, testStringValue, val)
+	})
+
+	t.Run("early refresh does not wait on a lock held elsewhere", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		stored := cacheEnvelope{value: testStringValue, delta: time.Second, expiry: time.Now().Add(-time.Millisecond)}
+		conn.Command(GetCommand, testKey).Expect(stored.encode())
+		conn.GenericCommand(EvalCommand).Expect(int64(0))
+
+		var calls int
+		val, err := GetOrSetWithOptions(context.Background(), client, testKey, time.Minute,
+			func(context.Context) (string, error) {
+				calls++
+				return "refreshed", nil
+			}, &GetOrSetOptions{Beta: DefaultXFetchBeta, LockTTL: time.Second, LockWait: time.Hour})
+		require.NoError(t, err)
+		assert.Equal(t, testStringValue, val)
+		assert.Equal(t, 0, calls)
+	})
+}
+
+// TestGetOrSetStale tests the method GetOrSetStale()
+func TestGetOrSetStale(t *testing.T) {
+	t.Run("cache miss loads synchronously", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		var stored string
+		conn.Command(GetCommand, testKey).Expect(nil)
+		conn.GenericCommand(SetExpirationCommand).Handle(func(args []interface{}) (interface{}, error) {
+			stored, _ = args[2].(string)
+			return "OK", nil
+		})
+
+		val, status, err := GetOrSetStale(context.Background(), client, testKey, time.Minute, time.Hour,
+			func(context.Context) (string, error) {
+				return testStringValue, nil
+			})
+		require.NoError(t, err)
+		assert.Equal(t, testStringValue, val)
+		assert.Equal(t, CacheMiss, status)
+
+		envelope, ok := decodeEnvelope(stored)
+		require.True(t, ok)
+		assert.WithinDuration(t, time.Now().Add(time.Minute), envelope.softExpiry, 5*time.Second)
+		assert.WithinDuration(t, time.Now().Add(time.Hour), envelope.expiry, 5*time.Second)
+	})
+
+	t.Run("cache miss filled by another process before the lock", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		now := time.Now()
+		stored := cacheEnvelope{value: testStringValue, expiry: now.Add(time.Hour), softExpiry: now.Add(time.Minute)}
+		conn.Command(GetCommand, testKey).Expect(nil).Expect(stored.encode())
+		conn.GenericCommand(EvalCommand).Expect(int64(1))
+
+		var calls int
+		val, err := GetOrSetWithOptions(context.Background(), client, testKey, time.Hour,
+			func(context.Context) (string, error) {
+				calls++
+				return "loaded", nil
+			}, &GetOrSetOptions{SoftTTL: time.Minute, LockTTL: time.Second},
+		)
+		require.NoError(t, err)
+		assert.Equal(t, testStringValue, val)
+		assert.Equal(t, 0, calls)
+	})
+
+	t.Run("cache miss with loader error", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.Command(GetCommand, testKey).Expect(nil)
+
+		val, status, err := GetOrSetStale(context.Background(), client, testKey, time.Minute, time.Hour,
+			func(context.Context) (string, error) {
+				return "", errTestLoader
+			})
+		require.ErrorIs(t, err, errTestLoader)
+		assert.Empty(t, val)
+		assert.Equal(t, CacheMiss, status)
+	})
+
+	t.Run("fresh value", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		stored := cacheEnvelope{
+			value:      testStringValue,
+			expiry:     time.Now().Add(time.Hour),
+			softExpiry: time.Now().Add(time.Minute),
+		}
+		conn.Command(GetCommand, testKey).Expect(stored.encode())
+
+		val, status, err := GetOrSetStale(context.Background(), client, testKey, time.Minute, time.Hour,
+			func(context.Context) (string, error) {
+				return "refreshed", nil
+			})
+		require.NoError(t, err)
+		assert.Equal(t, testStringValue, val)
+		assert.Equal(t, CacheFresh, status)
+	})
+
+	t.Run("stale value is served and refreshed in the background", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		stored := cacheEnvelope{
+			value:      testStringValue,
+			expiry:     time.Now().Add(time.Hour),
+			softExpiry: time.Now().Add(-time.Second),
+		}
+		refreshed := make(chan struct{})
+		conn.Command(GetCommand, testKey).Expect(stored.encode())
+		conn.GenericCommand(SetExpirationCommand).Handle(func([]interface{}) (interface{}, error) {
+			close(refreshed)
+			return "OK", nil
+		})
+
+		val, status, err := GetOrSetStale(context.Background(), client, testKey, time.Minute, time.Hour,
+			func(context.Context) (string, error) {
+				return "refreshed", nil
+			})
+		require.NoError(t, err)
+		assert.Equal(t, testStringValue, val)
+		assert.Equal(t, CacheStale, status)
+
+		select {
+		case <-refreshed:
+		case <-time.After(time.Second):
+			t.Fatal("background refresh did not store the value")
+		}
+	})
+
+	t.Run("stale value is kept when the refresh fails", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		stored := cacheEnvelope{
+			value:      testStringValue,
+			expiry:     time.Now().Add(time.Hour),
+			softExpiry: time.Now().Add(-time.Second),
+		}
+		conn.Command(GetCommand, testKey).Expect(stored.encode())
+
+		loaded := make(chan struct{})
+		val, status, err := GetOrSetStale(context.Background(), client, testKey, time.Minute, time.Hour,
+			func(context.Context) (string, error) {
+				close(loaded)
+				return "", errTestLoader
+			})
+		require.NoError(t, err)
+		assert.Equal(t, testStringValue, val)
+		assert.Equal(t, CacheStale, status)
+
+		select {
+		case <-loaded:
+		case <-time.After(time.Second):
+			t.Fatal("background refresh did not run")
+		}
+	})
+
+	t.Run("value stored without a soft ttl is never stale", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		// Written by GetOrSetXFetch (Beta only): no soft expiry
+		stored := cacheEnvelope{value: testStringValue, expiry: time.Now().Add(time.Hour)}
+		conn.Command(GetCommand, testKey).Expect(stored.encode())
+
+		calls := 0
+		val, status, err := GetOrSetStale(context.Background(), client, testKey, time.Minute, time.Hour,
+			func(context.Context) (string, error) {
+				calls++
+				return "refreshed", nil
+			})
+		require.NoError(t, err)
+		assert.Equal(t, testStringValue, val)
+		assert.Equal(t, CacheFresh, status)
+		assert.Equal(t, 0, calls)
+	})
+
+	t.Run("failing refresh backs off while stale reads hammer the key", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		stored := cacheEnvelope{
+			value:      testStringValue,
+			expiry:     time.Now().Add(time.Hour),
+			softExpiry: time.Now().Add(-time.Second),
+		}
+		conn.Command(GetCommand, testKey).Expect(stored.encode())
+
+		var calls atomic.Int32
+		loader := func(context.Context) (string, error) {
+			calls.Add(1)
+			return "", errTestLoader
+		}
+		for range 50 {
+			val, status, err := GetOrSetStale(context.Background(), client, testKey, time.Minute, time.Hour, loader)
+			require.NoError(t, err)
+			assert.Equal(t, testStringValue, val)
+			assert.Equal(t, CacheStale, status)
+			time.Sleep(time.Millisecond)
+		}
+		assert.Equal(t, int32(1), calls.Load(), "the loader is not retried during the backoff")
+	})
+
+	t.Run("cache status names", func(t *testing.T) {
+		assert.Equal(t, "miss", CacheMiss.String())
+		assert.Equal(t, "fresh", CacheFresh.String())
+		assert.Equal(t, "stale", CacheStale.String())
+		assert.Equal(t, "unknown", CacheStatus(99).String())
+	})
+}
+
+// TestRefreshGate tests the background refresh limits of GetOrSetStale()
+func TestRefreshGate(t *testing.T) {
+	now := time.Now()
+	var g refreshGate
+
+	require.True(t, g.start(testKey, now))
+	assert.False(t, g.start(testKey, now), "one refresh at a time")
+	assert.True(t, g.start("other", now))
+
+	g.finish(testKey, errTestLoader, now, 0)
+	assert.False(t, g.start(testKey, now.Add(refreshBackoffMin-time.Millisecond)))
+	require.True(t, g.start(testKey, now.Add(refreshBackoffMin)))
+
+	// Consecutive failures double the backoff
+	g.finish(testKey, errTestLoader, now, 0)
+	assert.False(t, g.start(testKey, now.Add(2*refreshBackoffMin-time.Millisecond)))
+	require.True(t, g.start(testKey, now.Add(2*refreshBackoffMin)))
+
+	// Up to the cap
+	for range 20 {
+		g.finish(testKey, errTestLoader, now, 0)
+		g.entries[testKey].running = true
+	}
+	assert.Equal(t, now.Add(refreshBackoffMax), g.entries[testKey].retryAt)
+
+	// A success forgets the failures
+	g.finish(testKey, nil, now, 0)
+	assert.True(t, g.start(testKey, now))
+}
+
+// TestCacheEnvelope tests encoding, decoding and the XFetch decision
+func TestCacheEnvelope(t *testing.T) {
+	t.Run("round trip", func(t *testing.T) {
+		envelope := cacheEnvelope{
+			value:  "value:with:colons",
+			delta:  1500 * time.Millisecond,
+			expiry: time.UnixMilli(1700000000000),
+		}
+		decoded, ok := decodeEnvelope(envelope.encode())
+		require.True(t, ok)
+		assert.Equal(t, envelope.value, decoded.value)
+		assert.Equal(t, envelope.delta, decoded.delta)
+		assert.True(t, envelope.expiry.Equal(decoded.expiry))
+		assert.True(t, decoded.softExpiry.IsZero())
+	})
+
+	t.Run("round trip with soft expiry", func(t *testing.T) {
+		envelope := cacheEnvelope{
+			value:      testStringValue,
+			expiry:     time.UnixMilli(1700000060000),
+			softExpiry: time.UnixMilli(1700000010000),
+		}
+		decoded, ok := decodeEnvelope(envelope.encode())
+		require.True(t, ok)
+		assert.True(t, envelope.softExpiry.Equal(decoded.softExpiry))
+	})
+
+	t.Run("plain and malformed values", func(t *testing.T) {
+		tests := []struct {
+			name string
+			raw  string
+		}{
+			{"plain value", testStringValue},
+			{"empty value", ""},
+			{"missing fields", envelopePrefix + "10:10:value"},
+			{"bad delta", envelopePrefix + "x:10:0:value"},
+			{"bad expiry", envelopePrefix + "10:x:0:value"},
+			{"bad soft expiry", envelopePrefix + "10:10:x:value"},
+		}
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				decoded, ok := decodeEnvelope(test.raw)
+				assert.False(t, ok)
+				assert.Equal(t, test.raw, decoded.value)
+			})
+		}
+	})
+
+	t.Run("refresh early decision", func(t *testing.T) {
+		now := time.Now()
+
+		expired := cacheEnvelope{delta: time.Second, expiry: now.Add(-time.Second)}
+		assert.True(t, expired.refreshEarly(DefaultXFetchBeta, now))
+
+		instant := cacheEnvelope{delta: 0, expiry: now.Add(time.Second)}
+		assert.False(t, instant.refreshEarly(DefaultXFetchBeta, now))
+
+		distant := cacheEnvelope{delta: time.Millisecond, expiry: now.Add(24 * time.Hour)}
+		assert.False(t, distant.refreshEarly(DefaultXFetchBeta, now))
+	})
+}
+
+// TestFlightGroup tests the in-process call collapsing
+func TestFlightGroup(t *testing.T) {
+	t.Run("concurrent calls share one execution", func(t *testing.T) {
+		var group flightGroup
+		var calls int32
+		release := make(chan struct{})
+		started := make(chan struct{})
+
+		const callers = 10
+		var wg sync.WaitGroup
+		results := make([]string, callers)
+
+		// The first caller blocks inside fn until released
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[0], _ = group.do(testKey, func() (string, error) {
+				atomic.AddInt32(&calls, 1)
+				close(started)
+				<-release
+				return testStringValue, nil
+			})
+		}()
+		<-started
+
+		for i := 1; i < callers; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				results[i], _ = group.do(testKey, func() (string, error) {
+					atomic.AddInt32(&calls, 1)
+					return "unexpected", nil
+				})
+			}(i)
+		}
+
+		// Give the waiters a moment to join the in-flight call
+		time.Sleep(20 * time.Millisecond)
+		close(release)
+		wg.Wait()
+
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+		for _, result := range results {
+			assert.Equal(t, testStringValue, result)
+		}
+	})
+
+	t.Run("panic is returned to every caller", func(t *testing.T) {
+		var group flightGroup
+		release := make(chan struct{})
+		started := make(chan struct{})
+
+		var wg sync.WaitGroup
+		errs := make([]error, 2)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[0] = group.do(testKey, func() (string, error) {
+				close(started)
+				<-release
+				panic("loader blew up")
+			})
+		}()
+		<-started
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[1] = group.do(testKey, func() (string, error) {
+				return "unexpected", nil
+			})
+		}()
+
+		// Give the waiter a moment to join the in-flight call
+		time.Sleep(20 * time.Millisecond)
+		close(release)
+		wg.Wait()
+
+		for _, err := range errs {
+			require.ErrorIs(t, err, ErrLoaderPanic)
+			assert.Contains(t, err.Error(), "loader blew up")
+		}
+
+		value, err := group.do(testKey, func() (string, error) { return testStringValue, nil })
+		require.NoError(t, err)
+		assert.Equal(t, testStringValue, value)
+	})
+
+	t.Run("sequential calls run again", func(t *testing.T) {
+		var group flightGroup
+		var calls int
+		for i := 0; i < 3; i++ {
+			_, err := group.do(testKey, func() (string, error) {
+				calls++
+				return "", errTestLoader
+			})
+			require.ErrorIs(t, err, errTestLoader)
+		}
+		assert.Equal(t, 3, calls)
+	})
+}
+
+// ExampleGetOrSet is an example of the method GetOrSet()
+func ExampleGetOrSet() {
+	// Load a mocked redis for testing/examples
+	client, conn := loadMockRedis()
+
+	// Close connections at end of request
+	defer client.CloseAll(conn)
+
+	// Mock a cache miss
+	conn.Command(GetCommand, testKey).Expect(nil)
+	conn.Command(SetExpirationCommand, testKey, int64(60), testStringValue)
+
+	// Load the value on a miss
+	val, _ := GetOrSet(context.Background(), client, testKey, time.Minute, func(context.Context) (string, error) {
+		return testStringValue, nil
+	})
+	fmt.Printf("got value: %s", val)
+	// Output:got value: test-string-value
+}
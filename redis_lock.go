@@ -2,6 +2,8 @@ package cache
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 
 	"github.com/gomodule/redigo/redis"
@@ -10,12 +12,21 @@ import (
 // ErrLockMismatch is the error if the key is locked by someone else
 var ErrLockMismatch = errors.New("key is locked with a different secret")
 
+// LockReleaseChannel is the pub/sub channel a released lock's key is published on (wakes AcquireLock waiters)
+const LockReleaseChannel = "go-cache:lock-released"
+
 // lockScript is the locking script
+// KEYS[2] is the fencing token counter: a new holder increments it if the lock was ever fenced
+// (see WriteFencedLock), so holders of older tokens are fenced off by plain locks too
 const lockScript = `
 local v = redis.call("GET", KEYS[1])
 if v == false
 then
-	return redis.call("SET", KEYS[1], ARGV[1], "NX", "EX", ARGV[2]) and 1
+	redis.call("SET", KEYS[1], ARGV[1], "NX", "EX", ARGV[2])
+	if KEYS[2] and redis.call("EXISTS", KEYS[2]) == 1 then
+		redis.call("INCR", KEYS[2])
+	end
+	return 1
 else
 	if v == ARGV[1]
 	then
@@ -26,19 +37,32 @@ else
 end
 `
 
+// lockShipped runs lockScript (or the library function when loaded)
+var lockShipped = shippedScript{function: lockFunction, script: redis.NewScript(-1, lockScript)}
+
 // releaseLockScript is the release lock script (removes lock)
+// The key is published on the channel in ARGV[2] (if given) so waiters can retry right away
 const releaseLockScript = `
 local v = redis.call("GET",KEYS[1])
 if v == false then
 	return 1
 elseif v == ARGV[1] then
-	return redis.call("DEL",KEYS[1])
+	redis.call("DEL",KEYS[1])
+	if ARGV[2] then
+		redis.call("PUBLISH",ARGV[2],KEYS[1])
+	end
+	return 1
 else
 	return 0
 end
 `
 
+// releaseLockShipped runs releaseLockScript (or the library function when loaded)
+var releaseLockShipped = shippedScript{function: releaseLockFunction, script: redis.NewScript(-1, releaseLockScript)}
+
 // WriteLock attempts to grab a redis lock
+// A new holder bumps the fencing token of a lock taken with WriteFencedLock() before
+// Uses the shipped function library when loaded (see LoadFunctions)
 // Creates a new connection and closes connection at end of function call
 //
 // Custom connections use method: WriteLockRaw()
@@ -48,14 +72,19 @@ func WriteLock(ctx context.Context, client *Client, name, secret string, ttl int
 		return false, err
 	}
 	defer client.CloseConnection(conn)
-	return WriteLockRaw(conn, name, secret, ttl)
+	return writeLockRaw(conn, client.functionsLoaded(), name, secret, ttl)
 }
 
 // WriteLockRaw attempts to grab a redis lock
 // Uses existing connection (does not close connection)
 func WriteLockRaw(conn redis.Conn, name, secret string, ttl int64) (bool, error) {
-	script := redis.NewScript(1, lockScript)
-	if resp, err := redis.Int(script.Do(conn, name, secret, ttl)); err != nil {
+	return writeLockRaw(conn, false, name, secret, ttl)
+}
+
+// writeLockRaw runs the lock function (when functions are loaded) or script
+func writeLockRaw(conn redis.Conn, functions bool, name, secret string, ttl int64) (bool, error) {
+	if resp, err := redis.Int(lockShipped.do(conn, functions, []string{name, FencingTokenPrefix + name},
+		secret, ttl)); err != nil {
 		return false, err
 	} else if resp != 0 {
 		return true, nil
@@ -63,7 +92,8 @@ func WriteLockRaw(conn redis.Conn, name, secret string, ttl int64) (bool, error)
 	return false, ErrLockMismatch
 }
 
-// ReleaseLock releases the redis lock
+// ReleaseLock releases the redis lock and notifies waiters on LockReleaseChannel
+// Uses the shipped function library when loaded (see LoadFunctions)
 // Creates a new connection and closes connection at end of function call
 //
 // Custom connections use method: ReleaseLockRaw()
@@ -73,17 +103,32 @@ func ReleaseLock(ctx context.Context, client *Client, name, secret string) (bool
 		return false, err
 	}
 	defer client.CloseConnection(conn)
-	return ReleaseLockRaw(conn, name, secret)
+	return releaseLockRaw(conn, client.functionsLoaded(), name, secret)
 }
 
-// ReleaseLockRaw releases the redis lock
+// ReleaseLockRaw releases the redis lock and notifies waiters on LockReleaseChannel
 // Uses existing connection (does not close connection)
 func ReleaseLockRaw(conn redis.Conn, name, secret string) (bool, error) {
-	script := redis.NewScript(1, releaseLockScript)
-	if resp, err := redis.Int(script.Do(conn, name, secret)); err != nil {
+	return releaseLockRaw(conn, false, name, secret)
+}
+
+// releaseLockRaw runs the release function (when functions are loaded) or script
+func releaseLockRaw(conn redis.Conn, functions bool, name, secret string) (bool, error) {
+	resp, err := redis.Int(releaseLockShipped.do(conn, functions, []string{name}, secret, LockReleaseChannel))
+	if err != nil {
 		return false, err
-	} else if resp != 0 {
+	}
+	if resp != 0 {
 		return true, nil
 	}
 	return false, ErrLockMismatch
 }
+
+// newLockSecret returns a random secret for a lock owned by this process
+func newLockSecret() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,296 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+
+	"github.com/mrz1836/go-cache/nrredis"
+)
+
+// ErrLockTimeout is the error if the lock was not acquired within LockOptions.WaitTimeout
+var ErrLockTimeout = errors.New("timed out waiting for the lock")
+
+const (
+	// defaultLockTTL is the default ttl of a lock taken with AcquireLock()
+	defaultLockTTL = 30 * time.Second
+
+	// defaultLockBackoffMin is the default backoff before the first retry
+	defaultLockBackoffMin = 10 * time.Millisecond
+
+	// defaultLockBackoffMax is the default maximum backoff between retries
+	defaultLockBackoffMax = time.Second
+
+	// lockSubscribeTimeout bounds opening the shared lock release subscription
+	lockSubscribeTimeout = 5 * time.Second
+)
+
+// LockOptions configures AcquireLock()
+type LockOptions struct {
+	Secret      string        // Secret to lock with (default: a random secret, returned by AcquireLock)
+	TTL         time.Duration // Lock ttl, rounded up to whole seconds (default: 30s)
+	WaitTimeout time.Duration // How long to wait for the lock (default: until the context is done)
+	MinBackoff  time.Duration // Backoff before the first retry, doubled on every retry (default: 10ms)
+	MaxBackoff  time.Duration // Maximum backoff between retries (default: 1s)
+	RenewEvery  time.Duration // Lock handles only: how often the lease is renewed, below the TTL (default: TTL/3)
+}
+
+// withDefaults returns a copy of the options with the defaults filled in
+func (o *LockOptions) withDefaults() LockOptions {
+	opts := LockOptions{}
+	if o != nil {
+		opts = *o
+	}
+	if opts.TTL <= 0 {
+		opts.TTL = defaultLockTTL
+	}
+	if opts.MinBackoff <= 0 {
+		opts.MinBackoff = defaultLockBackoffMin
+	}
+	if opts.MaxBackoff < opts.MinBackoff {
+		opts.MaxBackoff = max(defaultLockBackoffMax, opts.MinBackoff)
+	}
+	if opts.RenewEvery <= 0 {
+		opts.RenewEvery = time.Duration(opts.ttlSeconds()) * time.Second / 3
+	}
+	return opts
+}
+
+// ttlSeconds returns the lock ttl in seconds
+// Lock TTLs are in whole seconds; round up so the lock covers at least the TTL
+func (o *LockOptions) ttlSeconds() int64 {
+	return max(int64(math.Ceil(o.TTL.Seconds())), 1)
+}
+
+// AcquireLock grabs a redis lock, waiting until it is free (nil options are allowed)
+// Retries with a jittered backoff until the lock is taken, the context is done or the
+// wait timeout passes (ErrLockTimeout). Waiters retry as soon as ReleaseLock frees the
+// lock: all the waiters of a client share one subscription to LockReleaseChannel (one
+// pooled connection, held while anyone waits). They keep polling if subscribing fails, or
+// if the pool has no connection to spare for it (MaxActive).
+// Returns the secret the lock is held with (release it with ReleaseLock)
+//
+// Uses methods: WriteLock(), Subscribe()
+func AcquireLock(ctx context.Context, client *Client, name string, opts *LockOptions) (string, error) {
+	secret, _, err := acquireLock(ctx, client, name, opts, false)
+	return secret, err
+}
+
+// AcquireFencedLock is AcquireLock() taking a fencing token with the lock (see WriteFencedLock)
+// Returns the secret the lock is held with and its fencing token
+//
+// Uses methods: WriteFencedLock(), Subscribe()
+func AcquireFencedLock(ctx context.Context, client *Client, name string,
+	opts *LockOptions,
+) (secret string, token int64, err error) {
+	return acquireLock(ctx, client, name, opts, true)
+}
+
+// acquireLock waits for the lock, taking a fencing token when fenced
+func acquireLock(ctx context.Context, client *Client, name string, opts *LockOptions,
+	fenced bool,
+) (string, int64, error) {
+	o := opts.withDefaults()
+	secret := o.Secret
+	if len(secret) == 0 {
+		var err error
+		if secret, err = newLockSecret(); err != nil {
+			return "", 0, err
+		}
+	}
+	if o.WaitTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, o.WaitTimeout, ErrLockTimeout)
+		defer cancel()
+	}
+
+	ttl := o.ttlSeconds()
+	key := client.physicalKeys(name)[0]
+
+	var released <-chan struct{}
+	waiting := false
+	defer func() {
+		if waiting {
+			client.root().lockWaiters.leave(key, released)
+		}
+	}()
+
+	backoff := o.MinBackoff
+	for {
+		token, err := tryLock(ctx, client, name, secret, ttl, fenced)
+		if err == nil {
+			return secret, token, nil
+		}
+		if !errors.Is(err, ErrLockMismatch) {
+			if ctx.Err() != nil {
+				return "", 0, context.Cause(ctx)
+			}
+			return "", 0, err
+		}
+
+		// Listen for releases once the lock is found taken, then retry right away in case
+		// it was released before the subscription started. Without a subscription the
+		// backoff is the only wake-up.
+		if !waiting {
+			waiting = true
+			var listening bool
+			if released, listening = client.root().lockWaiters.join(ctx, client.root(), key); listening {
+				continue
+			}
+		}
+
+		if err = waitForRelease(ctx, released, jitter(backoff)); err != nil {
+			return "", 0, err
+		}
+		backoff = min(backoff*2, o.MaxBackoff)
+	}
+}
+
+// tryLock makes one attempt at the lock, returning the fencing token when fenced
+func tryLock(ctx context.Context, client *Client, name, secret string, ttl int64, fenced bool) (int64, error) {
+	if fenced {
+		return WriteFencedLock(ctx, client, name, secret, ttl)
+	}
+	_, err := WriteLock(ctx, client, name, secret, ttl)
+	return 0, err
+}
+
+// waitForRelease waits for the backoff, or until the lock is released or the context is done
+func waitForRelease(ctx context.Context, released <-chan struct{}, backoff time.Duration) error {
+	timer := time.NewTimer(backoff)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return context.Cause(ctx)
+	case <-timer.C:
+	case <-released:
+	}
+	return nil
+}
+
+// lockWaiters shares one LockReleaseChannel subscription among the AcquireLock calls of a
+// client, waking only the waiters of the lock that was released. The subscription is opened
+// by the first waiter and closed when the last one leaves.
+type lockWaiters struct {
+	mu      sync.Mutex
+	sub     *Subscription
+	cancel  context.CancelFunc                    // ends the subscription's context
+	opening bool                                  // a waiter is opening the subscription
+	waiters map[string]map[chan struct{}]struct{} // wake-up channels by lock key (with the namespace)
+	count   int
+}
+
+// join registers a waiter for the lock key and returns its wake-up channel (call leave when
+// done). listening is false when there is no subscription (the waiter has to poll).
+// Only one waiter opens the subscription, without holding the lock; the others poll meanwhile.
+func (w *lockWaiters) join(ctx context.Context, client *Client, key string) (<-chan struct{}, bool) {
+	released := make(chan struct{}, 1)
+	w.mu.Lock()
+	if w.waiters == nil {
+		w.waiters = make(map[string]map[chan struct{}]struct{})
+	}
+	if w.waiters[key] == nil {
+		w.waiters[key] = make(map[chan struct{}]struct{})
+	}
+	w.waiters[key][released] = struct{}{}
+	w.count++
+	open := w.sub == nil && !w.opening && poolHasRoom(client.Pool)
+	w.opening = w.opening || open
+	listening := w.sub != nil
+	w.mu.Unlock()
+	if !open {
+		return released, listening
+	}
+
+	sub, cancel, err := subscribeLockReleases(ctx, client)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.opening = false
+	if err != nil {
+		return released, false
+	}
+	w.sub, w.cancel = sub, cancel
+	go w.listen(sub.Messages)
+	return released, true
+}
+
+// subscribeLockReleases opens the lock release subscription
+// Opening gives up when ctx is done or after lockSubscribeTimeout; once open, the subscription
+// outlives ctx (the waiter that opened it) and lasts until cancel is called
+func subscribeLockReleases(ctx context.Context, client *Client) (*Subscription, context.CancelFunc, error) {
+	subCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stopCaller := context.AfterFunc(ctx, cancel)
+	timer := time.AfterFunc(lockSubscribeTimeout, cancel)
+
+	sub, err := Subscribe(subCtx, client, []string{LockReleaseChannel})
+	stopCaller()
+	timer.Stop()
+	if err == nil && subCtx.Err() != nil {
+		_ = sub.Close()
+		err = subCtx.Err()
+	}
+	if err != nil {
+		cancel()
+		return nil, nil, err
+	}
+	return sub, cancel, nil
+}
+
+// leave removes the waiter, closing the subscription when nobody waits anymore
+func (w *lockWaiters) leave(key string, released <-chan struct{}) {
+	w.mu.Lock()
+	for ch := range w.waiters[key] {
+		if ch == released {
+			delete(w.waiters[key], ch)
+		}
+	}
+	if len(w.waiters[key]) == 0 {
+		delete(w.waiters, key)
+	}
+	w.count--
+	var sub *Subscription
+	var cancel context.CancelFunc
+	if w.count == 0 {
+		sub, w.sub = w.sub, nil
+		cancel, w.cancel = w.cancel, nil
+	}
+	w.mu.Unlock()
+
+	if sub != nil {
+		_ = sub.Close()
+		cancel()
+	}
+}
+
+// listen wakes the waiters of every released lock until the subscription is closed
+func (w *lockWaiters) listen(messages <-chan Message) {
+	for msg := range messages {
+		w.notify(string(msg.Data))
+	}
+}
+
+// notify wakes the waiters of the lock key
+func (w *lockWaiters) notify(key string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for ch := range w.waiters[key] {
+		select {
+		case ch <- struct{}{}:
+		default: // already woken
+		}
+	}
+}
+
+// poolHasRoom returns false when a capped pool (MaxActive) cannot spare a connection for the
+// release subscription without starving the lock attempts themselves
+func poolHasRoom(pool nrredis.Pool) bool {
+	p, ok := pool.(*redis.Pool)
+	if !ok || p.MaxActive <= 0 {
+		return true
+	}
+	return p.ActiveCount()+2 <= p.MaxActive
+}
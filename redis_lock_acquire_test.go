@@ -0,0 +1,301 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/rafaeljusto/redigomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockFailedSubscribe makes SUBSCRIBE fail so lock waiters poll
+// The pool unsubscribes and echoes a sentinel when it takes the connection back.
+func mockFailedSubscribe(conn *redigomock.Conn) {
+	conn.GenericCommand(SubscribeCommand).ExpectError(errTestLoader)
+	conn.GenericCommand(UnsubscribeCommand).Expect(nil)
+	conn.GenericCommand("PUNSUBSCRIBE").Expect(nil)
+	conn.GenericCommand("ECHO").Handle(func(args []interface{}) (interface{}, error) {
+		return args[0], nil
+	})
+}
+
+// TestAcquireLock tests the method AcquireLock()
+func TestAcquireLock(t *testing.T) {
+	t.Run("free lock is taken right away", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		lockCmd := conn.GenericCommand(EvalCommand).Expect(int64(1))
+
+		secret, err := AcquireLock(context.Background(), client, testKey, nil)
+		require.NoError(t, err)
+		assert.Len(t, secret, 32)
+		assert.Equal(t, 1, conn.Stats(lockCmd))
+	})
+
+	t.Run("retries until the lock is free", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		mockFailedSubscribe(conn)
+		attempts := 0
+		conn.GenericCommand(EvalCommand).Handle(func(args []interface{}) (interface{}, error) {
+			attempts++
+			assert.Equal(t, []interface{}{2, testKey, FencingTokenPrefix + testKey, "the-secret", int64(2)}, args[1:])
+			if attempts < 3 {
+				return int64(0), nil
+			}
+			return int64(1), nil
+		})
+
+		secret, err := AcquireLock(context.Background(), client, testKey, &LockOptions{
+			Secret: "the-secret", TTL: 1500 * time.Millisecond, MinBackoff: time.Millisecond,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "the-secret", secret)
+		assert.Equal(t, 3, attempts)
+	})
+
+	t.Run("wait timeout", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		mockFailedSubscribe(conn)
+		conn.GenericCommand(EvalCommand).Expect(int64(0))
+
+		_, err := AcquireLock(context.Background(), client, testKey, &LockOptions{
+			WaitTimeout: 20 * time.Millisecond, MinBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond,
+		})
+		require.ErrorIs(t, err, ErrLockTimeout)
+	})
+
+	t.Run("context canceled", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		mockFailedSubscribe(conn)
+		conn.GenericCommand(EvalCommand).Expect(int64(0))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		_, err := AcquireLock(ctx, client, testKey, &LockOptions{MinBackoff: time.Millisecond})
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("lock error is returned", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.GenericCommand(EvalCommand).ExpectError(errTestLoader)
+
+		_, err := AcquireLock(context.Background(), client, testKey, nil)
+		require.ErrorIs(t, err, errTestLoader)
+	})
+
+	t.Run("release wakes the waiter using real redis", func(t *testing.T) {
+		if testing.Short() {
+			t.Skip("skipping live local redis tests")
+		}
+
+		client, conn, err := loadRealRedis(t)
+		require.NoError(t, err)
+		defer client.CloseAll(conn)
+		require.NoError(t, clearRealRedis(conn, t))
+
+		_, err = WriteLockRaw(conn, testKey, "holder", 10)
+		require.NoError(t, err)
+
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			_, _ = ReleaseLock(context.Background(), client, testKey, "holder")
+		}()
+
+		// The backoff alone would keep the waiter asleep for a minute
+		start := time.Now()
+		var secret string
+		secret, err = AcquireLock(context.Background(), client, testKey, &LockOptions{
+			WaitTimeout: 5 * time.Second, MinBackoff: time.Minute, MaxBackoff: time.Minute,
+		})
+		require.NoError(t, err)
+		assert.Less(t, time.Since(start), 5*time.Second)
+
+		var locked bool
+		locked, err = ReleaseLockRaw(conn, testKey, secret)
+		require.NoError(t, err)
+		assert.True(t, locked)
+	})
+}
+
+// TestWaitForRelease tests the method waitForRelease()
+func TestWaitForRelease(t *testing.T) {
+	t.Run("release wakes the waiter", func(t *testing.T) {
+		released := make(chan struct{}, 1)
+		released <- struct{}{}
+
+		start := time.Now()
+		require.NoError(t, waitForRelease(context.Background(), released, time.Minute))
+		assert.Less(t, time.Since(start), time.Minute)
+	})
+
+	t.Run("no channel waits for the backoff", func(t *testing.T) {
+		require.NoError(t, waitForRelease(context.Background(), nil, time.Millisecond))
+	})
+
+	t.Run("context done", func(t *testing.T) {
+		ctx, cancel := context.WithCancelCause(context.Background())
+		cancel(ErrLockTimeout)
+
+		err := waitForRelease(ctx, nil, time.Minute)
+		require.ErrorIs(t, err, ErrLockTimeout)
+	})
+}
+
+// TestLockWaiters tests the shared lock release subscription
+func TestLockWaiters(t *testing.T) {
+	t.Run("release wakes only the waiters of the key", func(t *testing.T) {
+		var w lockWaiters
+		w.waiters = map[string]map[chan struct{}]struct{}{}
+		first, second, other := make(chan struct{}, 1), make(chan struct{}, 1), make(chan struct{}, 1)
+		w.waiters[testKey] = map[chan struct{}]struct{}{first: {}, second: {}}
+		w.waiters["other-key"] = map[chan struct{}]struct{}{other: {}}
+		w.count = 3
+
+		w.notify(testKey)
+		w.notify(testKey)
+		assert.Len(t, first, 1)
+		assert.Len(t, second, 1)
+		assert.Empty(t, other)
+
+		w.leave(testKey, first)
+		w.leave(testKey, second)
+		w.leave("other-key", other)
+		assert.Empty(t, w.waiters)
+		assert.Zero(t, w.count)
+	})
+
+	t.Run("opening the subscription blocks nobody and honours the context", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+		pool, _ := client.Pool.(*redis.Pool)
+		dialed := make(chan struct{})
+		pool.DialContext = func(ctx context.Context) (redis.Conn, error) {
+			close(dialed)
+			<-ctx.Done() // hangs until the opening waiter gives up
+			return nil, ctx.Err()
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		joined := make(chan bool)
+		go func() {
+			_, listening := client.lockWaiters.join(ctx, client, testKey)
+			joined <- listening
+		}()
+		<-dialed
+
+		// Others join, poll and get notified while the subscription is being opened
+		other, listening := client.lockWaiters.join(context.Background(), client, "other-key")
+		assert.False(t, listening)
+		client.lockWaiters.notify("other-key")
+		assert.Len(t, other, 1)
+
+		cancel()
+		select {
+		case listening = <-joined:
+			assert.False(t, listening)
+		case <-time.After(time.Second):
+			t.Fatal("opening the subscription ignored the context")
+		}
+		client.lockWaiters.leave("other-key", other)
+		assert.Equal(t, 1, client.lockWaiters.count)
+		assert.False(t, client.lockWaiters.opening)
+	})
+
+	t.Run("capped pool polls instead of subscribing", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+		pool, _ := client.Pool.(*redis.Pool)
+		pool.MaxActive = 1
+		pool.Wait = true
+
+		subscribeCmd := conn.GenericCommand(SubscribeCommand).ExpectError(errTestLoader)
+		lockCmd := conn.GenericCommand(EvalCommand).Expect(int64(0)).Expect(int64(1))
+
+		_, err := AcquireLock(context.Background(), client, testKey, &LockOptions{
+			WaitTimeout: 5 * time.Second, MinBackoff: time.Millisecond,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 2, conn.Stats(lockCmd))
+		assert.False(t, subscribeCmd.Called)
+		assert.Nil(t, client.lockWaiters.sub)
+	})
+
+	t.Run("waiters share one subscription using real redis", func(t *testing.T) {
+		if testing.Short() {
+			t.Skip("skipping live local redis tests")
+		}
+
+		client, conn, err := loadRealRedis(t)
+		require.NoError(t, err)
+		defer client.CloseAll(conn)
+		require.NoError(t, clearRealRedis(conn))
+		client.CloseConnection(conn)
+
+		// More waiters than connections: one connection subscribes, the others take turns locking
+		pool, _ := client.Pool.(*redis.Pool)
+		pool.MaxActive = 3
+		pool.Wait = true
+
+		var secret string
+		secret, err = AcquireLock(context.Background(), client, testKey, nil)
+		require.NoError(t, err)
+
+		const waiters = 10
+		var wg sync.WaitGroup
+		errs := make([]error, waiters)
+		for i := 0; i < waiters; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				var waiterSecret string
+				if waiterSecret, errs[i] = AcquireLock(context.Background(), client, testKey, &LockOptions{
+					WaitTimeout: 10 * time.Second, MinBackoff: 5 * time.Millisecond, MaxBackoff: 50 * time.Millisecond,
+				}); errs[i] == nil {
+					_, errs[i] = ReleaseLock(context.Background(), client, testKey, waiterSecret)
+				}
+			}(i)
+		}
+		time.Sleep(50 * time.Millisecond)
+		_, err = ReleaseLock(context.Background(), client, testKey, secret)
+		require.NoError(t, err)
+
+		wg.Wait()
+		for _, waitErr := range errs {
+			require.NoError(t, waitErr)
+		}
+		assert.Nil(t, client.lockWaiters.sub)
+	})
+}
+
+// ExampleAcquireLock is an example of the method AcquireLock()
+func ExampleAcquireLock() {
+	// Load a mocked redis for testing/examples
+	client, conn := loadMockRedis()
+
+	// Close connections at end of request
+	defer client.CloseAll(conn)
+
+	// Mock the lock being free
+	conn.GenericCommand(EvalCommand).Expect(int64(1))
+
+	// Wait up to a second for the lock
+	secret, _ := AcquireLock(context.Background(), client, "report", &LockOptions{
+		Secret: "worker-1", TTL: 10 * time.Second, WaitTimeout: time.Second,
+	})
+	fmt.Printf("lock held with secret: %s", secret)
+	// Output:lock held with secret: worker-1
+}
@@ -0,0 +1,157 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// ErrStaleToken is the error if a fencing token is no longer the lock's current token
+var ErrStaleToken = errors.New("fencing token is no longer current")
+
+// fencedLockScript is the locking script that also hands out a fencing token
+// KEYS[1] is the lock, KEYS[2] the token counter. A new holder increments the counter, the
+// holder re-acquiring with the same secret keeps its token. Returns 0 when locked by someone else.
+const fencedLockScript = `
+local v = redis.call("GET", KEYS[1])
+if v == false then
+	redis.call("SET", KEYS[1], ARGV[1], "NX", "EX", ARGV[2])
+	return redis.call("INCR", KEYS[2])
+elseif v == ARGV[1] then
+	redis.call("SET", KEYS[1], ARGV[1], "EX", ARGV[2])
+	local token = redis.call("GET", KEYS[2])
+	if token == false then
+		return redis.call("INCR", KEYS[2])
+	end
+	return tonumber(token)
+end
+return 0
+`
+
+// fencedLockShipped runs fencedLockScript (or the library function when loaded)
+var fencedLockShipped = shippedScript{function: fencedLockFunction, script: redis.NewScript(-1, fencedLockScript)}
+
+// setIfTokenScript sets KEYS[2] only while the token counter KEYS[1] equals the token in ARGV[1]
+const setIfTokenScript = `
+if redis.call("GET", KEYS[1]) ~= ARGV[1] then
+	return 0
+end
+if tonumber(ARGV[3]) > 0 then
+	redis.call("SET", KEYS[2], ARGV[2], "EX", ARGV[3])
+else
+	redis.call("SET", KEYS[2], ARGV[2])
+end
+return 1
+`
+
+// setIfTokenShipped runs setIfTokenScript (or the library function when loaded)
+var setIfTokenShipped = shippedScript{function: setIfTokenFunction, script: redis.NewScript(-1, setIfTokenScript)}
+
+// WriteFencedLock attempts to grab a redis lock and returns its fencing token
+// Every new holder gets a higher token than the one before (tokens start at 1); the holder
+// re-locking with the same secret keeps its token. Protected writes check the token with
+// SetIfTokenCurrent(), so a holder whose lock expired (e.g. paused by GC) cannot overwrite
+// the work of the next one, including a holder that took the lock with WriteLock() or
+// AcquireLock(). The counter (FencingTokenPrefix + name) is never expired.
+// Uses the shipped function library when loaded (see LoadFunctions)
+// Creates a new connection and closes connection at end of function call
+//
+// Custom connections use method: WriteFencedLockRaw()
+func WriteFencedLock(ctx context.Context, client *Client, name, secret string, ttl int64) (int64, error) {
+	conn, err := client.GetConnectionWithContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer client.CloseConnection(conn)
+	return writeFencedLockRaw(conn, client.functionsLoaded(), name, secret, ttl)
+}
+
+// WriteFencedLockRaw attempts to grab a redis lock and returns its fencing token
+// Uses existing connection (does not close connection)
+func WriteFencedLockRaw(conn redis.Conn, name, secret string, ttl int64) (int64, error) {
+	return writeFencedLockRaw(conn, false, name, secret, ttl)
+}
+
+// writeFencedLockRaw runs the fenced lock function (when functions are loaded) or script
+func writeFencedLockRaw(conn redis.Conn, functions bool, name, secret string, ttl int64) (int64, error) {
+	token, err := redis.Int64(fencedLockShipped.do(conn, functions, []string{name, FencingTokenPrefix + name},
+		secret, ttl))
+	if err != nil {
+		return 0, err
+	}
+	if token == 0 {
+		return 0, ErrLockMismatch
+	}
+	return token, nil
+}
+
+// FencingToken returns the latest fencing token handed out for the lock (0 if none yet)
+// Creates a new connection and closes connection at end of function call
+//
+// Custom connections use method: FencingTokenRaw()
+func FencingToken(ctx context.Context, client *Client, name string) (int64, error) {
+	conn, err := client.GetConnectionWithContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer client.CloseConnection(conn)
+	return FencingTokenRaw(conn, name)
+}
+
+// FencingTokenRaw returns the latest fencing token handed out for the lock (0 if none yet)
+// Uses existing connection (does not close connection)
+//
+// Spec: https://redis.io/commands/get
+func FencingTokenRaw(conn redis.Conn, name string) (int64, error) {
+	token, err := redis.Int64(conn.Do(GetCommand, FencingTokenPrefix+name))
+	if errors.Is(err, redis.ErrNil) {
+		return 0, nil
+	}
+	return token, err
+}
+
+// SetIfTokenCurrent sets the key only while the token is the lock's latest fencing token
+// The check and the write are atomic. Returns ErrStaleToken (nothing is written) once the lock
+// has been taken by a newer holder. A ttl of 0 stores the key without expiration.
+// Uses the shipped function library when loaded (see LoadFunctions)
+// Creates a new connection and closes connection at end of function call
+//
+// Custom connections use method: SetIfTokenCurrentRaw()
+func SetIfTokenCurrent(ctx context.Context, client *Client, lockName string, token int64, key string,
+	value interface{}, ttl time.Duration,
+) error {
+	conn, err := client.GetConnectionWithContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.CloseConnection(conn)
+	return setIfTokenCurrentRaw(conn, client.functionsLoaded(), lockName, token, key, value, ttl)
+}
+
+// SetIfTokenCurrentRaw sets the key only while the token is the lock's latest fencing token
+// Uses existing connection (does not close connection)
+func SetIfTokenCurrentRaw(conn redis.Conn, lockName string, token int64, key string, value interface{},
+	ttl time.Duration,
+) error {
+	return setIfTokenCurrentRaw(conn, false, lockName, token, key, value, ttl)
+}
+
+// setIfTokenCurrentRaw runs the conditional set function (when functions are loaded) or script
+func setIfTokenCurrentRaw(conn redis.Conn, functions bool, lockName string, token int64, key string,
+	value interface{}, ttl time.Duration,
+) error {
+	// TTLs are in whole seconds; round up so the key lives at least ttl
+	seconds := int64(math.Ceil(ttl.Seconds()))
+	set, err := redis.Int(setIfTokenShipped.do(conn, functions, []string{FencingTokenPrefix + lockName, key},
+		token, value, seconds))
+	if err != nil {
+		return err
+	}
+	if set == 0 {
+		return ErrStaleToken
+	}
+	return nil
+}
@@ -0,0 +1,252 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestWriteFencedLock tests the method WriteFencedLock()
+func TestWriteFencedLock(t *testing.T) {
+	t.Run("returns the fencing token", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		var args []interface{}
+		conn.GenericCommand(EvalCommand).Handle(func(a []interface{}) (interface{}, error) {
+			args = a
+			return int64(7), nil
+		})
+
+		token, err := WriteFencedLock(context.Background(), client, testKey, "secret", 10)
+		require.NoError(t, err)
+		assert.Equal(t, int64(7), token)
+		assert.Equal(t, []interface{}{2, testKey, FencingTokenPrefix + testKey, "secret", int64(10)}, args[1:])
+	})
+
+	t.Run("locked by someone else", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.GenericCommand(EvalCommand).Expect(int64(0))
+
+		_, err := WriteFencedLockRaw(conn, testKey, "secret", 10)
+		require.ErrorIs(t, err, ErrLockMismatch)
+	})
+
+	t.Run("uses the function when loaded", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+		client.functions.Store(true)
+
+		conn.Command(FunctionCallCommand, fencedLockFunction, 2, testKey, FencingTokenPrefix+testKey,
+			"secret", int64(10)).Expect(int64(3))
+
+		token, err := WriteFencedLock(context.Background(), client, testKey, "secret", 10)
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), token)
+	})
+
+	t.Run("acquire returns the token", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.GenericCommand(EvalCommand).Expect(int64(4))
+
+		secret, token, err := AcquireFencedLock(context.Background(), client, testKey, nil)
+		require.NoError(t, err)
+		assert.Len(t, secret, 32)
+		assert.Equal(t, int64(4), token)
+	})
+
+	t.Run("tokens increase with every holder using real redis", func(t *testing.T) {
+		if testing.Short() {
+			t.Skip("skipping live local redis tests")
+		}
+
+		client, conn, err := loadRealRedis(t)
+		require.NoError(t, err)
+		defer client.CloseAll(conn)
+		require.NoError(t, clearRealRedis(conn, t))
+
+		var first, again, second int64
+		first, err = WriteFencedLockRaw(conn, testKey, "first", 10)
+		require.NoError(t, err)
+		again, err = WriteFencedLockRaw(conn, testKey, "first", 10)
+		require.NoError(t, err)
+		assert.Equal(t, first, again, "re-locking keeps the token")
+
+		_, err = WriteFencedLockRaw(conn, testKey, "second", 10)
+		require.ErrorIs(t, err, ErrLockMismatch)
+
+		_, err = ReleaseLockRaw(conn, testKey, "first")
+		require.NoError(t, err)
+		second, err = WriteFencedLockRaw(conn, testKey, "second", 10)
+		require.NoError(t, err)
+		assert.Greater(t, second, first)
+
+		var current int64
+		current, err = FencingTokenRaw(conn, testKey)
+		require.NoError(t, err)
+		assert.Equal(t, second, current)
+	})
+
+	t.Run("plain lock takeover fences off the last holder using real redis", func(t *testing.T) {
+		if testing.Short() {
+			t.Skip("skipping live local redis tests")
+		}
+
+		client, conn, err := loadRealRedis(t)
+		require.NoError(t, err)
+		defer client.CloseAll(conn)
+		require.NoError(t, clearRealRedis(conn, t))
+
+		var stale int64
+		stale, err = WriteFencedLockRaw(conn, "job", "paused", 1)
+		require.NoError(t, err)
+
+		// The paused holder's lease runs out and a plain lock takes over
+		time.Sleep(1100 * time.Millisecond)
+		var secret string
+		secret, err = AcquireLock(context.Background(), client, "job", &LockOptions{WaitTimeout: time.Second})
+		require.NoError(t, err)
+		require.ErrorIs(t, SetIfTokenCurrentRaw(conn, "job", stale, testKey, "from paused", 0), ErrStaleToken)
+
+		var current int64
+		current, err = FencingTokenRaw(conn, "job")
+		require.NoError(t, err)
+		assert.Equal(t, stale+1, current)
+
+		// Re-locking with the same secret keeps the token
+		_, err = WriteLockRaw(conn, "job", secret, 10)
+		require.NoError(t, err)
+		current, err = FencingTokenRaw(conn, "job")
+		require.NoError(t, err)
+		assert.Equal(t, stale+1, current)
+	})
+
+	t.Run("plain locks do not create a counter using real redis", func(t *testing.T) {
+		if testing.Short() {
+			t.Skip("skipping live local redis tests")
+		}
+
+		client, conn, err := loadRealRedis(t)
+		require.NoError(t, err)
+		defer client.CloseAll(conn)
+		require.NoError(t, clearRealRedis(conn, t))
+
+		_, err = WriteLockRaw(conn, "job", "the-secret", 10)
+		require.NoError(t, err)
+
+		var current int64
+		current, err = FencingTokenRaw(conn, "job")
+		require.NoError(t, err)
+		assert.Zero(t, current)
+	})
+}
+
+// TestFencingToken tests the method FencingToken()
+func TestFencingToken(t *testing.T) {
+	client, conn := loadMockRedis(t)
+	defer client.CloseAll(conn)
+
+	conn.Command(GetCommand, FencingTokenPrefix+testKey).Expect([]byte("5"))
+	conn.Command(GetCommand, FencingTokenPrefix+"never-locked").Expect(nil)
+
+	token, err := FencingToken(context.Background(), client, testKey)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), token)
+
+	token, err = FencingTokenRaw(conn, "never-locked")
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), token)
+}
+
+// TestSetIfTokenCurrent tests the method SetIfTokenCurrent()
+func TestSetIfTokenCurrent(t *testing.T) {
+	t.Run("current token writes", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		var args []interface{}
+		conn.GenericCommand(EvalCommand).Handle(func(a []interface{}) (interface{}, error) {
+			args = a
+			return int64(1), nil
+		})
+
+		err := SetIfTokenCurrent(context.Background(), client, "job", 4, testKey, testStringValue, 1500*time.Millisecond)
+		require.NoError(t, err)
+		assert.Equal(t, []interface{}{2, FencingTokenPrefix + "job", testKey, int64(4), testStringValue, int64(2)},
+			args[1:])
+	})
+
+	t.Run("stale token", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.GenericCommand(EvalCommand).Expect(int64(0))
+
+		err := SetIfTokenCurrentRaw(conn, "job", 3, testKey, testStringValue, 0)
+		require.ErrorIs(t, err, ErrStaleToken)
+	})
+
+	t.Run("script error", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.GenericCommand(EvalCommand).ExpectError(errTestLoader)
+
+		err := SetIfTokenCurrent(context.Background(), client, "job", 3, testKey, testStringValue, 0)
+		require.ErrorIs(t, err, errTestLoader)
+	})
+
+	t.Run("stale holder is fenced off using real redis", func(t *testing.T) {
+		if testing.Short() {
+			t.Skip("skipping live local redis tests")
+		}
+
+		client, conn, err := loadRealRedis(t)
+		require.NoError(t, err)
+		defer client.CloseAll(conn)
+		require.NoError(t, clearRealRedis(conn, t))
+
+		var stale, current int64
+		stale, err = WriteFencedLockRaw(conn, "job", "paused", 10)
+		require.NoError(t, err)
+
+		// The paused holder's lock is gone and someone else takes it
+		_, err = ReleaseLockRaw(conn, "job", "paused")
+		require.NoError(t, err)
+		current, err = WriteFencedLockRaw(conn, "job", "next", 10)
+		require.NoError(t, err)
+
+		require.NoError(t, SetIfTokenCurrentRaw(conn, "job", current, testKey, "from next", time.Minute))
+		require.ErrorIs(t, SetIfTokenCurrentRaw(conn, "job", stale, testKey, "from paused", 0), ErrStaleToken)
+
+		var value string
+		value, err = GetRaw(conn, testKey)
+		require.NoError(t, err)
+		assert.Equal(t, "from next", value)
+	})
+}
+
+// ExampleSetIfTokenCurrent is an example of the method SetIfTokenCurrent()
+func ExampleSetIfTokenCurrent() {
+	// Load a mocked redis for testing/examples
+	client, conn := loadMockRedis()
+
+	// Close connections at end of request
+	defer client.CloseAll(conn)
+
+	// Mock a newer holder having taken the lock
+	conn.GenericCommand(EvalCommand).Expect(int64(0))
+
+	// Write the result only while our token is still the current one
+	err := SetIfTokenCurrent(context.Background(), client, "report", 41, "report:result", "done", time.Hour)
+	fmt.Print(err)
+	// Output:fencing token is no longer current
+}
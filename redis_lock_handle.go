@@ -0,0 +1,144 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Define static errors to avoid dynamic error creation
+var (
+	ErrLockExpired         = errors.New("lock expired before it could be renewed")
+	ErrInvalidRenewalEvery = errors.New("lock renewal interval must be shorter than the lock ttl")
+)
+
+// Lock is a held redis lock whose lease is renewed in the background (see ObtainLock)
+// Watch Lost() to stop work when the lock is lost, and call Unlock() when done.
+type Lock struct {
+	client *Client
+	name   string
+	secret string
+	token  int64 // Fencing token of this holder
+	ttl    int64 // Lease in seconds
+
+	lost     chan struct{} // closed when renewal fails
+	stop     chan struct{} // closed by Unlock() to stop renewal
+	done     chan struct{} // closed when the renewal goroutine exits
+	stopOnce sync.Once
+
+	mu  sync.Mutex
+	err error // why the lock was lost
+}
+
+// ObtainLock is AcquireFencedLock() returning a Lock handle that renews the lease every
+// LockOptions.RenewEvery (default: a third of the TTL) until Unlock() is called
+// Renewal re-acquires the lock with the same secret. The lock is lost (see Lost) when redis
+// reports another secret, when the lease lapsed and the lock was taken again under a new
+// fencing token, or when no renewal succeeds before the lease runs out.
+// Returns ErrInvalidRenewalEvery if RenewEvery is not shorter than the TTL (the lease would lapse).
+//
+// Uses methods: AcquireFencedLock(), WriteFencedLock()
+func ObtainLock(ctx context.Context, client *Client, name string, opts *LockOptions) (*Lock, error) {
+	o := opts.withDefaults()
+	if o.RenewEvery >= time.Duration(o.ttlSeconds())*time.Second {
+		return nil, ErrInvalidRenewalEvery
+	}
+	secret, token, err := acquireLock(ctx, client, name, &o, true)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &Lock{
+		client: client,
+		name:   name,
+		secret: secret,
+		token:  token,
+		ttl:    o.ttlSeconds(),
+		lost:   make(chan struct{}),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go l.renew(context.WithoutCancel(ctx), o.RenewEvery)
+	return l, nil
+}
+
+// Secret returns the secret the lock is held with
+func (l *Lock) Secret() string {
+	return l.secret
+}
+
+// Token returns the fencing token of the lock (see SetIfTokenCurrent)
+func (l *Lock) Token() int64 {
+	return l.token
+}
+
+// Lost returns a channel that is closed if the lock is lost (see Err)
+func (l *Lock) Lost() <-chan struct{} {
+	return l.lost
+}
+
+// Err returns why the lock was lost, nil while it is held
+func (l *Lock) Err() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.err
+}
+
+// Unlock stops renewing the lease and releases the lock
+// Returns ErrLockMismatch if the lock was lost to another owner in the meantime
+//
+// Uses methods: ReleaseLock()
+func (l *Lock) Unlock(ctx context.Context) error {
+	l.stopOnce.Do(func() {
+		close(l.stop)
+	})
+	<-l.done
+	_, err := ReleaseLock(ctx, l.client, l.name, l.secret)
+	return err
+}
+
+// renew extends the lease until Unlock() is called or the lock is lost
+// Failed renewals are retried on the next tick while the lease has not run out.
+func (l *Lock) renew(ctx context.Context, every time.Duration) {
+	defer close(l.done)
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+
+	expires := time.Now().Add(time.Duration(l.ttl) * time.Second)
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+		}
+
+		renewCtx, cancel := context.WithDeadline(ctx, expires)
+		started := time.Now()
+		token, err := WriteFencedLock(renewCtx, l.client, l.name, l.secret, l.ttl)
+		cancel()
+		switch {
+		case err == nil && token != l.token:
+			// Renewing took the lock again under a new token; do not keep holding it
+			_, _ = ReleaseLock(ctx, l.client, l.name, l.secret)
+			l.lose(ErrLockExpired)
+			return
+		case err == nil:
+			expires = started.Add(time.Duration(l.ttl) * time.Second)
+		case errors.Is(err, ErrLockMismatch):
+			l.lose(err)
+			return
+		case !time.Now().Before(expires):
+			l.lose(errors.Join(ErrLockExpired, err))
+			return
+		}
+	}
+}
+
+// lose records why the lock was lost and closes the Lost() channel
+func (l *Lock) lose(err error) {
+	l.mu.Lock()
+	l.err = err
+	l.mu.Unlock()
+	close(l.lost)
+}
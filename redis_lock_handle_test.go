@@ -0,0 +1,201 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// isReleaseLock returns true if the script arguments are a lock release (see releaseLockRaw)
+func isReleaseLock(args []interface{}) bool {
+	return args[len(args)-1] == LockReleaseChannel
+}
+
+// TestObtainLock tests the method ObtainLock()
+func TestObtainLock(t *testing.T) {
+	t.Run("renews the lease until unlocked", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		var renewals, releases atomic.Int32
+		conn.GenericCommand(EvalCommand).Handle(func(args []interface{}) (interface{}, error) {
+			if isReleaseLock(args) {
+				releases.Add(1)
+			} else {
+				renewals.Add(1)
+			}
+			return int64(1), nil
+		})
+
+		lock, err := ObtainLock(context.Background(), client, testKey, &LockOptions{RenewEvery: time.Millisecond})
+		require.NoError(t, err)
+		assert.Len(t, lock.Secret(), 32)
+		assert.Equal(t, int64(1), lock.Token())
+
+		require.Eventually(t, func() bool { return renewals.Load() >= 3 }, time.Second, time.Millisecond)
+		require.NoError(t, lock.Unlock(context.Background()))
+		require.NoError(t, lock.Unlock(context.Background()), "unlock is idempotent")
+		assert.Equal(t, int32(2), releases.Load())
+		assert.NoError(t, lock.Err())
+
+		select {
+		case <-lock.Lost():
+			t.Fatal("lock reported lost after unlock")
+		default:
+		}
+	})
+
+	t.Run("lost to another owner", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		var calls atomic.Int32
+		conn.GenericCommand(EvalCommand).Handle(func(args []interface{}) (interface{}, error) {
+			if calls.Add(1) == 1 {
+				return int64(1), nil
+			}
+			return int64(0), nil
+		})
+
+		lock, err := ObtainLock(context.Background(), client, testKey, &LockOptions{RenewEvery: time.Millisecond})
+		require.NoError(t, err)
+
+		select {
+		case <-lock.Lost():
+		case <-time.After(time.Second):
+			t.Fatal("lock was not reported lost")
+		}
+		require.ErrorIs(t, lock.Err(), ErrLockMismatch)
+		require.ErrorIs(t, lock.Unlock(context.Background()), ErrLockMismatch)
+	})
+
+	t.Run("lease lapsed and re-taken under a new token", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		var calls, releases atomic.Int32
+		conn.GenericCommand(EvalCommand).Handle(func(args []interface{}) (interface{}, error) {
+			if isReleaseLock(args) {
+				releases.Add(1)
+				return int64(1), nil
+			}
+			return int64(calls.Add(1)), nil
+		})
+
+		lock, err := ObtainLock(context.Background(), client, testKey, &LockOptions{RenewEvery: time.Millisecond})
+		require.NoError(t, err)
+
+		select {
+		case <-lock.Lost():
+		case <-time.After(time.Second):
+			t.Fatal("lock was not reported lost")
+		}
+		require.ErrorIs(t, lock.Err(), ErrLockExpired)
+		assert.Equal(t, int32(1), releases.Load(), "the re-taken lock is released")
+	})
+
+	t.Run("renewal interval not shorter than the ttl", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		lockCmd := conn.GenericCommand(EvalCommand).Expect(int64(1))
+
+		_, err := ObtainLock(context.Background(), client, testKey, &LockOptions{
+			TTL: time.Second, RenewEvery: time.Second,
+		})
+		require.ErrorIs(t, err, ErrInvalidRenewalEvery)
+		assert.False(t, lockCmd.Called)
+	})
+
+	t.Run("transient errors are retried until the lease runs out", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		var calls atomic.Int32
+		conn.GenericCommand(EvalCommand).Handle(func(args []interface{}) (interface{}, error) {
+			if calls.Add(1) == 1 {
+				return int64(1), nil
+			}
+			return nil, errTestLoader
+		})
+
+		start := time.Now()
+		lock, err := ObtainLock(context.Background(), client, testKey, &LockOptions{
+			TTL: time.Second, RenewEvery: 10 * time.Millisecond,
+		})
+		require.NoError(t, err)
+
+		select {
+		case <-lock.Lost():
+		case <-time.After(5 * time.Second):
+			t.Fatal("lock was not reported lost")
+		}
+		assert.GreaterOrEqual(t, time.Since(start), time.Second)
+		assert.Greater(t, calls.Load(), int32(2))
+		require.ErrorIs(t, lock.Err(), ErrLockExpired)
+		require.ErrorIs(t, lock.Err(), errTestLoader)
+	})
+
+	t.Run("acquire error", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.GenericCommand(EvalCommand).ExpectError(errTestLoader)
+
+		_, err := ObtainLock(context.Background(), client, testKey, nil)
+		require.ErrorIs(t, err, errTestLoader)
+	})
+
+	t.Run("lease outlives the ttl using real redis", func(t *testing.T) {
+		if testing.Short() {
+			t.Skip("skipping live local redis tests")
+		}
+
+		client, conn, err := loadRealRedis(t)
+		require.NoError(t, err)
+		defer client.CloseAll(conn)
+		require.NoError(t, clearRealRedis(conn, t))
+
+		var lock *Lock
+		lock, err = ObtainLock(context.Background(), client, testKey, &LockOptions{TTL: time.Second})
+		require.NoError(t, err)
+
+		time.Sleep(2500 * time.Millisecond)
+		_, err = WriteLockRaw(conn, testKey, "someone-else", 10)
+		require.ErrorIs(t, err, ErrLockMismatch, "the lock is still held")
+
+		require.NoError(t, lock.Unlock(context.Background()))
+		var locked bool
+		locked, err = WriteLockRaw(conn, testKey, "someone-else", 10)
+		require.NoError(t, err)
+		assert.True(t, locked)
+	})
+}
+
+// ExampleObtainLock is an example of the method ObtainLock()
+func ExampleObtainLock() {
+	// Load a mocked redis for testing/examples
+	client, conn := loadMockRedis()
+
+	// Close connections at end of request
+	defer client.CloseAll(conn)
+
+	// Mock the lock and its release
+	conn.GenericCommand(EvalCommand).Expect(int64(1))
+
+	// Hold the lock for as long as the job runs
+	lock, _ := ObtainLock(context.Background(), client, "report", &LockOptions{TTL: 10 * time.Second})
+	select {
+	case <-lock.Lost():
+		fmt.Print("lock lost, stopping the job")
+	default:
+		fmt.Print("job done")
+	}
+	_ = lock.Unlock(context.Background())
+	// Output:job done
+}
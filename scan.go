@@ -0,0 +1,204 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// errUnexpectedScanReply is returned when a scan page cannot be parsed
+var errUnexpectedScanReply = errors.New("unexpected scan reply")
+
+// ScanOptions configures a SCAN, SSCAN, HSCAN or ZSCAN iteration
+type ScanOptions struct {
+	Match string // Glob-style pattern to filter results (empty = everything)
+	Count int    // Hint for how many elements redis examines per page (0 = redis default)
+	Type  string // Only return keys of this type, e.g. "string" or "hash" (Scan only, redis 6+)
+}
+
+// HashEntry is a single field/value pair of a hash
+type HashEntry struct {
+	Field string
+	Value string
+}
+
+// Scan iterates over the keys in the database using a cursor (non-blocking alternative to KEYS)
+// Keys may be returned more than once, and keys changed during the iteration may be missed (see spec)
+// Iteration stops with ctx.Err() once ctx is done, checked before fetching each page
+// Creates a new connection when iteration starts and closes it when iteration ends
+//
+// Custom connections use method: ScanRaw()
+func Scan(ctx context.Context, client *Client, opts ScanOptions) iter.Seq2[string, error] {
+	return scanWithClient(ctx, client, func(conn redis.Conn) iter.Seq2[string, error] {
+		return scanSeq(ctx, conn, ScanCommand, nil, opts, redis.Strings)
+	})
+}
+
+// ScanRaw iterates over the keys in the database using a cursor (non-blocking alternative to KEYS)
+// Keys may be returned more than once, and keys changed during the iteration may be missed (see spec)
+// Uses existing connection (does not close connection)
+//
+// Spec: https://redis.io/commands/scan
+func ScanRaw(conn redis.Conn, opts ScanOptions) iter.Seq2[string, error] {
+	return scanSeq(context.Background(), conn, ScanCommand, nil, opts, redis.Strings)
+}
+
+// SScan iterates over the members of a set using a cursor
+// Iteration stops with ctx.Err() once ctx is done, checked before fetching each page
+// Creates a new connection when iteration starts and closes it when iteration ends
+//
+// Custom connections use method: SScanRaw()
+func SScan(ctx context.Context, client *Client, set string, opts ScanOptions) iter.Seq2[string, error] {
+	return scanWithClient(ctx, client, func(conn redis.Conn) iter.Seq2[string, error] {
+		return scanSeq(ctx, conn, SetScanCommand, set, opts, redis.Strings)
+	})
+}
+
+// SScanRaw iterates over the members of a set using a cursor
+// Uses existing connection (does not close connection)
+//
+// Spec: https://redis.io/commands/sscan
+func SScanRaw(conn redis.Conn, set string, opts ScanOptions) iter.Seq2[string, error] {
+	return scanSeq(context.Background(), conn, SetScanCommand, set, opts, redis.Strings)
+}
+
+// HScan iterates over the fields and values of a hash using a cursor
+// Iteration stops with ctx.Err() once ctx is done, checked before fetching each page
+// Creates a new connection when iteration starts and closes it when iteration ends
+//
+// Custom connections use method: HScanRaw()
+func HScan(ctx context.Context, client *Client, hash string, opts ScanOptions) iter.Seq2[HashEntry, error] {
+	return scanWithClient(ctx, client, func(conn redis.Conn) iter.Seq2[HashEntry, error] {
+		return scanSeq(ctx, conn, HashScanCommand, hash, opts, parseHashEntries)
+	})
+}
+
+// HScanRaw iterates over the fields and values of a hash using a cursor
+// Uses existing connection (does not close connection)
+//
+// Spec: https://redis.io/commands/hscan
+func HScanRaw(conn redis.Conn, hash string, opts ScanOptions) iter.Seq2[HashEntry, error] {
+	return scanSeq(context.Background(), conn, HashScanCommand, hash, opts, parseHashEntries)
+}
+
+// ZScan iterates over the members and scores of a sorted set using a cursor
+// Iteration stops with ctx.Err() once ctx is done, checked before fetching each page
+// Creates a new connection when iteration starts and closes it when iteration ends
+//
+// Custom connections use method: ZScanRaw()
+func ZScan(ctx context.Context, client *Client, key string, opts ScanOptions) iter.Seq2[SortedSetMember, error] {
+	return scanWithClient(ctx, client, func(conn redis.Conn) iter.Seq2[SortedSetMember, error] {
+		return scanSeq(ctx, conn, SortedSetScanCommand, key, opts, parseScanSortedSet)
+	})
+}
+
+// ZScanRaw iterates over the members and scores of a sorted set using a cursor
+// Uses existing connection (does not close connection)
+//
+// Spec: https://redis.io/commands/zscan
+func ZScanRaw(conn redis.Conn, key string, opts ScanOptions) iter.Seq2[SortedSetMember, error] {
+	return scanSeq(context.Background(), conn, SortedSetScanCommand, key, opts, parseScanSortedSet)
+}
+
+// scanWithClient borrows a connection for the duration of a single iteration
+func scanWithClient[T any](ctx context.Context, client *Client,
+	scan func(conn redis.Conn) iter.Seq2[T, error],
+) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		conn, err := client.GetConnectionWithContext(ctx)
+		if err != nil {
+			var zero T
+			yield(zero, err)
+			return
+		}
+		defer client.CloseConnection(conn)
+		scan(conn)(yield)
+	}
+}
+
+// scanSeq walks the cursor of a SCAN-family command and yields every parsed element
+// key is the set/hash/sorted set name, or nil for SCAN
+func scanSeq[T any](ctx context.Context, conn redis.Conn, command string, key interface{},
+	opts ScanOptions, parse func(reply interface{}, err error) ([]T, error),
+) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		cursor := "0"
+		for {
+			if err := ctx.Err(); err != nil {
+				yield(zero, err)
+				return
+			}
+
+			args := make([]interface{}, 0, 8)
+			if key != nil {
+				args = append(args, key)
+			}
+			args = append(args, cursor)
+			if len(opts.Match) > 0 {
+				args = append(args, "MATCH", opts.Match)
+			}
+			if opts.Count > 0 {
+				args = append(args, "COUNT", opts.Count)
+			}
+			if len(opts.Type) > 0 && command == ScanCommand {
+				args = append(args, "TYPE", opts.Type)
+			}
+
+			// Each reply is [next cursor, [elements...]]
+			values, err := redis.Values(conn.Do(command, args...))
+			if err != nil {
+				yield(zero, err)
+				return
+			}
+			var page interface{}
+			if _, err = redis.Scan(values, &cursor, &page); err != nil {
+				yield(zero, err)
+				return
+			}
+			var items []T
+			if items, err = parse(page, nil); err != nil {
+				yield(zero, err)
+				return
+			}
+			for _, item := range items {
+				if !yield(item, nil) {
+					return
+				}
+			}
+
+			// The iteration is complete when redis returns cursor 0
+			if cursor == "0" {
+				return
+			}
+		}
+	}
+}
+
+// parseHashEntries parses the flat alternating [field, value, ...] page returned by HSCAN
+func parseHashEntries(reply interface{}, err error) ([]HashEntry, error) {
+	var values []string
+	if values, err = redis.Strings(reply, err); err != nil {
+		return nil, err
+	}
+	if len(values)%2 != 0 {
+		return nil, fmt.Errorf("%w: odd number of hash elements (%d)", errUnexpectedScanReply, len(values))
+	}
+	entries := make([]HashEntry, 0, len(values)/2)
+	for i := 0; i < len(values); i += 2 {
+		entries = append(entries, HashEntry{Field: values[i], Value: values[i+1]})
+	}
+	return entries, nil
+}
+
+// parseScanSortedSet parses the flat alternating [member, score, ...] page returned by ZSCAN
+func parseScanSortedSet(reply interface{}, err error) ([]SortedSetMember, error) {
+	var values []interface{}
+	if values, err = redis.Values(reply, err); err != nil {
+		return nil, err
+	}
+	return parseSortedSetWithScores(values)
+}
@@ -0,0 +1,252 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scanPage builds a mocked SCAN-family reply
+func scanPage(cursor string, items ...string) []interface{} {
+	page := make([]interface{}, len(items))
+	for i, item := range items {
+		page[i] = []byte(item)
+	}
+	return []interface{}{[]byte(cursor), page}
+}
+
+// TestScan tests the method Scan()
+func TestScan(t *testing.T) {
+	t.Run("iterates over every page", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.Command(ScanCommand, "0").Expect(scanPage("17", "a", "b"))
+		conn.Command(ScanCommand, "17").Expect(scanPage("0", "c"))
+
+		var keys []string
+		for key, err := range Scan(context.Background(), client, ScanOptions{}) {
+			require.NoError(t, err)
+			keys = append(keys, key)
+		}
+		assert.Equal(t, []string{"a", "b", "c"}, keys)
+	})
+
+	t.Run("passes match, count and type", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		cmd := conn.Command(ScanCommand, "0", "MATCH", "user:*", "COUNT", 500, "TYPE", "hash").
+			Expect(scanPage("0", "user:1"))
+
+		var keys []string
+		for key, err := range Scan(context.Background(), client, ScanOptions{Match: "user:*", Count: 500, Type: "hash"}) {
+			require.NoError(t, err)
+			keys = append(keys, key)
+		}
+		assert.True(t, cmd.Called)
+		assert.Equal(t, []string{"user:1"}, keys)
+	})
+
+	t.Run("stops when the loop breaks", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.Command(ScanCommand, "0").Expect(scanPage("17", "a", "b"))
+		next := conn.Command(ScanCommand, "17").Expect(scanPage("0", "c"))
+
+		for range Scan(context.Background(), client, ScanOptions{}) {
+			break
+		}
+		assert.False(t, next.Called)
+	})
+
+	t.Run("stops when the context is canceled", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		conn.Command(ScanCommand, "0").Expect(scanPage("17", "a"))
+		next := conn.Command(ScanCommand, "17").Expect(scanPage("0", "b"))
+
+		var keys []string
+		var lastErr error
+		for key, err := range Scan(ctx, client, ScanOptions{}) {
+			if err != nil {
+				lastErr = err
+				continue
+			}
+			keys = append(keys, key)
+			cancel()
+		}
+		require.ErrorIs(t, lastErr, context.Canceled)
+		assert.Equal(t, []string{"a"}, keys)
+		assert.False(t, next.Called)
+	})
+
+	t.Run("yields command errors", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.Command(ScanCommand, "0").ExpectError(errTestLoader)
+
+		var errs int
+		for _, err := range Scan(context.Background(), client, ScanOptions{}) {
+			require.ErrorIs(t, err, errTestLoader)
+			errs++
+		}
+		assert.Equal(t, 1, errs)
+	})
+
+	t.Run("scan raw", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.Command(ScanCommand, "0", "MATCH", "a*").Expect(scanPage("0", "a", "ab"))
+
+		var keys []string
+		for key, err := range ScanRaw(conn, ScanOptions{Match: "a*"}) {
+			require.NoError(t, err)
+			keys = append(keys, key)
+		}
+		assert.Equal(t, []string{"a", "ab"}, keys)
+	})
+
+	t.Run("scan using real redis", func(t *testing.T) {
+		if testing.Short() {
+			t.Skip("skipping live local redis tests")
+		}
+
+		client, conn, err := loadRealRedis(t)
+		require.NoError(t, err)
+		defer client.CloseAll(conn)
+		require.NoError(t, clearRealRedis(conn))
+
+		for i := 0; i < 50; i++ {
+			require.NoError(t, SetRaw(conn, fmt.Sprintf("scan:%d", i), i))
+		}
+		require.NoError(t, SetAddRaw(conn, "scan:set", "member"))
+
+		seen := make(map[string]bool)
+		for key, err := range Scan(context.Background(), client, ScanOptions{Match: "scan:*", Count: 10, Type: "string"}) {
+			require.NoError(t, err)
+			seen[key] = true
+		}
+		assert.Len(t, seen, 50)
+		assert.False(t, seen["scan:set"])
+	})
+}
+
+// TestSScan tests the methods SScan() and SScanRaw()
+func TestSScan(t *testing.T) {
+	client, conn := loadMockRedis(t)
+	defer client.CloseAll(conn)
+
+	conn.Command(SetScanCommand, testKey, "0", "COUNT", 2).Expect(scanPage("3", "a", "b"))
+	conn.Command(SetScanCommand, testKey, "3", "COUNT", 2).Expect(scanPage("0", "c"))
+
+	var members []string
+	for member, err := range SScan(context.Background(), client, testKey, ScanOptions{Count: 2}) {
+		require.NoError(t, err)
+		members = append(members, member)
+	}
+	assert.Equal(t, []string{"a", "b", "c"}, members)
+
+	// Type is only sent with SCAN
+	members = nil
+	for member, err := range SScanRaw(conn, testKey, ScanOptions{Count: 2, Type: "string"}) {
+		require.NoError(t, err)
+		members = append(members, member)
+	}
+	assert.Equal(t, []string{"a", "b", "c"}, members)
+}
+
+// TestHScan tests the methods HScan() and HScanRaw()
+func TestHScan(t *testing.T) {
+	t.Run("yields field/value pairs", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.Command(HashScanCommand, testKey, "0").Expect(scanPage("0", "f1", "v1", "f2", "v2"))
+
+		var entries []HashEntry
+		for entry, err := range HScan(context.Background(), client, testKey, ScanOptions{}) {
+			require.NoError(t, err)
+			entries = append(entries, entry)
+		}
+		assert.Equal(t, []HashEntry{{Field: "f1", Value: "v1"}, {Field: "f2", Value: "v2"}}, entries)
+	})
+
+	t.Run("odd reply is an error", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.Command(HashScanCommand, testKey, "0").Expect(scanPage("0", "f1"))
+
+		var errs int
+		for _, err := range HScanRaw(conn, testKey, ScanOptions{}) {
+			require.ErrorIs(t, err, errUnexpectedScanReply)
+			require.NotErrorIs(t, err, redis.ErrNil, "a malformed reply is not a missing key")
+			errs++
+		}
+		assert.Equal(t, 1, errs)
+	})
+}
+
+// TestZScan tests the methods ZScan() and ZScanRaw()
+func TestZScan(t *testing.T) {
+	t.Run("yields members with scores", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.Command(SortedSetScanCommand, testKey, "0", "MATCH", "m*").Expect(scanPage("0", "m1", "1.5", "m2", "3"))
+
+		var members []SortedSetMember
+		for member, err := range ZScan(context.Background(), client, testKey, ScanOptions{Match: "m*"}) {
+			require.NoError(t, err)
+			members = append(members, member)
+		}
+		assert.Equal(t, []SortedSetMember{{Member: "m1", Score: 1.5}, {Member: "m2", Score: 3}}, members)
+	})
+
+	t.Run("bad score is an error", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.Command(SortedSetScanCommand, testKey, "0").Expect(scanPage("0", "m1", "not-a-score"))
+
+		var errs int
+		for _, err := range ZScanRaw(conn, testKey, ScanOptions{}) {
+			require.Error(t, err)
+			errs++
+		}
+		assert.Equal(t, 1, errs)
+	})
+}
+
+// ExampleScan is an example of the method Scan()
+func ExampleScan() {
+	// Load a mocked redis for testing/examples
+	client, conn := loadMockRedis()
+
+	// Close connections at end of request
+	defer client.CloseAll(conn)
+
+	// Mock the SCAN reply
+	conn.Command(ScanCommand, "0", "MATCH", "user:*").Expect(scanPage("0", "user:1"))
+
+	// Iterate over the matching keys
+	for key, err := range Scan(context.Background(), client, ScanOptions{Match: "user:*"}) {
+		if err != nil {
+			return
+		}
+		fmt.Printf("found key: %s", key)
+	}
+	// Output:found key: user:1
+}
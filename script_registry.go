@@ -0,0 +1,270 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// Define static errors to avoid dynamic error creation
+var (
+	ErrScriptNotRegistered = errors.New("script is not registered")
+	ErrScriptNameTaken     = errors.New("a different script is already registered under this name")
+	ErrScriptKeyCount      = errors.New("wrong number of keys for script")
+	errMissingRunID        = errors.New("run_id not found in INFO server reply")
+)
+
+// namedScript is a script registered with RegisterNamedScript()
+type namedScript struct {
+	name     string
+	keyCount int
+	sha      string
+	src      string
+}
+
+// scriptRegistry holds the named scripts and which servers they are loaded on
+type scriptRegistry struct {
+	mu      sync.RWMutex
+	scripts map[string]*namedScript
+	servers map[string]map[string]bool // server run_id -> names of the scripts loaded there
+}
+
+// RegisterNamedScript adds a Lua script to the client's registry under the name
+// keyCount is the number of KEYS the script takes (checked by RunScript)
+//
+// Nothing is sent to redis here. Scripts are loaded when a new pooled connection reaches
+// a server that does not have them (e.g. after a restart), or on NOSCRIPT in RunScript().
+// Registering the same source under the same name again is a no-op.
+func (c *Client) RegisterNamedScript(name string, keyCount int, src string) error {
+	r := c.scriptRegistry()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if existing, ok := r.scripts[name]; ok {
+		if existing.src != src || existing.keyCount != keyCount {
+			return ErrScriptNameTaken
+		}
+		return nil
+	}
+	r.scripts[name] = &namedScript{name: name, keyCount: keyCount, sha: redis.NewScript(keyCount, src).Hash(), src: src}
+	return nil
+}
+
+// LoadedScripts returns the names of the registered scripts known to be loaded, by server
+// Servers are identified by their run_id (which changes when redis restarts)
+func (c *Client) LoadedScripts() map[string][]string {
+	r := c.scriptRegistry()
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	loaded := make(map[string][]string, len(r.servers))
+	for runID, names := range r.servers {
+		for name := range names {
+			loaded[runID] = append(loaded[runID], name)
+		}
+		slices.Sort(loaded[runID])
+	}
+	return loaded
+}
+
+// RunScript runs a script registered with RegisterNamedScript()
+// If the server does not have the script (NOSCRIPT), every registered script is loaded and
+// the call is retried once
+// Creates a new connection and closes connection at end of function call
+//
+// Custom connections use method: RunScriptRaw()
+func RunScript(ctx context.Context, client *Client, name string, keys []string,
+	args ...interface{},
+) (interface{}, error) {
+	conn, err := client.GetConnectionWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer client.CloseConnection(conn)
+	return RunScriptRaw(client, conn, name, keys, args...)
+}
+
+// RunScriptRaw runs a script registered with RegisterNamedScript()
+// Uses existing connection (does not close connection)
+//
+// Commands used:
+// https://redis.io/commands/evalsha
+// https://redis.io/commands/script-load
+func RunScriptRaw(client *Client, conn redis.Conn, name string, keys []string,
+	args ...interface{},
+) (interface{}, error) {
+	r := client.scriptRegistry()
+	r.mu.RLock()
+	script, ok := r.scripts[name]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, ErrScriptNotRegistered
+	}
+	if len(keys) != script.keyCount {
+		return nil, ErrScriptKeyCount
+	}
+
+	evalArgs := make([]interface{}, 0, 2+len(keys)+len(args))
+	evalArgs = append(evalArgs, script.sha, script.keyCount)
+	evalArgs = append(evalArgs, toInterfaces(keys)...)
+	evalArgs = append(evalArgs, args...)
+
+	reply, err := conn.Do(EvalCommand, evalArgs...)
+	if !isNoScript(err) {
+		return reply, err
+	}
+
+	// The server lost its script cache (restart, failover or SCRIPT FLUSH)
+	if err = r.reload(conn); err != nil {
+		return nil, err
+	}
+	return conn.Do(EvalCommand, evalArgs...)
+}
+
+// scriptRegistry returns the registry (shared by namespaced clients), creating it on first use
+func (c *Client) scriptRegistry() *scriptRegistry {
+	root := c.root()
+	root.mu.Lock()
+	defer root.mu.Unlock()
+	if root.scripts == nil {
+		root.scripts = &scriptRegistry{
+			scripts: make(map[string]*namedScript),
+			servers: make(map[string]map[string]bool),
+		}
+	}
+	return root.scripts
+}
+
+// dialWithScripts wraps a pool dialer so new connections load the named scripts
+// their server is missing. Failures are ignored: RunScript() recovers on NOSCRIPT.
+// The registry is resolved up front: dialing happens while the client's lock is held.
+func (c *Client) dialWithScripts(dial func() (redis.Conn, error)) func() (redis.Conn, error) {
+	r := c.scriptRegistry()
+	return func() (redis.Conn, error) {
+		conn, err := dial()
+		if err == nil {
+			_ = r.sync(conn)
+		}
+		return conn, err
+	}
+}
+
+// sync loads the registered scripts that the connection's server does not have yet
+//
+// Commands used:
+// https://redis.io/commands/info
+// https://redis.io/commands/script-exists
+// https://redis.io/commands/script-load
+func (r *scriptRegistry) sync(conn redis.Conn) error {
+	r.mu.RLock()
+	empty := len(r.scripts) == 0
+	r.mu.RUnlock()
+	if empty {
+		return nil
+	}
+
+	runID, err := serverRunID(conn)
+	if err != nil {
+		return err
+	}
+	missing := r.missing(runID)
+	if len(missing) == 0 {
+		return nil
+	}
+
+	args := make([]interface{}, 0, len(missing)+1)
+	args = append(args, ExistsCommand)
+	for _, script := range missing {
+		args = append(args, script.sha)
+	}
+	var exists []int
+	if exists, err = redis.Ints(conn.Do(ScriptCommand, args...)); err != nil {
+		return err
+	}
+	for i, script := range missing {
+		if i < len(exists) && exists[i] == 1 {
+			continue
+		}
+		if _, err = conn.Do(ScriptCommand, LoadCommand, script.src); err != nil {
+			return err
+		}
+	}
+	r.markLoaded(runID, missing)
+	return nil
+}
+
+// reload loads every registered script on the connection's server
+func (r *scriptRegistry) reload(conn redis.Conn) error {
+	scripts := r.all()
+
+	for _, script := range scripts {
+		if _, err := conn.Do(ScriptCommand, LoadCommand, script.src); err != nil {
+			return err
+		}
+	}
+
+	// Knowing the server is only needed for LoadedScripts(), so this is best-effort
+	if runID, err := serverRunID(conn); err == nil {
+		r.markLoaded(runID, scripts)
+	}
+	return nil
+}
+
+// all returns every registered script (by name)
+func (r *scriptRegistry) all() []*namedScript {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	scripts := make([]*namedScript, 0, len(r.scripts))
+	for _, script := range r.scripts {
+		scripts = append(scripts, script)
+	}
+	slices.SortFunc(scripts, func(a, b *namedScript) int { return strings.Compare(a.name, b.name) })
+	return scripts
+}
+
+// missing returns the registered scripts not known to be loaded on the server (by name)
+func (r *scriptRegistry) missing(runID string) []*namedScript {
+	scripts := r.all()
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return slices.DeleteFunc(scripts, func(script *namedScript) bool {
+		return r.servers[runID][script.name]
+	})
+}
+
+// markLoaded records the scripts as loaded on the server
+func (r *scriptRegistry) markLoaded(runID string, scripts []*namedScript) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.servers[runID] == nil {
+		r.servers[runID] = make(map[string]bool)
+	}
+	for _, script := range scripts {
+		r.servers[runID][script.name] = true
+	}
+}
+
+// serverRunID returns the run_id of the connection's server
+//
+// Spec: https://redis.io/commands/info
+func serverRunID(conn redis.Conn) (string, error) {
+	info, err := redis.String(conn.Do(InfoCommand, "server"))
+	if err != nil {
+		return "", err
+	}
+	scanner := bufio.NewScanner(strings.NewReader(info))
+	for scanner.Scan() {
+		if runID, ok := strings.CutPrefix(strings.TrimSpace(scanner.Text()), "run_id:"); ok {
+			return runID, nil
+		}
+	}
+	return "", errMissingRunID
+}
+
+// isNoScript reports whether redis does not have the script (EVALSHA)
+func isNoScript(err error) bool {
+	return err != nil && strings.HasPrefix(err.Error(), "NOSCRIPT")
+}
@@ -0,0 +1,211 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testScriptGet   = `return redis.call("GET", KEYS[1])`
+	testScriptIncr  = `return redis.call("INCRBY", KEYS[1], ARGV[1])`
+	testServerInfo  = "# Server\r\nredis_version:7.2.0\r\nrun_id:test-run-id\r\n"
+	testServerRunID = "test-run-id"
+)
+
+// TestRegisterNamedScript tests the method RegisterNamedScript()
+func TestRegisterNamedScript(t *testing.T) {
+	client, conn := loadMockRedis(t)
+	defer client.CloseAll(conn)
+
+	require.NoError(t, client.RegisterNamedScript("get", 1, testScriptGet))
+	require.NoError(t, client.RegisterNamedScript("get", 1, testScriptGet))
+	require.ErrorIs(t, client.RegisterNamedScript("get", 1, testScriptIncr), ErrScriptNameTaken)
+	require.ErrorIs(t, client.RegisterNamedScript("get", 2, testScriptGet), ErrScriptNameTaken)
+
+	// Namespaced clients share the registry
+	ns := client.WithNamespace(testNamespace)
+	require.ErrorIs(t, ns.RegisterNamedScript("get", 1, testScriptIncr), ErrScriptNameTaken)
+	assert.Empty(t, client.LoadedScripts())
+}
+
+// TestRunScript tests the methods RunScript() and RunScriptRaw()
+func TestRunScript(t *testing.T) {
+	t.Run("runs by sha", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+		require.NoError(t, client.RegisterNamedScript("incr", 1, testScriptIncr))
+
+		evalCmd := conn.Command(EvalCommand, redis.NewScript(1, testScriptIncr).Hash(), 1, testKey, 5).Expect(int64(5))
+
+		reply, err := redis.Int(RunScript(context.Background(), client, "incr", []string{testKey}, 5))
+		require.NoError(t, err)
+		assert.Equal(t, 5, reply)
+		assert.True(t, evalCmd.Called)
+	})
+
+	t.Run("reloads every script on NOSCRIPT", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+		require.NoError(t, client.RegisterNamedScript("get", 1, testScriptGet))
+		require.NoError(t, client.RegisterNamedScript("incr", 1, testScriptIncr))
+
+		evalCmd := conn.Command(EvalCommand, redis.NewScript(1, testScriptGet).Hash(), 1, testKey).
+			ExpectError(redis.Error("NOSCRIPT No matching script. Please use EVAL.")).
+			Expect([]byte(testStringValue))
+		getLoad := conn.Command(ScriptCommand, LoadCommand, testScriptGet).Expect([]byte("sha"))
+		incrLoad := conn.Command(ScriptCommand, LoadCommand, testScriptIncr).Expect([]byte("sha"))
+		conn.Command(InfoCommand, "server").Expect([]byte(testServerInfo))
+
+		reply, err := redis.String(RunScript(context.Background(), client, "get", []string{testKey}))
+		require.NoError(t, err)
+		assert.Equal(t, testStringValue, reply)
+		assert.Equal(t, 2, conn.Stats(evalCmd))
+		assert.True(t, getLoad.Called)
+		assert.True(t, incrLoad.Called)
+		assert.Equal(t, map[string][]string{testServerRunID: {"get", "incr"}}, client.LoadedScripts())
+	})
+
+	t.Run("load error", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+		require.NoError(t, client.RegisterNamedScript("get", 1, testScriptGet))
+
+		conn.Command(EvalCommand, redis.NewScript(1, testScriptGet).Hash(), 1, testKey).
+			ExpectError(redis.Error("NOSCRIPT No matching script. Please use EVAL."))
+		conn.Command(ScriptCommand, LoadCommand, testScriptGet).ExpectError(errTestLoader)
+
+		_, err := RunScriptRaw(client, conn, "get", []string{testKey})
+		require.ErrorIs(t, err, errTestLoader)
+	})
+
+	t.Run("not registered", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		_, err := RunScriptRaw(client, conn, "missing", nil)
+		require.ErrorIs(t, err, ErrScriptNotRegistered)
+	})
+
+	t.Run("wrong number of keys", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+		require.NoError(t, client.RegisterNamedScript("get", 1, testScriptGet))
+
+		_, err := RunScriptRaw(client, conn, "get", []string{"a", "b"})
+		require.ErrorIs(t, err, ErrScriptKeyCount)
+	})
+
+	t.Run("run script using real redis", func(t *testing.T) {
+		if testing.Short() {
+			t.Skip("skipping live local redis tests")
+		}
+
+		client, conn, err := loadRealRedis(t)
+		require.NoError(t, err)
+		defer client.CloseAll(conn)
+		require.NoError(t, clearRealRedis(conn))
+		require.NoError(t, client.RegisterNamedScript("incr", 1, testScriptIncr))
+
+		// Start without any scripts on the server
+		_, err = conn.Do(ScriptCommand, "FLUSH")
+		require.NoError(t, err)
+
+		var total int
+		total, err = redis.Int(RunScript(context.Background(), client, "incr", []string{testKey}, 2))
+		require.NoError(t, err)
+		assert.Equal(t, 2, total)
+		assert.Len(t, client.LoadedScripts(), 1)
+	})
+}
+
+// TestDialWithScripts tests loading the named scripts on new connections
+func TestDialWithScripts(t *testing.T) {
+	t.Run("loads the scripts the server is missing", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+		require.NoError(t, client.RegisterNamedScript("get", 1, testScriptGet))
+		require.NoError(t, client.RegisterNamedScript("incr", 1, testScriptIncr))
+
+		infoCmd := conn.Command(InfoCommand, "server").Expect([]byte(testServerInfo))
+		existsCmd := conn.Command(ScriptCommand, ExistsCommand,
+			redis.NewScript(1, testScriptGet).Hash(), redis.NewScript(1, testScriptIncr).Hash()).
+			Expect([]interface{}{int64(1), int64(0)})
+		getLoad := conn.Command(ScriptCommand, LoadCommand, testScriptGet).Expect([]byte("sha"))
+		incrLoad := conn.Command(ScriptCommand, LoadCommand, testScriptIncr).Expect([]byte("sha"))
+
+		dial := client.dialWithScripts(func() (redis.Conn, error) { return conn, nil })
+		_, err := dial()
+		require.NoError(t, err)
+		assert.False(t, getLoad.Called)
+		assert.True(t, incrLoad.Called)
+
+		// The server is known now, so the next connection only asks who it is
+		_, err = dial()
+		require.NoError(t, err)
+		assert.Equal(t, 2, conn.Stats(infoCmd))
+		assert.Equal(t, 1, conn.Stats(existsCmd))
+		assert.Equal(t, map[string][]string{testServerRunID: {"get", "incr"}}, client.LoadedScripts())
+	})
+
+	t.Run("nothing registered", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		infoCmd := conn.Command(InfoCommand, "server").Expect([]byte(testServerInfo))
+
+		dial := client.dialWithScripts(func() (redis.Conn, error) { return conn, nil })
+		_, err := dial()
+		require.NoError(t, err)
+		assert.False(t, infoCmd.Called)
+	})
+
+	t.Run("dial error", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		dial := client.dialWithScripts(func() (redis.Conn, error) { return nil, errTestLoader })
+		_, err := dial()
+		require.ErrorIs(t, err, errTestLoader)
+	})
+}
+
+// TestServerRunID tests parsing the run_id from INFO server
+func TestServerRunID(t *testing.T) {
+	client, conn := loadMockRedis(t)
+	defer client.CloseAll(conn)
+
+	conn.Command(InfoCommand, "server").Expect([]byte(testServerInfo))
+	runID, err := serverRunID(conn)
+	require.NoError(t, err)
+	assert.Equal(t, testServerRunID, runID)
+
+	conn.Clear()
+	conn.Command(InfoCommand, "server").Expect([]byte("# Server\r\n"))
+	_, err = serverRunID(conn)
+	require.ErrorIs(t, err, errMissingRunID)
+}
+
+// ExampleRunScript is an example of the method RunScript()
+func ExampleRunScript() {
+	// Load a mocked redis for testing/examples
+	client, conn := loadMockRedis()
+
+	// Close connections at end of request
+	defer client.CloseAll(conn)
+
+	// Register the script once (usually at startup)
+	_ = client.RegisterNamedScript("incr", 1, testScriptIncr)
+
+	// Mock the reply
+	conn.Command(EvalCommand, redis.NewScript(1, testScriptIncr).Hash(), 1, testKey, 2).Expect(int64(2))
+
+	// Run the script by name
+	total, _ := redis.Int(RunScript(context.Background(), client, "incr", []string{testKey}, 2))
+	fmt.Printf("total: %d", total)
+	// Output:total: 2
+}
@@ -17,6 +17,9 @@ func (c *Client) RegisterScripts(ctx context.Context) (err error) {
 	}
 	defer c.CloseConnection(conn)
 
+	// Prefer the function library (redis 7+); older servers keep using the scripts
+	_ = c.LoadFunctions(ctx)
+
 	// Load dependency script if not loaded
 	if len(c.DependencyScriptSha) == 0 {
 		c.DependencyScriptSha, err = RegisterScript(ctx, c, killByDependencyLua)
@@ -51,24 +54,44 @@ func RegisterScriptRaw(client *Client, conn redis.Conn, script string) (sha stri
 	return sha, err
 }
 
-// killByDependencySha is the SHA of the below script
-const killByDependencySha = "a648f768f57e73e2497ccaa113d5ad9e731c5cd8"
+// killByDependencyScript runs killByDependencyLua (or the library function when loaded)
+var killByDependencyScript = shippedScript{
+	function: killByDependencyFunction,
+	script:   redis.NewScript(-1, killByDependencyLua),
+}
+
+// killByDependencySha is the SHA of the below script (as loaded by RegisterScripts)
+var killByDependencySha = killByDependencyScript.script.Hash()
 
 // killByDependencyLua is a script for kill related dependencies
-//
-// Editing this script requires a new SHA above
+// KEYS are the dependency sets, ARGV the keys they belong to (optional): the reverse dependency
+// sets of those keys and of the deleted members are removed as well (not counted). The namespace
+// (if any) is what precedes the dependency prefix and the key in KEYS.
 const killByDependencyLua = `
 --@begin=lua@
-redis.replicate_commands()
-local number_of_keys = table.getn(ARGV)
+redis.replicate_commands()` + killByDependencyBody + `--@end=lua@
+`
+
+// killByDependencyBody is killByDependencyLua without the script-only parts (shared with the function library)
+const killByDependencyBody = `
 local all_keys = {}
-for _, key in ipairs(ARGV) do
+local reverse_keys = {}
+for i, key in ipairs(KEYS) do
 	table.insert(all_keys, key)
 	local set = redis.call("` + MembersCommand + `", key)
 	for _, v in ipairs(set) do
 	  table.insert(all_keys, v)
 	end
+	if ARGV[i] then
+		local namespace = string.sub(key, 1, #key - #ARGV[i] - #"` + DependencyPrefix + `")
+		table.insert(reverse_keys, namespace .. "` + ReverseDependencyPrefix + `" .. ARGV[i])
+		for _, v in ipairs(set) do
+			table.insert(reverse_keys, namespace .. "` + ReverseDependencyPrefix + `" .. string.sub(v, #namespace + 1))
+		end
+	end
+end
+if #reverse_keys > 0 then
+	redis.call("` + DeleteCommand + `", unpack(reverse_keys))
 end
 return redis.call("` + DeleteCommand + `", unpack(all_keys))
---@end=lua@
 `
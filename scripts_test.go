@@ -196,5 +196,5 @@ func ExampleRegisterScript() {
 	_, _ = RegisterScript(context.Background(), client, killByDependencyLua)
 
 	fmt.Printf("registered: %s", testKillDependencyHash)
-	// Output:registered: a648f768f57e73e2497ccaa113d5ad9e731c5cd8
+	// Output:registered: 62ffb9bb8e146d0048fe2c00b624e0fee9cffdb7
 }
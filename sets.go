@@ -48,7 +48,34 @@ func SetAddMany(ctx context.Context, client *Client, setName string, members ...
 // Uses existing connection (does not close connection)
 //
 // Spec: https://redis.io/commands/sadd
-func SetAddManyRaw(conn redis.Conn, setName string, members ...interface{}) (err error) {
+func SetAddManyRaw(conn redis.Conn, setName string, members ...interface{}) error {
+	return SetAddManyWithDependenciesRaw(conn, setName, members)
+}
+
+// SetAddManyWithDependencies will add many values to a set and link a reference to each
+// dependency for the entire Set
+// Creates a new connection and closes connection at end of function call
+//
+// Custom connections use method: SetAddManyWithDependenciesRaw()
+func SetAddManyWithDependencies(ctx context.Context, client *Client, setName string,
+	members []interface{}, dependencies ...string,
+) error {
+	conn, err := client.GetConnectionWithContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.CloseConnection(conn)
+	return SetAddManyWithDependenciesRaw(conn, setName, members, dependencies...)
+}
+
+// SetAddManyWithDependenciesRaw will add many values to a set and link a reference to each
+// dependency for the entire Set
+// Uses existing connection (does not close connection)
+//
+// Spec: https://redis.io/commands/sadd
+func SetAddManyWithDependenciesRaw(conn redis.Conn, setName string, members []interface{},
+	dependencies ...string,
+) error {
 	// Create the arguments
 	args := make([]interface{}, len(members)+1)
 	args[0] = setName
@@ -58,9 +85,13 @@ func SetAddManyRaw(conn redis.Conn, setName string, members ...interface{}) (err
 		args[i+1] = key
 	}
 
-	// Fire the delete command
-	_, err = conn.Do(AddToSetCommand, args...)
-	return err
+	// Fire the add command
+	if _, err := conn.Do(AddToSetCommand, args...); err != nil {
+		return err
+	}
+
+	// Link and return the error
+	return linkDependencies(conn, setName, dependencies...)
 }
 
 // SetIsMember returns if the member is part of the set
@@ -23,6 +23,7 @@ func FuzzSetAdd(f *testing.F) {
 
 		conn.Command(AddToSetCommand, setName, member).Expect(int64(1))
 		conn.Command(AddToSetCommand, DependencyPrefix+dependency, setName).Expect(int64(1))
+		mockReverseDependencies(conn, setName, dependency).Expect(int64(1))
 		conn.Command(MultiCommand).Expect("QUEUED")
 		conn.Command(ExecuteCommand).Expect([]interface{}{int64(1)})
 
@@ -250,6 +251,7 @@ func FuzzSetAddWithDependencies(f *testing.F) {
 		if dep2 != "" && dep2 != dep1 {
 			dependencies = append(dependencies, dep2)
 		}
+		mockReverseDependencies(conn, setName, dependencies...).Expect(int64(len(dependencies)))
 
 		assert.NotPanics(t, func() {
 			err := SetAdd(ctx, client, setName, member, dependencies...)
@@ -47,6 +47,7 @@ func TestSetAdd(t *testing.T) {
 					for _, dep := range test.dependencies {
 						commands = append(commands, conn.Command(AddToSetCommand, DependencyPrefix+dep, test.setName))
 					}
+					commands = append(commands, mockReverseDependencies(conn, test.setName, test.dependencies...))
 					commands = append(commands, conn.Command(ExecuteCommand))
 
 					err := SetAddRaw(conn, test.setName, test.member, test.dependencies...)
@@ -198,6 +199,47 @@ func ExampleSetAddMany() {
 	// Output:found member: test-string-value2
 }
 
+// TestSetAddManyWithDependencies tests the method SetAddManyWithDependencies()
+func TestSetAddManyWithDependencies(t *testing.T) {
+	t.Run("links the set using mocked redis", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		addCmd := conn.Command(AddToSetCommand, testKey, "one", "two")
+		commands := mockLinkDependencies(conn, testKey, testDependantKey)
+
+		err := SetAddManyWithDependencies(context.Background(), client, testKey,
+			[]interface{}{"one", "two"}, testDependantKey)
+		require.NoError(t, err)
+		assert.True(t, addCmd.Called)
+		for _, c := range commands {
+			assert.True(t, c.Called)
+		}
+	})
+
+	t.Run("every data type killed by one dependency using real redis", func(t *testing.T) {
+		if testing.Short() {
+			t.Skip("skipping live local redis tests")
+		}
+
+		client, conn, err := loadRealRedis(t)
+		require.NoError(t, err)
+		defer client.CloseAll(conn)
+		require.NoError(t, clearRealRedis(conn, t))
+
+		require.NoError(t, SetAddManyWithDependenciesRaw(conn, "tags", []interface{}{"a", "b"}, "user"))
+		require.NoError(t, SortedSetAddRaw(conn, "scores", 1, "a", "user"))
+		require.NoError(t, SetListRaw(conn, "recent", []string{"a"}, "user"))
+		_, err = StreamAddRaw(conn, "activity", map[string]string{"event": "login"}, "user")
+		require.NoError(t, err)
+
+		var total int
+		total, err = KillByDependencyRaw(conn, "user")
+		require.NoError(t, err)
+		assert.Equal(t, 5, total) // four keys and the dependency set
+	})
+}
+
 // TestSetRemoveMember test the method SetRemoveMember()
 func TestSetRemoveMember(t *testing.T) {
 	t.Run("set remove member command using mocked redis", func(t *testing.T) {
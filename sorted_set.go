@@ -42,26 +42,34 @@ func parseSortedSetWithScores(values []interface{}) ([]SortedSetMember, error) {
 	return members, nil
 }
 
-// SortedSetAdd adds a single member with a score to a sorted set
+// SortedSetAdd adds a single member with a score to a sorted set and links a reference to
+// each dependency for the entire sorted set
 // Creates a new connection and closes connection at end of function call
 //
 // Custom connections use method: SortedSetAddRaw()
-func SortedSetAdd(ctx context.Context, client *Client, key string, score float64, member interface{}) error {
+func SortedSetAdd(ctx context.Context, client *Client, key string, score float64, member interface{},
+	dependencies ...string,
+) error {
 	conn, err := client.GetConnectionWithContext(ctx)
 	if err != nil {
 		return err
 	}
 	defer client.CloseConnection(conn)
-	return SortedSetAddRaw(conn, key, score, member)
+	return SortedSetAddRaw(conn, key, score, member, dependencies...)
 }
 
-// SortedSetAddRaw adds a single member with a score to a sorted set
+// SortedSetAddRaw adds a single member with a score to a sorted set and links a reference to
+// each dependency for the entire sorted set
 // Uses existing connection (does not close connection)
 //
 // Spec: https://redis.io/commands/zadd
-func SortedSetAddRaw(conn redis.Conn, key string, score float64, member interface{}) (err error) {
-	_, err = conn.Do(SortedSetAddCommand, key, score, member)
-	return err
+func SortedSetAddRaw(conn redis.Conn, key string, score float64, member interface{},
+	dependencies ...string,
+) error {
+	if _, err := conn.Do(SortedSetAddCommand, key, score, member); err != nil {
+		return err
+	}
+	return linkDependencies(conn, key, dependencies...)
 }
 
 // SortedSetAddMany adds multiple members with scores to a sorted set
@@ -81,14 +89,43 @@ func SortedSetAddMany(ctx context.Context, client *Client, key string, members .
 // Uses existing connection (does not close connection)
 //
 // Spec: https://redis.io/commands/zadd
-func SortedSetAddManyRaw(conn redis.Conn, key string, members ...SortedSetMember) (err error) {
+func SortedSetAddManyRaw(conn redis.Conn, key string, members ...SortedSetMember) error {
+	return SortedSetAddManyWithDependenciesRaw(conn, key, members)
+}
+
+// SortedSetAddManyWithDependencies adds multiple members with scores to a sorted set and links
+// a reference to each dependency for the entire sorted set (e.g. a leaderboard)
+// Creates a new connection and closes connection at end of function call
+//
+// Custom connections use method: SortedSetAddManyWithDependenciesRaw()
+func SortedSetAddManyWithDependencies(ctx context.Context, client *Client, key string,
+	members []SortedSetMember, dependencies ...string,
+) error {
+	conn, err := client.GetConnectionWithContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.CloseConnection(conn)
+	return SortedSetAddManyWithDependenciesRaw(conn, key, members, dependencies...)
+}
+
+// SortedSetAddManyWithDependenciesRaw adds multiple members with scores to a sorted set and
+// links a reference to each dependency for the entire sorted set
+// Uses existing connection (does not close connection)
+//
+// Spec: https://redis.io/commands/zadd
+func SortedSetAddManyWithDependenciesRaw(conn redis.Conn, key string, members []SortedSetMember,
+	dependencies ...string,
+) error {
 	args := make([]interface{}, 0, 1+2*len(members))
 	args = append(args, key)
 	for _, m := range members {
 		args = append(args, m.Score, m.Member)
 	}
-	_, err = conn.Do(SortedSetAddCommand, args...)
-	return err
+	if _, err := conn.Do(SortedSetAddCommand, args...); err != nil {
+		return err
+	}
+	return linkDependencies(conn, key, dependencies...)
 }
 
 // SortedSetRemove removes a member from a sorted set
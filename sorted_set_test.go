@@ -59,6 +59,33 @@ func TestSortedSetAdd(t *testing.T) {
 		require.NoError(t, err)
 	})
 
+	t.Run("sorted set add with dependencies using mocked redis", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		addCmd := conn.Command(SortedSetAddCommand, testKey, 1.0, testStringValue)
+		commands := mockLinkDependencies(conn, testKey, testDependantKey)
+
+		err := SortedSetAdd(context.Background(), client, testKey, 1.0, testStringValue, testDependantKey)
+		require.NoError(t, err)
+		assert.True(t, addCmd.Called)
+		for _, c := range commands {
+			assert.True(t, c.Called)
+		}
+	})
+
+	t.Run("sorted set add error skips dependencies using mocked redis", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.Command(SortedSetAddCommand, testKey, 1.0, testStringValue).ExpectError(errTestLoader)
+		multiCmd := conn.GenericCommand(MultiCommand)
+
+		err := SortedSetAddRaw(conn, testKey, 1.0, testStringValue, testDependantKey)
+		require.ErrorIs(t, err, errTestLoader)
+		assert.False(t, multiCmd.Called)
+	})
+
 	t.Run("sorted set add command using real redis", func(t *testing.T) {
 		if testing.Short() {
 			t.Skip("skipping live local redis tests")
@@ -189,6 +216,47 @@ func TestSortedSetAddMany(t *testing.T) {
 	})
 }
 
+// TestSortedSetAddManyWithDependencies tests the method SortedSetAddManyWithDependencies()
+func TestSortedSetAddManyWithDependencies(t *testing.T) {
+	t.Run("links the sorted set using mocked redis", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		addCmd := conn.Command(SortedSetAddCommand, "leaderboard", 10.0, "alice", 5.0, "bob")
+		commands := mockLinkDependencies(conn, "leaderboard", "game", "season")
+
+		members := []SortedSetMember{{Member: "alice", Score: 10}, {Member: "bob", Score: 5}}
+		err := SortedSetAddManyWithDependencies(context.Background(), client, "leaderboard", members, "game", "season")
+		require.NoError(t, err)
+		assert.True(t, addCmd.Called)
+		for _, c := range commands {
+			assert.True(t, c.Called)
+		}
+	})
+
+	t.Run("killed by dependency using real redis", func(t *testing.T) {
+		if testing.Short() {
+			t.Skip("skipping live local redis tests")
+		}
+
+		client, conn, err := loadRealRedis(t)
+		require.NoError(t, err)
+		defer client.CloseAll(conn)
+		require.NoError(t, clearRealRedis(conn, t))
+
+		members := []SortedSetMember{{Member: "alice", Score: 10}}
+		require.NoError(t, SortedSetAddManyWithDependenciesRaw(conn, "leaderboard", members, "game"))
+
+		_, err = KillByDependencyRaw(conn, "game")
+		require.NoError(t, err)
+
+		var found bool
+		found, err = ExistsRaw(conn, "leaderboard")
+		require.NoError(t, err)
+		assert.False(t, found)
+	})
+}
+
 // TestSortedSetRemove tests the method SortedSetRemove()
 func TestSortedSetRemove(t *testing.T) {
 	t.Run("sorted set remove command using mocked redis", func(t *testing.T) {
@@ -65,56 +65,75 @@ func parseStreamEntries(values []interface{}) ([]StreamEntry, error) {
 	return entries, nil
 }
 
-// StreamAdd appends an entry with an auto-generated ID to a stream
+// StreamAdd appends an entry with an auto-generated ID to a stream and links a reference to
+// each dependency for the entire stream
 // Creates a new connection and closes connection at end of function call
 //
 // Custom connections use method: StreamAddRaw()
-func StreamAdd(ctx context.Context, client *Client, key string, fields map[string]string) (string, error) {
+func StreamAdd(ctx context.Context, client *Client, key string, fields map[string]string,
+	dependencies ...string,
+) (string, error) {
 	conn, err := client.GetConnectionWithContext(ctx)
 	if err != nil {
 		return "", err
 	}
 	defer client.CloseConnection(conn)
-	return StreamAddRaw(conn, key, fields)
+	return StreamAddRaw(conn, key, fields, dependencies...)
 }
 
-// StreamAddRaw appends an entry with an auto-generated ID to a stream
+// StreamAddRaw appends an entry with an auto-generated ID to a stream and links a reference to
+// each dependency for the entire stream
 // Uses existing connection (does not close connection)
 //
 // Spec: https://redis.io/commands/xadd
-func StreamAddRaw(conn redis.Conn, key string, fields map[string]string) (string, error) {
+func StreamAddRaw(conn redis.Conn, key string, fields map[string]string, dependencies ...string) (string, error) {
 	args := make([]interface{}, 0, 2+2*len(fields))
 	args = append(args, key, "*")
 	for k, v := range fields {
 		args = append(args, k, v)
 	}
-	return redis.String(conn.Do(StreamAddCommand, args...))
+	return streamAddRaw(conn, key, args, dependencies)
 }
 
-// StreamAddCapped appends an entry to a stream, trimming it to at most maxLen entries
+// StreamAddCapped appends an entry to a stream, trimming it to at most maxLen entries, and links
+// a reference to each dependency for the entire stream
 // Creates a new connection and closes connection at end of function call
 //
 // Custom connections use method: StreamAddCappedRaw()
-func StreamAddCapped(ctx context.Context, client *Client, key string, maxLen int64, fields map[string]string) (string, error) {
+func StreamAddCapped(ctx context.Context, client *Client, key string, maxLen int64, fields map[string]string,
+	dependencies ...string,
+) (string, error) {
 	conn, err := client.GetConnectionWithContext(ctx)
 	if err != nil {
 		return "", err
 	}
 	defer client.CloseConnection(conn)
-	return StreamAddCappedRaw(conn, key, maxLen, fields)
+	return StreamAddCappedRaw(conn, key, maxLen, fields, dependencies...)
 }
 
-// StreamAddCappedRaw appends an entry to a stream, trimming it to at most maxLen entries
+// StreamAddCappedRaw appends an entry to a stream, trimming it to at most maxLen entries, and
+// links a reference to each dependency for the entire stream
 // Uses existing connection (does not close connection)
 //
 // Spec: https://redis.io/commands/xadd
-func StreamAddCappedRaw(conn redis.Conn, key string, maxLen int64, fields map[string]string) (string, error) {
+func StreamAddCappedRaw(conn redis.Conn, key string, maxLen int64, fields map[string]string,
+	dependencies ...string,
+) (string, error) {
 	args := make([]interface{}, 0, 4+2*len(fields))
 	args = append(args, key, "MAXLEN", "~", maxLen, "*")
 	for k, v := range fields {
 		args = append(args, k, v)
 	}
-	return redis.String(conn.Do(StreamAddCommand, args...))
+	return streamAddRaw(conn, key, args, dependencies)
+}
+
+// streamAddRaw fires XADD and links the stream to the dependencies, returning the entry ID
+func streamAddRaw(conn redis.Conn, key string, args []interface{}, dependencies []string) (string, error) {
+	id, err := redis.String(conn.Do(StreamAddCommand, args...))
+	if err != nil {
+		return "", err
+	}
+	return id, linkDependencies(conn, key, dependencies...)
 }
 
 // StreamRead reads entries from a stream starting at startID (non-blocking)
@@ -92,6 +92,21 @@ func TestStreamAdd(t *testing.T) {
 		assert.Equal(t, "2-0", id)
 	})
 
+	t.Run("stream add with dependencies using mocked redis", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.Command(StreamAddCommand, testKey, "*", "field", "value").Expect([]byte("1-0"))
+		commands := mockLinkDependencies(conn, testKey, testDependantKey)
+
+		id, err := StreamAdd(context.Background(), client, testKey, map[string]string{"field": "value"}, testDependantKey)
+		require.NoError(t, err)
+		assert.Equal(t, "1-0", id)
+		for _, c := range commands {
+			assert.True(t, c.Called)
+		}
+	})
+
 	t.Run("stream add command using real redis", func(t *testing.T) {
 		if testing.Short() {
 			t.Skip("skipping live local redis tests")
@@ -187,6 +202,36 @@ func TestStreamAddCapped(t *testing.T) {
 		assert.Equal(t, "3-0", id)
 	})
 
+	t.Run("stream add capped with dependencies using mocked redis", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.Command(StreamAddCommand, testKey, "MAXLEN", "~", int64(100), "*", "field", "value").
+			Expect([]byte("1-0"))
+		commands := mockLinkDependencies(conn, testKey, testDependantKey)
+
+		id, err := StreamAddCapped(context.Background(), client, testKey, 100,
+			map[string]string{"field": "value"}, testDependantKey)
+		require.NoError(t, err)
+		assert.Equal(t, "1-0", id)
+		for _, c := range commands {
+			assert.True(t, c.Called)
+		}
+	})
+
+	t.Run("stream add capped error skips dependencies using mocked redis", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.Command(StreamAddCommand, testKey, "MAXLEN", "~", int64(100), "*", "field", "value").
+			ExpectError(errTestLoader)
+		multiCmd := conn.GenericCommand(MultiCommand)
+
+		_, err := StreamAddCappedRaw(conn, testKey, 100, map[string]string{"field": "value"}, testDependantKey)
+		require.ErrorIs(t, err, errTestLoader)
+		assert.False(t, multiCmd.Called)
+	})
+
 	t.Run("stream add capped command using real redis", func(t *testing.T) {
 		if testing.Short() {
 			t.Skip("skipping live local redis tests")
@@ -0,0 +1,248 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// Define static errors to avoid dynamic error creation
+var (
+	ErrTxConflict         = errors.New("transaction aborted: a watched key was modified")
+	ErrTxRetriesExhausted = errors.New("transaction retries exhausted")
+	ErrTxReadAfterQueue   = errors.New("transaction commands cannot be run after writes are queued")
+)
+
+const (
+	// defaultTxRetries is the default number of retries after a conflict
+	defaultTxRetries = 5
+
+	// defaultTxBackoffMin is the default backoff before the first retry
+	defaultTxBackoffMin = 5 * time.Millisecond
+
+	// defaultTxBackoffMax is the default maximum backoff between retries
+	defaultTxBackoffMax = 200 * time.Millisecond
+)
+
+// TxOption configures Transaction()
+type TxOption func(*txOptions)
+
+type txOptions struct {
+	retries    int
+	backoffMin time.Duration
+	backoffMax time.Duration
+}
+
+// WithTxRetries sets how many times the transaction is retried after a conflict (default: 5)
+// Use 0 to run it once and return ErrTxRetriesExhausted on the first conflict.
+func WithTxRetries(n int) TxOption {
+	return func(o *txOptions) {
+		if n >= 0 {
+			o.retries = n
+		}
+	}
+}
+
+// WithTxBackoff sets the backoff between retries: it starts at minBackoff and doubles
+// up to maxBackoff (default: 5ms to 200ms). Each wait is jittered by up to 50%.
+func WithTxBackoff(minBackoff, maxBackoff time.Duration) TxOption {
+	return func(o *txOptions) {
+		if minBackoff > 0 {
+			o.backoffMin = minBackoff
+		}
+		if maxBackoff >= o.backoffMin {
+			o.backoffMax = maxBackoff
+		}
+	}
+}
+
+// Tx is the transaction handed to the Transaction() function
+//
+// Read the watched keys with Do() (or any Raw method on Conn()), then queue the writes
+// with Queue(). Queued commands run atomically on EXEC.
+type Tx struct {
+	conn    redis.Conn
+	multi   bool // MULTI has been sent
+	queued  int
+	written []string // keys of the queued commands (dropped from the local cache after EXEC)
+}
+
+// Conn returns the transaction's connection, for reading with the Raw methods
+// Only read before queueing writes; do not close the connection
+func (tx *Tx) Conn() redis.Conn {
+	return tx.conn
+}
+
+// Do runs a command immediately (use it to read the watched keys)
+// Returns ErrTxReadAfterQueue once writes have been queued
+func (tx *Tx) Do(command string, args ...interface{}) (interface{}, error) {
+	if tx.multi {
+		return nil, ErrTxReadAfterQueue
+	}
+	return tx.conn.Do(command, args...)
+}
+
+// Queue adds a command to the transaction (the first call starts the MULTI block)
+func (tx *Tx) Queue(command string, args ...interface{}) error {
+	if !tx.multi {
+		if err := tx.conn.Send(MultiCommand); err != nil {
+			return err
+		}
+		tx.multi = true
+	}
+	if err := tx.conn.Send(command, args...); err != nil {
+		return err
+	}
+	tx.queued++
+	tx.written = append(tx.written, commandKeys(command, args)...)
+	return nil
+}
+
+// commandKeys returns the keys of a command, using the key positions known to namespaced
+// connections; the first argument is assumed to be the key of any other command
+func commandKeys(command string, args []interface{}) []string {
+	command = strings.ToUpper(command)
+	var keys []interface{}
+	switch {
+	case namespaceAllKeysCommands[command]:
+		keys = args
+	case command == MultiSetCommand:
+		for i := 0; i < len(args); i += 2 {
+			keys = append(keys, args[i])
+		}
+	case namespaceScriptCommands[command]:
+		if len(args) > 1 {
+			if n, ok := argInt(args[1]); ok && n > 0 && n+2 <= len(args) {
+				keys = args[2 : n+2]
+			}
+		}
+	case len(args) > 0:
+		keys = args[:1]
+	}
+	out := make([]string, 0, len(keys))
+	for _, key := range keys {
+		out = append(out, argString(key))
+	}
+	return out
+}
+
+// Transaction runs fn as an optimistic (compare-and-set) transaction
+//
+// The watchKeys are WATCHed, fn reads them and queues its writes, then EXEC runs the
+// writes only if no watched key changed in the meantime. On a conflict the whole
+// transaction (including fn) is retried with backoff; once the retries are exhausted
+// the error matches both ErrTxRetriesExhausted and ErrTxConflict.
+//
+// Returns the replies of the queued commands, and the first error reply among them (the
+// other commands still ran). The keys written by the queued commands are dropped from the
+// local cache (if enabled) once EXEC ran.
+// Creates a new connection and closes connection at end of function call
+//
+// Custom connections use method: TransactionRaw()
+func Transaction(ctx context.Context, client *Client, watchKeys []string, fn func(tx *Tx) error,
+	opts ...TxOption,
+) ([]interface{}, error) {
+	o := txOptions{retries: defaultTxRetries, backoffMin: defaultTxBackoffMin, backoffMax: defaultTxBackoffMax}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	conn, err := client.GetConnectionWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer client.CloseConnection(conn)
+
+	backoff := o.backoffMin
+	for attempt := 0; ; attempt++ {
+		replies, written, txErr := transactionRaw(conn, watchKeys, fn)
+		if !errors.Is(txErr, ErrTxConflict) {
+			// Drop the written keys (none unless EXEC ran), even if one of the commands failed
+			if err = client.invalidateLocal(ctx, written...); txErr == nil {
+				txErr = err
+			}
+			return replies, txErr
+		}
+		err = txErr
+		if attempt >= o.retries {
+			return nil, fmt.Errorf("%w after %d attempts: %w", ErrTxRetriesExhausted, attempt+1, err)
+		}
+
+		// Jitter spreads out competing writers so they do not collide again
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(jitter(backoff)):
+		}
+		backoff = min(backoff*2, o.backoffMax)
+	}
+}
+
+// TransactionRaw runs fn as an optimistic (compare-and-set) transaction, once
+// Returns ErrTxConflict if a watched key changed before EXEC (nothing was written)
+// Returns nil replies (and no error) if fn queued nothing
+// Returns the replies with the first error reply (redis.Error) of the queued commands; the
+// other commands still ran, as redis does not roll back
+// Uses existing connection (does not close connection)
+//
+// Commands used:
+// https://redis.io/commands/watch
+// https://redis.io/commands/multi
+// https://redis.io/commands/exec
+func TransactionRaw(conn redis.Conn, watchKeys []string, fn func(tx *Tx) error) ([]interface{}, error) {
+	replies, _, err := transactionRaw(conn, watchKeys, fn)
+	return replies, err
+}
+
+// transactionRaw runs the transaction once and returns the keys written (nil unless EXEC ran)
+func transactionRaw(conn redis.Conn, watchKeys []string,
+	fn func(tx *Tx) error,
+) (replies []interface{}, written []string, err error) {
+	if len(watchKeys) > 0 {
+		if _, err = conn.Do(WatchCommand, toInterfaces(watchKeys)...); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	tx := &Tx{conn: conn}
+	if err = fn(tx); err != nil {
+		tx.abort()
+		return nil, nil, err
+	}
+	if tx.queued == 0 {
+		tx.abort()
+		return nil, nil, nil
+	}
+
+	// EXEC returns nil when a watched key was modified
+	if replies, err = redis.Values(conn.Do(ExecuteCommand)); errors.Is(err, redis.ErrNil) {
+		return nil, nil, ErrTxConflict
+	} else if err != nil {
+		return nil, tx.written, err
+	}
+	for _, reply := range replies {
+		if replyErr, ok := reply.(redis.Error); ok {
+			return replies, tx.written, replyErr
+		}
+	}
+	return replies, tx.written, nil
+}
+
+// abort discards the queued commands (or only releases the watched keys)
+func (tx *Tx) abort() {
+	if tx.multi {
+		_, _ = tx.conn.Do(DiscardCommand)
+		return
+	}
+	_, _ = tx.conn.Do(UnwatchCommand)
+}
+
+// jitter returns a random wait between half and all of the backoff
+func jitter(backoff time.Duration) time.Duration {
+	return backoff/2 + rand.N(backoff/2+1) //nolint:gosec // randomness is for load spreading, not security
+}
@@ -0,0 +1,281 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// incrementTx is a compare-and-set increment of testKey
+func incrementTx(tx *Tx) error {
+	current, err := redis.Int(tx.Do(GetCommand, testKey))
+	if err != nil && !errors.Is(err, redis.ErrNil) {
+		return err
+	}
+	return tx.Queue(SetCommand, testKey, current+1)
+}
+
+// TestTransaction tests the method Transaction()
+func TestTransaction(t *testing.T) {
+	t.Run("watches, reads and executes", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		watchCmd := conn.Command(WatchCommand, testKey).Expect("OK")
+		conn.Command(GetCommand, testKey).Expect([]byte("1"))
+		conn.Command(MultiCommand).Expect("OK")
+		setCmd := conn.Command(SetCommand, testKey, 2).Expect("QUEUED")
+		conn.Command(ExecuteCommand).Expect([]interface{}{"OK"})
+
+		replies, err := Transaction(context.Background(), client, []string{testKey}, incrementTx)
+		require.NoError(t, err)
+		assert.Equal(t, []interface{}{"OK"}, replies)
+		assert.True(t, watchCmd.Called)
+		assert.True(t, setCmd.Called)
+	})
+
+	t.Run("retries after a conflict", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.Command(WatchCommand, testKey).Expect("OK")
+		conn.Command(GetCommand, testKey).Expect([]byte("1"))
+		conn.Command(MultiCommand).Expect("OK")
+		conn.Command(SetCommand, testKey, 2).Expect("QUEUED")
+		execCmd := conn.Command(ExecuteCommand).Expect(nil).Expect([]interface{}{"OK"})
+
+		replies, err := Transaction(context.Background(), client, []string{testKey}, incrementTx,
+			WithTxBackoff(time.Millisecond, time.Millisecond))
+		require.NoError(t, err)
+		assert.Equal(t, []interface{}{"OK"}, replies)
+		assert.Equal(t, 2, conn.Stats(execCmd))
+	})
+
+	t.Run("retries exhausted", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.Command(WatchCommand, testKey).Expect("OK")
+		conn.Command(GetCommand, testKey).Expect([]byte("1"))
+		conn.Command(MultiCommand).Expect("OK")
+		conn.Command(SetCommand, testKey, 2).Expect("QUEUED")
+		execCmd := conn.Command(ExecuteCommand).Expect(nil)
+
+		_, err := Transaction(context.Background(), client, []string{testKey}, incrementTx,
+			WithTxRetries(2), WithTxBackoff(time.Millisecond, 2*time.Millisecond))
+		require.ErrorIs(t, err, ErrTxRetriesExhausted)
+		require.ErrorIs(t, err, ErrTxConflict)
+		assert.Equal(t, 3, conn.Stats(execCmd))
+	})
+
+	t.Run("context canceled while backing off", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.Command(WatchCommand, testKey).Expect("OK")
+		conn.Command(GetCommand, testKey).Expect([]byte("1"))
+		conn.Command(MultiCommand).Expect("OK")
+		conn.Command(SetCommand, testKey, 2).Expect("QUEUED")
+		conn.Command(ExecuteCommand).Expect(nil)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		_, err := Transaction(ctx, client, []string{testKey}, incrementTx, WithTxBackoff(time.Minute, time.Minute))
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("function error discards the transaction", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.Command(WatchCommand, testKey).Expect("OK")
+		conn.Command(MultiCommand).Expect("OK")
+		conn.Command(SetCommand, testKey, "v").Expect("QUEUED")
+		discardCmd := conn.Command(DiscardCommand).Expect("OK")
+
+		_, err := Transaction(context.Background(), client, []string{testKey}, func(tx *Tx) error {
+			if err := tx.Queue(SetCommand, testKey, "v"); err != nil {
+				return err
+			}
+			return errTestLoader
+		})
+		require.ErrorIs(t, err, errTestLoader)
+		assert.True(t, discardCmd.Called)
+	})
+
+	t.Run("nothing queued releases the watch", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.Command(WatchCommand, testKey).Expect("OK")
+		unwatchCmd := conn.Command(UnwatchCommand).Expect("OK")
+		execCmd := conn.Command(ExecuteCommand).Expect([]interface{}{})
+
+		replies, err := TransactionRaw(conn, []string{testKey}, func(*Tx) error { return nil })
+		require.NoError(t, err)
+		assert.Nil(t, replies)
+		assert.True(t, unwatchCmd.Called)
+		assert.False(t, execCmd.Called)
+	})
+
+	t.Run("reads are refused after queueing", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.Command(MultiCommand).Expect("OK")
+		conn.Command(SetCommand, testKey, "v").Expect("QUEUED")
+		conn.Command(DiscardCommand).Expect("OK")
+
+		_, err := TransactionRaw(conn, nil, func(tx *Tx) error {
+			if err := tx.Queue(SetCommand, testKey, "v"); err != nil {
+				return err
+			}
+			_, err := tx.Do(GetCommand, testKey)
+			return err
+		})
+		require.ErrorIs(t, err, ErrTxReadAfterQueue)
+	})
+
+	t.Run("written keys are dropped from the local cache", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+		lc := loadMockLocalCache(client, LocalCacheOptions{})
+		for _, key := range []string{testKey, "other", "deleted", "untouched"} {
+			lc.store.set(key, testStringValue, lc.store.currentEpoch())
+		}
+
+		conn.Command(WatchCommand, testKey).Expect("OK")
+		conn.Command(MultiCommand).Expect("OK")
+		conn.Command(SetCommand, "other", "v").Expect("QUEUED")
+		conn.Command(DeleteCommand, "deleted").Expect("QUEUED")
+		conn.Command(ExecuteCommand).Expect([]interface{}{"OK", int64(1)})
+		conn.GenericCommand(PublishCommand).Expect(int64(0))
+
+		_, err := Transaction(context.Background(), client, []string{testKey}, func(tx *Tx) error {
+			if err := tx.Queue(SetCommand, "other", "v"); err != nil {
+				return err
+			}
+			return tx.Queue(DeleteCommand, "deleted")
+		})
+		require.NoError(t, err)
+
+		for _, key := range []string{"other", "deleted"} {
+			_, ok := lc.store.get(key)
+			assert.False(t, ok, key)
+		}
+		for _, key := range []string{testKey, "untouched"} {
+			_, ok := lc.store.get(key)
+			assert.True(t, ok, key)
+		}
+	})
+
+	t.Run("first error reply is returned", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+		lc := loadMockLocalCache(client, LocalCacheOptions{})
+		lc.store.set(testKey, testStringValue, lc.store.currentEpoch())
+
+		conn.Command(WatchCommand, testKey).Expect("OK")
+		conn.Command(GetCommand, testKey).Expect([]byte("1"))
+		conn.Command(MultiCommand).Expect("OK")
+		conn.Command(SetCommand, testKey, 2).Expect("QUEUED")
+		conn.Command(ExecuteCommand).Expect([]interface{}{
+			redis.Error("WRONGTYPE Operation against a key holding the wrong kind of value"), "OK",
+		})
+		conn.GenericCommand(PublishCommand).Expect(int64(0))
+
+		replies, err := Transaction(context.Background(), client, []string{testKey}, incrementTx)
+		var replyErr redis.Error
+		require.ErrorAs(t, err, &replyErr)
+		assert.Contains(t, replyErr.Error(), "WRONGTYPE")
+		assert.Len(t, replies, 2)
+
+		_, ok := lc.store.get(testKey)
+		assert.False(t, ok, "commands that succeeded were applied")
+	})
+
+	t.Run("watch error", func(t *testing.T) {
+		client, conn := loadMockRedis(t)
+		defer client.CloseAll(conn)
+
+		conn.Command(WatchCommand, testKey).ExpectError(errTestLoader)
+
+		_, err := Transaction(context.Background(), client, []string{testKey}, incrementTx)
+		require.ErrorIs(t, err, errTestLoader)
+	})
+
+	t.Run("concurrent increments using real redis", func(t *testing.T) {
+		if testing.Short() {
+			t.Skip("skipping live local redis tests")
+		}
+
+		client, conn, err := loadRealRedis(t)
+		require.NoError(t, err)
+		defer client.CloseAll(conn)
+		require.NoError(t, clearRealRedis(conn))
+
+		const writers = 10
+		var wg sync.WaitGroup
+		errs := make([]error, writers)
+		for i := 0; i < writers; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				_, errs[i] = Transaction(context.Background(), client, []string{testKey}, incrementTx,
+					WithTxRetries(100))
+			}(i)
+		}
+		wg.Wait()
+		for _, txErr := range errs {
+			require.NoError(t, txErr)
+		}
+
+		var value string
+		value, err = GetRaw(conn, testKey)
+		require.NoError(t, err)
+		assert.Equal(t, strconv.Itoa(writers), value)
+	})
+}
+
+// TestCommandKeys tests the method commandKeys()
+func TestCommandKeys(t *testing.T) {
+	assert.Equal(t, []string{"a"}, commandKeys(SetCommand, []interface{}{"a", "v"}))
+	assert.Equal(t, []string{"a", "b"}, commandKeys("del", []interface{}{"a", "b"}))
+	assert.Equal(t, []string{"a", "b"}, commandKeys(MultiSetCommand, []interface{}{"a", 1, "b", 2}))
+	assert.Equal(t, []string{"a"}, commandKeys(EvalCommand, []interface{}{"sha", 1, "a", "arg"}))
+	assert.Equal(t, []string{"a"}, commandKeys("HINCRBY", []interface{}{"a", "f", 1}))
+	assert.Empty(t, commandKeys(EvalCommand, []interface{}{"sha", 3, "a"}))
+	assert.Empty(t, commandKeys("PING", nil))
+}
+
+// ExampleTransaction is an example of the method Transaction()
+func ExampleTransaction() {
+	// Load a mocked redis for testing/examples
+	client, conn := loadMockRedis()
+
+	// Close connections at end of request
+	defer client.CloseAll(conn)
+
+	// Mock the transaction
+	conn.Command(WatchCommand, testKey).Expect("OK")
+	conn.Command(GetCommand, testKey).Expect([]byte("41"))
+	conn.Command(MultiCommand).Expect("OK")
+	conn.Command(SetCommand, testKey, 42).Expect("QUEUED")
+	conn.Command(ExecuteCommand).Expect([]interface{}{"OK"})
+
+	// Increment the counter only if nobody else changed it in the meantime
+	_, err := Transaction(context.Background(), client, []string{testKey}, incrementTx)
+	if err != nil {
+		return
+	}
+	fmt.Print("counter incremented")
+	// Output:counter incremented
+}